@@ -7,12 +7,30 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
+	// Embed the timezone database so requests can name a timezone on hosts
+	// without one.
+	_ "time/tzdata"
 
 	"github.com/tobilg/text-to-sql-proxy/src/internal/config"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/dialect"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/examples"
 	"github.com/tobilg/text-to-sql-proxy/src/internal/handler"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
 	"github.com/tobilg/text-to-sql-proxy/src/internal/provider"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/semantic"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/session"
+)
+
+// writeTimeout is the server's write deadline. Generation is cancelled a
+// little earlier so the timeout error can still reach the client.
+const (
+	writeTimeout    = 60 * time.Second
+	generateTimeout = writeTimeout - 5*time.Second
 )
 
 var (
@@ -29,33 +47,176 @@ func main() {
 
 	cfg := config.Load()
 
+	prompts, err := prompt.Load(cfg.PromptsDir)
+	if err != nil {
+		log.Fatalf("Failed to load prompt templates: %v", err)
+	}
+
 	// Initialize all providers
 	providers := map[string]provider.SQLGenerator{
-		"claude":   provider.NewClaudeClient(cfg.Database),
-		"gemini":   provider.NewGeminiClient(cfg.Database),
-		"codex":    provider.NewCodexClient(cfg.Database),
-		"continue": provider.NewContinueClient(cfg.Database),
-		"opencode": provider.NewOpenCodeClient(cfg.Database),
+		"claude":   provider.NewClaudeClient(cfg.Database, prompts),
+		"gemini":   provider.NewGeminiClient(cfg.Database, prompts),
+		"codex":    provider.NewCodexClient(cfg.Database, prompts),
+		"continue": provider.NewContinueClient(cfg.Database, prompts),
+		"opencode": provider.NewOpenCodeClient(cfg.Database, prompts),
+	}
+
+	// API providers are only available when credentials are configured
+	if cfg.AnthropicAPIKey != "" {
+		providers["anthropic"] = provider.NewAnthropicClient(cfg.Database, prompts, cfg.AnthropicAPIKey, cfg.AnthropicBaseURL, cfg.AnthropicModel)
+	}
+	if cfg.OpenAIBaseURL != "" {
+		if cfg.OpenAIModel == "" {
+			log.Fatalf("TEXT_TO_SQL_PROXY_OPENAI_MODEL is required when TEXT_TO_SQL_PROXY_OPENAI_BASE_URL is set")
+		}
+		providers["openai"] = provider.NewOpenAICompatibleClient(cfg.Database, prompts, cfg.OpenAIBaseURL, cfg.OpenAIAPIKey, cfg.OpenAIModel, cfg.OpenAIResponseFormat)
+	}
+
+	// Config-defined command providers
+	if cfg.CommandsFile != "" {
+		specs, err := provider.LoadCommandSpecs(cfg.CommandsFile)
+		if err != nil {
+			log.Fatalf("Failed to load command providers: %v", err)
+		}
+		for _, spec := range specs {
+			if _, exists := providers[spec.Name]; exists {
+				log.Fatalf("Command provider %s conflicts with a built-in provider", spec.Name)
+			}
+			client, err := provider.NewCommandClient(cfg.Database, prompts, spec)
+			if err != nil {
+				log.Fatalf("Invalid command provider: %v", err)
+			}
+			providers[spec.Name] = client
+		}
+	}
+
+	var layer *semantic.Layer
+	if cfg.SemanticFile != "" {
+		layer, err = semantic.Load(cfg.SemanticFile)
+		if err != nil {
+			log.Fatalf("Failed to load semantic layer: %v", err)
+		}
 	}
 
+	var library *examples.Library
+	if cfg.ExamplesFile != "" {
+		library, err = examples.Load(cfg.ExamplesFile)
+		if err != nil {
+			log.Fatalf("Failed to load examples: %v", err)
+		}
+	}
+
+	providerNames := make([]string, 0, len(providers))
+	for name := range providers {
+		providerNames = append(providerNames, name)
+	}
+	sort.Strings(providerNames)
+
 	// Validate configured provider exists
 	if _, ok := providers[cfg.Provider]; !ok {
-		log.Fatalf("Unknown provider: %s (valid options: claude, gemini, codex, continue, opencode)", cfg.Provider)
+		log.Fatalf("Unknown provider: %s (valid options: %s)", cfg.Provider, strings.Join(providerNames, ", "))
+	}
+
+	for head, chain := range cfg.FallbackChains {
+		for _, name := range append([]string{head}, chain...) {
+			if _, ok := providers[name]; !ok {
+				log.Fatalf("Unknown provider in fallback chain: %s (valid options: %s)", name, strings.Join(providerNames, ", "))
+			}
+		}
 	}
 
-	h := handler.New(providers, cfg.Provider, cfg.AllowedOrigin)
+	for _, name := range append(cfg.RaceProviders, cfg.ConsensusProviders...) {
+		if _, ok := providers[name]; !ok {
+			log.Fatalf("Unknown race or consensus provider: %s (valid options: %s)", name, strings.Join(providerNames, ", "))
+		}
+	}
+	if _, ok := providers[cfg.CriticReviewer]; !ok && cfg.CriticReviewer != "" {
+		log.Fatalf("Unknown critic reviewer: %s (valid options: %s)", cfg.CriticReviewer, strings.Join(providerNames, ", "))
+	}
+	if cfg.Strategy == "critic" && cfg.CriticReviewer == "" {
+		log.Printf("[WARN] Critic strategy without TEXT_TO_SQL_PROXY_CRITIC_REVIEWER: requests must name a reviewer")
+	}
+
+	// The API providers have their own model settings
+	if _, ok := cfg.Models["anthropic"]; !ok && providers["anthropic"] != nil {
+		cfg.Models["anthropic"] = cfg.AnthropicModel
+	}
+	if _, ok := cfg.Models["openai"]; !ok && providers["openai"] != nil {
+		cfg.Models["openai"] = cfg.OpenAIModel
+	}
+
+	for name, model := range cfg.Models {
+		if _, ok := providers[name]; !ok {
+			log.Fatalf("Unknown provider in model defaults: %s (valid options: %s)", name, strings.Join(providerNames, ", "))
+		}
+		if allowed := cfg.AllowedModels[name]; len(allowed) > 0 && !slices.Contains(allowed, model) {
+			log.Fatalf("Default model %s of provider %s is not in its allowed models: %s", model, name, strings.Join(allowed, ", "))
+		}
+	}
+	for name := range cfg.AllowedModels {
+		if _, ok := providers[name]; !ok {
+			log.Fatalf("Unknown provider in allowed models: %s (valid options: %s)", name, strings.Join(providerNames, ", "))
+		}
+	}
+
+	// Detect which providers are actually usable, then keep checking
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	defer stopMonitor()
+
+	monitor := provider.NewMonitor(providers, cfg.AuthProbe)
+	monitor.Refresh(monitorCtx)
+	if cfg.ProbeInterval > 0 {
+		go monitor.Run(monitorCtx, cfg.ProbeInterval)
+	}
+
+	var installed, missing []string
+	for _, name := range providerNames {
+		if status, _ := monitor.Status(name); status.Installed {
+			installed = append(installed, name)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+
+	if status, _ := monitor.Status(cfg.Provider); !status.Installed {
+		log.Printf("[WARN] Default provider %s is not installed: %s", cfg.Provider, status.LastError)
+	}
+
+	var sessions *session.Store
+	if cfg.SessionTTL > 0 {
+		sessions = session.NewStore(cfg.SessionTTL, cfg.SessionMax, cfg.SessionMaxBytes)
+	}
+
+	h := handler.New(providers, cfg.Provider, cfg.AllowedOrigin,
+		handler.WithStatus(monitor),
+		handler.WithFallbackChains(cfg.FallbackChains, cfg.FallbackTimeout),
+		handler.WithDefaultStrategy(cfg.Strategy),
+		handler.WithRaceProviders(cfg.RaceProviders),
+		handler.WithConsensusProviders(cfg.ConsensusProviders),
+		handler.WithCriticReviewer(cfg.CriticReviewer),
+		handler.WithModels(cfg.Models, cfg.AllowedModels),
+		handler.WithDatabase(cfg.Database, cfg.AllowedDatabases),
+		handler.WithExamples(library, cfg.ExamplesBudget),
+		handler.WithSemanticLayer(layer),
+		handler.WithSchemaPruning(cfg.SchemaBudget, cfg.SchemaMaxTables),
+		handler.WithDates(cfg.Dates),
+		handler.WithDDLNormalization(cfg.NormalizeDDL),
+		handler.WithSessions(sessions),
+		handler.WithCorrection(cfg.CorrectionRounds),
+	)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/generate-sql", h.HandleGenerateSQL)
+	mux.HandleFunc("/generate-sql/stream", h.HandleGenerateSQLStream)
 	mux.HandleFunc("/providers", h.HandleProviders)
 	mux.HandleFunc("/health", h.HandleHealth)
 	mux.HandleFunc("/openapi.json", h.HandleOpenAPI)
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Port),
-		Handler:      mux,
+		Handler:      handler.WithTimeout(mux, generateTimeout),
 		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 60 * time.Second,
+		WriteTimeout: writeTimeout,
 		IdleTimeout:  120 * time.Second,
 	}
 
@@ -71,12 +232,45 @@ func main() {
 
 		fmt.Printf("Text-to-SQL Proxy active at %s://localhost:%d\n", protocol, cfg.Port)
 		fmt.Printf("Default provider: %s\n", cfg.Provider)
-		fmt.Printf("Target database: %s\n", cfg.Database)
+		if profile := dialect.Lookup(cfg.Database); profile != nil {
+			fmt.Printf("Target database: %s (dialect profile %s)\n", cfg.Database, profile.Name)
+		} else {
+			fmt.Printf("Target database: %s (no dialect profile, known: %s)\n", cfg.Database, strings.Join(dialect.Names(), ", "))
+		}
+		if len(cfg.AllowedDatabases) > 0 {
+			fmt.Printf("Allowed databases: %s\n", strings.Join(cfg.AllowedDatabases, ", "))
+		}
+		if cfg.PromptsDir != "" {
+			fmt.Printf("Prompt templates: %s\n", cfg.PromptsDir)
+		}
+		if layer != nil {
+			fmt.Printf("Semantic layer: %d terms, %d metrics, %d columns from %s\n", len(layer.Terms), len(layer.Metrics), len(layer.Columns), cfg.SemanticFile)
+		}
+		if cfg.ExamplesFile != "" {
+			fmt.Printf("Few-shot examples: %d from %s\n", library.Len(), cfg.ExamplesFile)
+		}
+		fmt.Printf("Relative dates: %s\n", cfg.Dates)
+		if sessions != nil {
+			fmt.Printf("Sessions: expire after %s, at most %d kept in %d bytes\n", cfg.SessionTTL, cfg.SessionMax, cfg.SessionMaxBytes)
+		}
+		if cfg.CorrectionRounds > 0 {
+			fmt.Printf("Schema validation: up to %d correction rounds\n", cfg.CorrectionRounds)
+		}
 		fmt.Printf("Allowed origin: %s\n", cfg.AllowedOrigin)
+		fmt.Printf("Default strategy: %s\n", cfg.Strategy)
+		if cfg.CriticReviewer != "" {
+			fmt.Printf("Critic reviewer: %s\n", cfg.CriticReviewer)
+		}
+		for head, chain := range cfg.FallbackChains {
+			fmt.Printf("Fallback chain: %s -> %s\n", head, strings.Join(chain, " -> "))
+		}
 		if cfg.TLSEnabled() {
 			fmt.Printf("TLS enabled: cert=%s, key=%s\n", cfg.TLSCert, cfg.TLSKey)
 		}
-		fmt.Println("Available providers: claude, gemini, codex, continue, opencode")
+		fmt.Printf("Available providers: %s\n", strings.Join(installed, ", "))
+		if len(missing) > 0 {
+			fmt.Printf("Not installed: %s\n", strings.Join(missing, ", "))
+		}
 		fmt.Printf("API docs: %s://localhost:%d/openapi.json\n", protocol, cfg.Port)
 		fmt.Println("Press Ctrl+C to stop")
 
@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -10,16 +12,135 @@ const (
 	defaultAllowedOrigin = "https://sql-workbench.com"
 	defaultProvider      = "claude"
 	defaultDatabase      = "DuckDB"
+
+	defaultAnthropicBaseURL = "https://api.anthropic.com"
+	defaultAnthropicModel   = "claude-sonnet-4-5"
+
+	defaultOpenAIResponseFormat = "json_schema"
+
+	defaultProbeInterval = 5 * time.Minute
+
+	defaultStrategy = "fallback"
+
+	defaultDates = "literal"
+
+	defaultSessionTTL      = 30 * time.Minute
+	defaultSessionMax      = 1000
+	defaultSessionMaxBytes = 64 << 20
+
+	defaultCorrectionRounds = 0
+
+	defaultExamplesBudget = 1000
 )
 
+// validOpenAIResponseFormats lists the accepted response_format modes for the
+// OpenAI-compatible provider.
+var validOpenAIResponseFormats = map[string]bool{
+	"json_schema": true,
+	"json_object": true,
+	"text":        true,
+}
+
+// validStrategies lists the accepted default generation strategies.
+var validStrategies = map[string]bool{
+	"fallback":  true,
+	"race":      true,
+	"consensus": true,
+	"critic":    true,
+}
+
+// validDates lists the accepted ways of expressing relative dates.
+var validDates = map[string]bool{
+	"literal":   true,
+	"functions": true,
+}
+
 // Config holds the application configuration.
 type Config struct {
 	Port          int
 	AllowedOrigin string
 	Provider      string
 	Database      string
-	TLSCert       string
-	TLSKey        string
+	// AllowedDatabases lists the databases requests may target instead of
+	// Database.
+	AllowedDatabases []string
+	TLSCert          string
+	TLSKey           string
+
+	AnthropicAPIKey  string
+	AnthropicBaseURL string
+	AnthropicModel   string
+
+	OpenAIBaseURL        string
+	OpenAIAPIKey         string
+	OpenAIModel          string
+	OpenAIResponseFormat string
+
+	CommandsFile string
+
+	// PromptsDir holds prompt templates overriding the embedded defaults.
+	PromptsDir string
+
+	// ExamplesFile is a JSONL library of few-shot examples; ExamplesBudget
+	// caps the estimated tokens of the examples added to a prompt.
+	ExamplesFile   string
+	ExamplesBudget int
+
+	// SchemaBudget and SchemaMaxTables limit the estimated tokens and the
+	// tables of the DDL sent to providers; larger schemas are pruned to the
+	// tables relevant to the question. 0 disables a limit; both are
+	// disabled by default.
+	SchemaBudget    int
+	SchemaMaxTables int
+
+	// SessionTTL is how long conversations are kept after their last turn;
+	// 0 disables sessions. SessionMax and SessionMaxBytes bound the
+	// conversations kept and the bytes they take; 0 disables a limit.
+	SessionTTL      time.Duration
+	SessionMax      int
+	SessionMaxBytes int
+
+	// CorrectionRounds is how often SQL that fails validation against the
+	// request's DDL is sent back to the provider with its problems, and
+	// racing providers only win with SQL that passes it; 0 disables
+	// validation.
+	CorrectionRounds int
+
+	// NormalizeDDL compacts schema dumps such as pg_dump output before they
+	// are sent to providers. It is disabled by default.
+	NormalizeDDL bool
+
+	// SemanticFile is a JSON semantic layer of business terms, metric
+	// definitions and column descriptions.
+	SemanticFile string
+
+	// Dates selects whether prompts ask for relative dates as literals
+	// computed from the request's current date ("literal") or as the
+	// database's date functions ("functions").
+	Dates string
+
+	ProbeInterval time.Duration
+	AuthProbe     bool
+
+	// FallbackChains maps a provider to the providers tried, in order, when
+	// it fails.
+	FallbackChains  map[string][]string
+	FallbackTimeout time.Duration
+
+	// Strategy is the default generation strategy; RaceProviders and
+	// ConsensusProviders are the providers those strategies use by default
+	// and CriticReviewer the provider reviewing drafts with the critic
+	// strategy.
+	Strategy           string
+	RaceProviders      []string
+	ConsensusProviders []string
+	CriticReviewer     string
+
+	// Models maps a provider to the model it uses when a request does not
+	// name one; AllowedModels maps a provider to the models requests may
+	// pick. Providers without an allowlist accept any model.
+	Models        map[string]string
+	AllowedModels map[string][]string
 }
 
 // TLSEnabled returns true if both TLS cert and key are configured.
@@ -34,6 +155,20 @@ func Load() Config {
 		AllowedOrigin: defaultAllowedOrigin,
 		Provider:      defaultProvider,
 		Database:      defaultDatabase,
+
+		AnthropicBaseURL: defaultAnthropicBaseURL,
+		AnthropicModel:   defaultAnthropicModel,
+
+		OpenAIResponseFormat: defaultOpenAIResponseFormat,
+		Strategy:             defaultStrategy,
+		Dates:                defaultDates,
+		SessionTTL:           defaultSessionTTL,
+		SessionMax:           defaultSessionMax,
+		SessionMaxBytes:      defaultSessionMaxBytes,
+		CorrectionRounds:     defaultCorrectionRounds,
+
+		ExamplesBudget: defaultExamplesBudget,
+		ProbeInterval:  defaultProbeInterval,
 	}
 
 	if portStr := os.Getenv("TEXT_TO_SQL_PROXY_PORT"); portStr != "" {
@@ -57,5 +192,152 @@ func Load() Config {
 	cfg.TLSCert = os.Getenv("TEXT_TO_SQL_PROXY_TLS_CERT")
 	cfg.TLSKey = os.Getenv("TEXT_TO_SQL_PROXY_TLS_KEY")
 
+	cfg.AnthropicAPIKey = os.Getenv("TEXT_TO_SQL_PROXY_ANTHROPIC_API_KEY")
+
+	if baseURL := os.Getenv("TEXT_TO_SQL_PROXY_ANTHROPIC_BASE_URL"); baseURL != "" {
+		cfg.AnthropicBaseURL = baseURL
+	}
+
+	if model := os.Getenv("TEXT_TO_SQL_PROXY_ANTHROPIC_MODEL"); model != "" {
+		cfg.AnthropicModel = model
+	}
+
+	cfg.OpenAIBaseURL = os.Getenv("TEXT_TO_SQL_PROXY_OPENAI_BASE_URL")
+	cfg.OpenAIAPIKey = os.Getenv("TEXT_TO_SQL_PROXY_OPENAI_API_KEY")
+	cfg.OpenAIModel = os.Getenv("TEXT_TO_SQL_PROXY_OPENAI_MODEL")
+
+	if format := os.Getenv("TEXT_TO_SQL_PROXY_OPENAI_RESPONSE_FORMAT"); validOpenAIResponseFormats[format] {
+		cfg.OpenAIResponseFormat = format
+	}
+
+	cfg.CommandsFile = os.Getenv("TEXT_TO_SQL_PROXY_COMMANDS_FILE")
+	cfg.PromptsDir = os.Getenv("TEXT_TO_SQL_PROXY_PROMPTS_DIR")
+
+	if budget, err := strconv.Atoi(os.Getenv("TEXT_TO_SQL_PROXY_SCHEMA_BUDGET")); err == nil && budget >= 0 {
+		cfg.SchemaBudget = budget
+	}
+	if maxTables, err := strconv.Atoi(os.Getenv("TEXT_TO_SQL_PROXY_SCHEMA_MAX_TABLES")); err == nil && maxTables >= 0 {
+		cfg.SchemaMaxTables = maxTables
+	}
+
+	cfg.SemanticFile = os.Getenv("TEXT_TO_SQL_PROXY_SEMANTIC_FILE")
+
+	if ttlStr := os.Getenv("TEXT_TO_SQL_PROXY_SESSION_TTL"); ttlStr != "" {
+		if ttl, err := time.ParseDuration(ttlStr); err == nil && ttl >= 0 {
+			cfg.SessionTTL = ttl
+		}
+	}
+	if limit, err := strconv.Atoi(os.Getenv("TEXT_TO_SQL_PROXY_SESSION_MAX")); err == nil && limit >= 0 {
+		cfg.SessionMax = limit
+	}
+	if maxBytes, err := strconv.Atoi(os.Getenv("TEXT_TO_SQL_PROXY_SESSION_MAX_BYTES")); err == nil && maxBytes >= 0 {
+		cfg.SessionMaxBytes = maxBytes
+	}
+
+	if rounds, err := strconv.Atoi(os.Getenv("TEXT_TO_SQL_PROXY_CORRECTION_ROUNDS")); err == nil && rounds >= 0 {
+		cfg.CorrectionRounds = rounds
+	}
+
+	if normalize, err := strconv.ParseBool(os.Getenv("TEXT_TO_SQL_PROXY_NORMALIZE_DDL")); err == nil {
+		cfg.NormalizeDDL = normalize
+	}
+
+	if dates := os.Getenv("TEXT_TO_SQL_PROXY_DATES"); validDates[dates] {
+		cfg.Dates = dates
+	}
+
+	cfg.ExamplesFile = os.Getenv("TEXT_TO_SQL_PROXY_EXAMPLES_FILE")
+	if budget, err := strconv.Atoi(os.Getenv("TEXT_TO_SQL_PROXY_EXAMPLES_BUDGET")); err == nil && budget >= 0 {
+		cfg.ExamplesBudget = budget
+	}
+
+	if intervalStr := os.Getenv("TEXT_TO_SQL_PROXY_PROBE_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil && interval >= 0 {
+			cfg.ProbeInterval = interval
+		}
+	}
+
+	if authProbe, err := strconv.ParseBool(os.Getenv("TEXT_TO_SQL_PROXY_AUTH_PROBE")); err == nil {
+		cfg.AuthProbe = authProbe
+	}
+
+	cfg.FallbackChains = parseChains(os.Getenv("TEXT_TO_SQL_PROXY_FALLBACK_CHAINS"))
+
+	if timeoutStr := os.Getenv("TEXT_TO_SQL_PROXY_FALLBACK_TIMEOUT"); timeoutStr != "" {
+		if timeout, err := time.ParseDuration(timeoutStr); err == nil && timeout >= 0 {
+			cfg.FallbackTimeout = timeout
+		}
+	}
+
+	if strategy := os.Getenv("TEXT_TO_SQL_PROXY_STRATEGY"); validStrategies[strategy] {
+		cfg.Strategy = strategy
+	}
+
+	cfg.RaceProviders = parseList(os.Getenv("TEXT_TO_SQL_PROXY_RACE_PROVIDERS"))
+	cfg.ConsensusProviders = parseList(os.Getenv("TEXT_TO_SQL_PROXY_CONSENSUS_PROVIDERS"))
+	cfg.CriticReviewer = strings.TrimSpace(os.Getenv("TEXT_TO_SQL_PROXY_CRITIC_REVIEWER"))
+
+	cfg.Models = make(map[string]string)
+	for name, models := range parseProviderMap(os.Getenv("TEXT_TO_SQL_PROXY_MODELS")) {
+		cfg.Models[name] = models[0]
+	}
+	cfg.AllowedModels = parseProviderMap(os.Getenv("TEXT_TO_SQL_PROXY_ALLOWED_MODELS"))
+	cfg.AllowedDatabases = parseList(os.Getenv("TEXT_TO_SQL_PROXY_ALLOWED_DATABASES"))
+
 	return cfg
 }
+
+// parseList splits a comma-separated list, dropping blank entries.
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseChains parses fallback chains such as "claude->codex->gemini;anthropic->openai"
+// into a map from each chain's first provider to the rest of the chain.
+// Chains with fewer than two providers are ignored.
+func parseChains(value string) map[string][]string {
+	chains := make(map[string][]string)
+
+	for _, chain := range strings.Split(value, ";") {
+		var names []string
+		for _, name := range strings.Split(chain, "->") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		if len(names) < 2 {
+			continue
+		}
+		chains[names[0]] = names[1:]
+	}
+
+	return chains
+}
+
+// parseProviderMap parses per-provider lists such as
+// "claude=haiku,sonnet;codex=gpt-5-codex" into a map from provider to its
+// list. Entries without a provider name or without values are ignored.
+func parseProviderMap(value string) map[string][]string {
+	entries := make(map[string][]string)
+
+	for _, entry := range strings.Split(value, ";") {
+		name, list, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		items := parseList(list)
+		if name == "" || len(items) == 0 {
+			continue
+		}
+		entries[name] = items
+	}
+
+	return entries
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad_Defaults(t *testing.T) {
@@ -11,8 +13,37 @@ func TestLoad_Defaults(t *testing.T) {
 	os.Unsetenv("TEXT_TO_SQL_PROXY_ALLOWED_ORIGIN")
 	os.Unsetenv("TEXT_TO_SQL_PROXY_PROVIDER")
 	os.Unsetenv("TEXT_TO_SQL_PROXY_DATABASE")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_ALLOWED_DATABASES")
 	os.Unsetenv("TEXT_TO_SQL_PROXY_TLS_CERT")
 	os.Unsetenv("TEXT_TO_SQL_PROXY_TLS_KEY")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_ANTHROPIC_API_KEY")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_ANTHROPIC_BASE_URL")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_ANTHROPIC_MODEL")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_OPENAI_BASE_URL")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_OPENAI_API_KEY")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_OPENAI_MODEL")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_OPENAI_RESPONSE_FORMAT")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_COMMANDS_FILE")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_PROBE_INTERVAL")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_AUTH_PROBE")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_FALLBACK_CHAINS")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_FALLBACK_TIMEOUT")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_STRATEGY")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_RACE_PROVIDERS")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_CONSENSUS_PROVIDERS")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_MODELS")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_ALLOWED_MODELS")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_PROMPTS_DIR")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_EXAMPLES_FILE")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_EXAMPLES_BUDGET")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_SEMANTIC_FILE")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_SCHEMA_BUDGET")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_SCHEMA_MAX_TABLES")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_DATES")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_NORMALIZE_DDL")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_SESSION_TTL")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_SESSION_MAX")
+	os.Unsetenv("TEXT_TO_SQL_PROXY_SESSION_MAX_BYTES")
 
 	cfg := Load()
 
@@ -37,6 +68,90 @@ func TestLoad_Defaults(t *testing.T) {
 	if cfg.TLSEnabled() {
 		t.Error("expected TLS to be disabled by default")
 	}
+	if cfg.AnthropicAPIKey != "" {
+		t.Errorf("expected empty Anthropic API key, got %s", cfg.AnthropicAPIKey)
+	}
+	if cfg.AnthropicBaseURL != "https://api.anthropic.com" {
+		t.Errorf("expected default Anthropic base URL, got %s", cfg.AnthropicBaseURL)
+	}
+	if cfg.AnthropicModel != "claude-sonnet-4-5" {
+		t.Errorf("expected default Anthropic model claude-sonnet-4-5, got %s", cfg.AnthropicModel)
+	}
+	if cfg.OpenAIBaseURL != "" {
+		t.Errorf("expected empty OpenAI base URL, got %s", cfg.OpenAIBaseURL)
+	}
+	if cfg.OpenAIResponseFormat != "json_schema" {
+		t.Errorf("expected default OpenAI response format json_schema, got %s", cfg.OpenAIResponseFormat)
+	}
+	if cfg.CommandsFile != "" {
+		t.Errorf("expected empty commands file, got %s", cfg.CommandsFile)
+	}
+	if cfg.PromptsDir != "" {
+		t.Errorf("expected empty prompts dir, got %s", cfg.PromptsDir)
+	}
+	if cfg.ProbeInterval != 5*time.Minute {
+		t.Errorf("expected default probe interval 5m, got %s", cfg.ProbeInterval)
+	}
+	if cfg.AuthProbe {
+		t.Error("expected auth probe to be disabled by default")
+	}
+	if len(cfg.FallbackChains) != 0 {
+		t.Errorf("expected no fallback chains, got %v", cfg.FallbackChains)
+	}
+	if cfg.FallbackTimeout != 0 {
+		t.Errorf("expected no fallback timeout, got %s", cfg.FallbackTimeout)
+	}
+	if cfg.Strategy != "fallback" {
+		t.Errorf("expected default strategy fallback, got %s", cfg.Strategy)
+	}
+	if len(cfg.RaceProviders) != 0 {
+		t.Errorf("expected no race providers, got %v", cfg.RaceProviders)
+	}
+	if len(cfg.ConsensusProviders) != 0 {
+		t.Errorf("expected no consensus providers, got %v", cfg.ConsensusProviders)
+	}
+	if cfg.CriticReviewer != "" {
+		t.Errorf("expected no critic reviewer, got %s", cfg.CriticReviewer)
+	}
+	if len(cfg.Models) != 0 {
+		t.Errorf("expected no default models, got %v", cfg.Models)
+	}
+	if len(cfg.AllowedModels) != 0 {
+		t.Errorf("expected no model allowlists, got %v", cfg.AllowedModels)
+	}
+	if len(cfg.AllowedDatabases) != 0 {
+		t.Errorf("expected no allowed databases, got %v", cfg.AllowedDatabases)
+	}
+	if cfg.ExamplesFile != "" {
+		t.Errorf("expected empty examples file, got %s", cfg.ExamplesFile)
+	}
+	if cfg.ExamplesBudget != 1000 {
+		t.Errorf("expected default examples budget 1000, got %d", cfg.ExamplesBudget)
+	}
+	if cfg.SemanticFile != "" {
+		t.Errorf("expected empty semantic file, got %s", cfg.SemanticFile)
+	}
+	if cfg.SchemaBudget != 0 {
+		t.Errorf("expected no schema budget, got %d", cfg.SchemaBudget)
+	}
+	if cfg.SchemaMaxTables != 0 {
+		t.Errorf("expected no schema table limit, got %d", cfg.SchemaMaxTables)
+	}
+	if cfg.Dates != "literal" {
+		t.Errorf("expected literal dates, got %s", cfg.Dates)
+	}
+	if cfg.NormalizeDDL {
+		t.Error("expected DDL normalization to be disabled")
+	}
+	if cfg.SessionTTL != 30*time.Minute {
+		t.Errorf("expected session TTL 30m, got %s", cfg.SessionTTL)
+	}
+	if cfg.SessionMax != 1000 || cfg.SessionMaxBytes != 64<<20 {
+		t.Errorf("expected at most 1000 sessions of 64 MiB, got %d of %d bytes", cfg.SessionMax, cfg.SessionMaxBytes)
+	}
+	if cfg.CorrectionRounds != 0 {
+		t.Errorf("expected schema validation to be disabled, got %d correction rounds", cfg.CorrectionRounds)
+	}
 }
 
 func TestLoad_CustomPort(t *testing.T) {
@@ -114,6 +229,17 @@ func TestLoad_CustomDatabase(t *testing.T) {
 	}
 }
 
+func TestLoad_AllowedDatabases(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_ALLOWED_DATABASES", "PostgreSQL 16, ,MySQL")
+	defer os.Unsetenv("TEXT_TO_SQL_PROXY_ALLOWED_DATABASES")
+
+	cfg := Load()
+
+	if got := strings.Join(cfg.AllowedDatabases, ","); got != "PostgreSQL 16,MySQL" {
+		t.Errorf("expected allowed databases PostgreSQL 16,MySQL, got %s", got)
+	}
+}
+
 func TestLoad_AllCustomValues(t *testing.T) {
 	os.Setenv("TEXT_TO_SQL_PROXY_PORT", "3000")
 	os.Setenv("TEXT_TO_SQL_PROXY_ALLOWED_ORIGIN", "https://myapp.com")
@@ -183,3 +309,373 @@ func TestTLSEnabled_BothSet(t *testing.T) {
 		t.Error("TLS should be enabled when both cert and key are set")
 	}
 }
+
+func TestLoad_AnthropicConfig(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_ANTHROPIC_API_KEY", "sk-ant-test")
+	os.Setenv("TEXT_TO_SQL_PROXY_ANTHROPIC_BASE_URL", "http://localhost:9999")
+	os.Setenv("TEXT_TO_SQL_PROXY_ANTHROPIC_MODEL", "claude-haiku-4-5")
+	defer func() {
+		os.Unsetenv("TEXT_TO_SQL_PROXY_ANTHROPIC_API_KEY")
+		os.Unsetenv("TEXT_TO_SQL_PROXY_ANTHROPIC_BASE_URL")
+		os.Unsetenv("TEXT_TO_SQL_PROXY_ANTHROPIC_MODEL")
+	}()
+
+	cfg := Load()
+
+	if cfg.AnthropicAPIKey != "sk-ant-test" {
+		t.Errorf("expected Anthropic API key sk-ant-test, got %s", cfg.AnthropicAPIKey)
+	}
+	if cfg.AnthropicBaseURL != "http://localhost:9999" {
+		t.Errorf("expected Anthropic base URL http://localhost:9999, got %s", cfg.AnthropicBaseURL)
+	}
+	if cfg.AnthropicModel != "claude-haiku-4-5" {
+		t.Errorf("expected Anthropic model claude-haiku-4-5, got %s", cfg.AnthropicModel)
+	}
+}
+
+func TestLoad_OpenAIConfig(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_OPENAI_BASE_URL", "http://localhost:11434/v1")
+	os.Setenv("TEXT_TO_SQL_PROXY_OPENAI_API_KEY", "secret")
+	os.Setenv("TEXT_TO_SQL_PROXY_OPENAI_MODEL", "qwen2.5-coder")
+	os.Setenv("TEXT_TO_SQL_PROXY_OPENAI_RESPONSE_FORMAT", "json_object")
+	defer func() {
+		os.Unsetenv("TEXT_TO_SQL_PROXY_OPENAI_BASE_URL")
+		os.Unsetenv("TEXT_TO_SQL_PROXY_OPENAI_API_KEY")
+		os.Unsetenv("TEXT_TO_SQL_PROXY_OPENAI_MODEL")
+		os.Unsetenv("TEXT_TO_SQL_PROXY_OPENAI_RESPONSE_FORMAT")
+	}()
+
+	cfg := Load()
+
+	if cfg.OpenAIBaseURL != "http://localhost:11434/v1" {
+		t.Errorf("expected OpenAI base URL http://localhost:11434/v1, got %s", cfg.OpenAIBaseURL)
+	}
+	if cfg.OpenAIAPIKey != "secret" {
+		t.Errorf("expected OpenAI API key secret, got %s", cfg.OpenAIAPIKey)
+	}
+	if cfg.OpenAIModel != "qwen2.5-coder" {
+		t.Errorf("expected OpenAI model qwen2.5-coder, got %s", cfg.OpenAIModel)
+	}
+	if cfg.OpenAIResponseFormat != "json_object" {
+		t.Errorf("expected OpenAI response format json_object, got %s", cfg.OpenAIResponseFormat)
+	}
+}
+
+func TestLoad_InvalidOpenAIResponseFormat(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_OPENAI_RESPONSE_FORMAT", "yaml")
+	defer os.Unsetenv("TEXT_TO_SQL_PROXY_OPENAI_RESPONSE_FORMAT")
+
+	cfg := Load()
+
+	if cfg.OpenAIResponseFormat != "json_schema" {
+		t.Errorf("expected fallback to json_schema, got %s", cfg.OpenAIResponseFormat)
+	}
+}
+
+func TestLoad_CommandsFile(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_COMMANDS_FILE", "/etc/text-to-sql-proxy/commands.json")
+	defer os.Unsetenv("TEXT_TO_SQL_PROXY_COMMANDS_FILE")
+
+	cfg := Load()
+
+	if cfg.CommandsFile != "/etc/text-to-sql-proxy/commands.json" {
+		t.Errorf("expected commands file /etc/text-to-sql-proxy/commands.json, got %s", cfg.CommandsFile)
+	}
+}
+
+func TestLoad_PromptsDir(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_PROMPTS_DIR", "/etc/text-to-sql-proxy/prompts")
+	defer os.Unsetenv("TEXT_TO_SQL_PROXY_PROMPTS_DIR")
+
+	cfg := Load()
+
+	if cfg.PromptsDir != "/etc/text-to-sql-proxy/prompts" {
+		t.Errorf("expected prompts dir /etc/text-to-sql-proxy/prompts, got %s", cfg.PromptsDir)
+	}
+}
+
+func TestLoad_Examples(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_EXAMPLES_FILE", "/etc/text-to-sql-proxy/examples.jsonl")
+	os.Setenv("TEXT_TO_SQL_PROXY_EXAMPLES_BUDGET", "250")
+	defer func() {
+		os.Unsetenv("TEXT_TO_SQL_PROXY_EXAMPLES_FILE")
+		os.Unsetenv("TEXT_TO_SQL_PROXY_EXAMPLES_BUDGET")
+	}()
+
+	cfg := Load()
+
+	if cfg.ExamplesFile != "/etc/text-to-sql-proxy/examples.jsonl" {
+		t.Errorf("expected examples file /etc/text-to-sql-proxy/examples.jsonl, got %s", cfg.ExamplesFile)
+	}
+	if cfg.ExamplesBudget != 250 {
+		t.Errorf("expected examples budget 250, got %d", cfg.ExamplesBudget)
+	}
+
+	os.Setenv("TEXT_TO_SQL_PROXY_EXAMPLES_BUDGET", "-1")
+	if cfg := Load(); cfg.ExamplesBudget != 1000 {
+		t.Errorf("expected default budget for a negative value, got %d", cfg.ExamplesBudget)
+	}
+}
+
+func TestLoad_SchemaPruning(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_SCHEMA_BUDGET", "8000")
+	os.Setenv("TEXT_TO_SQL_PROXY_SCHEMA_MAX_TABLES", "25")
+	defer func() {
+		os.Unsetenv("TEXT_TO_SQL_PROXY_SCHEMA_BUDGET")
+		os.Unsetenv("TEXT_TO_SQL_PROXY_SCHEMA_MAX_TABLES")
+	}()
+
+	cfg := Load()
+
+	if cfg.SchemaBudget != 8000 {
+		t.Errorf("expected schema budget 8000, got %d", cfg.SchemaBudget)
+	}
+	if cfg.SchemaMaxTables != 25 {
+		t.Errorf("expected 25 schema tables, got %d", cfg.SchemaMaxTables)
+	}
+}
+
+func TestLoad_SemanticFile(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_SEMANTIC_FILE", "/etc/text-to-sql-proxy/semantic.json")
+	defer os.Unsetenv("TEXT_TO_SQL_PROXY_SEMANTIC_FILE")
+
+	cfg := Load()
+
+	if cfg.SemanticFile != "/etc/text-to-sql-proxy/semantic.json" {
+		t.Errorf("expected semantic file /etc/text-to-sql-proxy/semantic.json, got %s", cfg.SemanticFile)
+	}
+}
+
+func TestLoad_NormalizeDDL(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_NORMALIZE_DDL", "true")
+	defer os.Unsetenv("TEXT_TO_SQL_PROXY_NORMALIZE_DDL")
+
+	if cfg := Load(); !cfg.NormalizeDDL {
+		t.Error("expected DDL normalization to be enabled")
+	}
+}
+
+func TestLoad_SessionTTL(t *testing.T) {
+	tests := map[string]time.Duration{
+		"1h":    time.Hour,
+		"0":     0,
+		"-5m":   30 * time.Minute,
+		"later": 30 * time.Minute,
+	}
+
+	for value, expected := range tests {
+		t.Run(value, func(t *testing.T) {
+			os.Setenv("TEXT_TO_SQL_PROXY_SESSION_TTL", value)
+			defer os.Unsetenv("TEXT_TO_SQL_PROXY_SESSION_TTL")
+
+			if cfg := Load(); cfg.SessionTTL != expected {
+				t.Errorf("expected session TTL %s, got %s", expected, cfg.SessionTTL)
+			}
+		})
+	}
+}
+
+func TestLoad_SessionLimits(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_SESSION_MAX", "50")
+	os.Setenv("TEXT_TO_SQL_PROXY_SESSION_MAX_BYTES", "-1")
+	defer os.Unsetenv("TEXT_TO_SQL_PROXY_SESSION_MAX")
+	defer os.Unsetenv("TEXT_TO_SQL_PROXY_SESSION_MAX_BYTES")
+
+	cfg := Load()
+	if cfg.SessionMax != 50 {
+		t.Errorf("expected at most 50 sessions, got %d", cfg.SessionMax)
+	}
+	if cfg.SessionMaxBytes != 64<<20 {
+		t.Errorf("expected the default byte limit for a negative value, got %d", cfg.SessionMaxBytes)
+	}
+}
+
+func TestLoad_CorrectionRounds(t *testing.T) {
+	tests := map[string]int{
+		"5":    5,
+		"0":    0,
+		"-1":   0,
+		"many": 0,
+	}
+
+	for value, expected := range tests {
+		t.Run(value, func(t *testing.T) {
+			os.Setenv("TEXT_TO_SQL_PROXY_CORRECTION_ROUNDS", value)
+			defer os.Unsetenv("TEXT_TO_SQL_PROXY_CORRECTION_ROUNDS")
+
+			if cfg := Load(); cfg.CorrectionRounds != expected {
+				t.Errorf("expected %d correction rounds, got %d", expected, cfg.CorrectionRounds)
+			}
+		})
+	}
+}
+
+func TestLoad_Dates(t *testing.T) {
+	tests := map[string]string{
+		"functions": "functions",
+		"literal":   "literal",
+		"relative":  "literal",
+	}
+
+	for value, expected := range tests {
+		t.Run(value, func(t *testing.T) {
+			os.Setenv("TEXT_TO_SQL_PROXY_DATES", value)
+			defer os.Unsetenv("TEXT_TO_SQL_PROXY_DATES")
+
+			if cfg := Load(); cfg.Dates != expected {
+				t.Errorf("expected %s dates, got %s", expected, cfg.Dates)
+			}
+		})
+	}
+}
+
+func TestLoad_ProbeConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		interval  string
+		authProbe string
+		expected  time.Duration
+		auth      bool
+	}{
+		{"custom", "30s", "true", 30 * time.Second, true},
+		{"disabled", "0", "false", 0, false},
+		{"invalid", "soon", "maybe", 5 * time.Minute, false},
+		{"negative", "-1m", "", 5 * time.Minute, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Setenv("TEXT_TO_SQL_PROXY_PROBE_INTERVAL", tc.interval)
+			os.Setenv("TEXT_TO_SQL_PROXY_AUTH_PROBE", tc.authProbe)
+			defer func() {
+				os.Unsetenv("TEXT_TO_SQL_PROXY_PROBE_INTERVAL")
+				os.Unsetenv("TEXT_TO_SQL_PROXY_AUTH_PROBE")
+			}()
+
+			cfg := Load()
+
+			if cfg.ProbeInterval != tc.expected {
+				t.Errorf("expected probe interval %s, got %s", tc.expected, cfg.ProbeInterval)
+			}
+			if cfg.AuthProbe != tc.auth {
+				t.Errorf("expected auth probe %v, got %v", tc.auth, cfg.AuthProbe)
+			}
+		})
+	}
+}
+
+func TestLoad_FallbackChains(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_FALLBACK_CHAINS", "claude -> codex -> gemini; anthropic->openai; lonely")
+	os.Setenv("TEXT_TO_SQL_PROXY_FALLBACK_TIMEOUT", "20s")
+	defer func() {
+		os.Unsetenv("TEXT_TO_SQL_PROXY_FALLBACK_CHAINS")
+		os.Unsetenv("TEXT_TO_SQL_PROXY_FALLBACK_TIMEOUT")
+	}()
+
+	cfg := Load()
+
+	if len(cfg.FallbackChains) != 2 {
+		t.Fatalf("expected 2 chains, got %v", cfg.FallbackChains)
+	}
+	if got := strings.Join(cfg.FallbackChains["claude"], ","); got != "codex,gemini" {
+		t.Errorf("expected claude -> codex,gemini, got %s", got)
+	}
+	if got := strings.Join(cfg.FallbackChains["anthropic"], ","); got != "openai" {
+		t.Errorf("expected anthropic -> openai, got %s", got)
+	}
+	if cfg.FallbackTimeout != 20*time.Second {
+		t.Errorf("expected fallback timeout 20s, got %s", cfg.FallbackTimeout)
+	}
+}
+
+func TestLoad_RaceStrategy(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_STRATEGY", "race")
+	os.Setenv("TEXT_TO_SQL_PROXY_RACE_PROVIDERS", "claude, codex,,gemini")
+	defer func() {
+		os.Unsetenv("TEXT_TO_SQL_PROXY_STRATEGY")
+		os.Unsetenv("TEXT_TO_SQL_PROXY_RACE_PROVIDERS")
+	}()
+
+	cfg := Load()
+
+	if cfg.Strategy != "race" {
+		t.Errorf("expected strategy race, got %s", cfg.Strategy)
+	}
+	if got := strings.Join(cfg.RaceProviders, ","); got != "claude,codex,gemini" {
+		t.Errorf("expected race providers claude,codex,gemini, got %s", got)
+	}
+}
+
+func TestLoad_InvalidStrategy(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_STRATEGY", "vote")
+	defer os.Unsetenv("TEXT_TO_SQL_PROXY_STRATEGY")
+
+	cfg := Load()
+
+	if cfg.Strategy != "fallback" {
+		t.Errorf("expected fallback to fallback strategy, got %s", cfg.Strategy)
+	}
+}
+
+func TestLoad_ConsensusStrategy(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_STRATEGY", "consensus")
+	os.Setenv("TEXT_TO_SQL_PROXY_CONSENSUS_PROVIDERS", "claude,gemini,codex")
+	defer func() {
+		os.Unsetenv("TEXT_TO_SQL_PROXY_STRATEGY")
+		os.Unsetenv("TEXT_TO_SQL_PROXY_CONSENSUS_PROVIDERS")
+	}()
+
+	cfg := Load()
+
+	if cfg.Strategy != "consensus" {
+		t.Errorf("expected strategy consensus, got %s", cfg.Strategy)
+	}
+	if got := strings.Join(cfg.ConsensusProviders, ","); got != "claude,gemini,codex" {
+		t.Errorf("expected consensus providers claude,gemini,codex, got %s", got)
+	}
+}
+
+func TestLoad_CriticStrategy(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_STRATEGY", "critic")
+	os.Setenv("TEXT_TO_SQL_PROXY_CRITIC_REVIEWER", " claude ")
+	defer func() {
+		os.Unsetenv("TEXT_TO_SQL_PROXY_STRATEGY")
+		os.Unsetenv("TEXT_TO_SQL_PROXY_CRITIC_REVIEWER")
+	}()
+
+	cfg := Load()
+
+	if cfg.Strategy != "critic" {
+		t.Errorf("expected strategy critic, got %s", cfg.Strategy)
+	}
+	if cfg.CriticReviewer != "claude" {
+		t.Errorf("expected critic reviewer claude, got %q", cfg.CriticReviewer)
+	}
+}
+
+func TestLoad_Models(t *testing.T) {
+	os.Setenv("TEXT_TO_SQL_PROXY_MODELS", "claude = sonnet; codex=gpt-5-codex; =orphan; gemini=")
+	os.Setenv("TEXT_TO_SQL_PROXY_ALLOWED_MODELS", "claude=haiku, sonnet,opus;broken")
+	defer func() {
+		os.Unsetenv("TEXT_TO_SQL_PROXY_MODELS")
+		os.Unsetenv("TEXT_TO_SQL_PROXY_ALLOWED_MODELS")
+	}()
+
+	cfg := Load()
+
+	if len(cfg.Models) != 2 {
+		t.Fatalf("expected 2 default models, got %v", cfg.Models)
+	}
+	if cfg.Models["claude"] != "sonnet" {
+		t.Errorf("expected claude model sonnet, got %s", cfg.Models["claude"])
+	}
+	if cfg.Models["codex"] != "gpt-5-codex" {
+		t.Errorf("expected codex model gpt-5-codex, got %s", cfg.Models["codex"])
+	}
+	if len(cfg.AllowedModels) != 1 {
+		t.Fatalf("expected 1 allowlist, got %v", cfg.AllowedModels)
+	}
+	if got := strings.Join(cfg.AllowedModels["claude"], ","); got != "haiku,sonnet,opus" {
+		t.Errorf("expected claude models haiku,sonnet,opus, got %s", got)
+	}
+}
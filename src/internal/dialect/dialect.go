@@ -0,0 +1,338 @@
+// Package dialect describes the SQL dialects the proxy generates for: their
+// version, quoting rules, supported functions and the features models tend
+// to use although the target does not have them.
+package dialect
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Profile describes a target database and version.
+type Profile struct {
+	// Name identifies the profile, e.g. "postgresql-12". Engine is the
+	// database without version, e.g. "postgresql".
+	Name   string
+	Engine string
+	// Title is the human-readable name used in prompts, e.g. "PostgreSQL 12".
+	Title   string
+	Version string
+	// IdentifierQuote is the character that quotes identifiers.
+	IdentifierQuote string
+	// Functions lists supported functions and syntax worth pointing out.
+	Functions []string
+	// Do and Dont are hints injected into the prompt.
+	Do   []string
+	Dont []string
+	// Forbidden flags features the target does not support.
+	Forbidden []Rule
+}
+
+// Rule matches a feature in generated SQL. Patterns are matched against the
+// SQL with string literals and comments removed.
+type Rule struct {
+	Pattern *regexp.Regexp
+	Message string
+}
+
+// rule compiles a case-insensitive Rule.
+func rule(pattern, message string) Rule {
+	return Rule{Pattern: regexp.MustCompile(`(?i)` + pattern), Message: message}
+}
+
+// Rules shared by several profiles.
+var (
+	noQualify       = rule(`\bQUALIFY\b`, "QUALIFY is not supported; filter window function results in a subquery or CTE")
+	noILike         = rule(`\bILIKE\b`, "ILIKE is not supported; compare LOWER(...) with LIKE")
+	noCastOperator  = rule(`::`, "the :: cast operator is not supported; use CAST(... AS ...)")
+	noDateTrunc     = rule(`\bDATE_TRUNC\s*\(`, "DATE_TRUNC is not available")
+	noDistinctOn    = rule(`\bDISTINCT\s+ON\b`, "DISTINCT ON is not supported; use ROW_NUMBER() in a subquery")
+	noGroupByAll    = rule(`\bGROUP\s+BY\s+ALL\b`, "GROUP BY ALL is not supported; list the grouping columns")
+	noSelectExclude = rule(`\*\s*EXCLUDE\b`, "SELECT * EXCLUDE is not supported; list the columns")
+	noFullJoin      = rule(`\bFULL\s+(OUTER\s+)?JOIN\b`, "FULL JOIN is not supported; combine LEFT and RIGHT joins with UNION")
+	noIfNull        = rule(`\bIFNULL\s*\(`, "IFNULL is not available; use COALESCE")
+	noDateAdd       = rule(`\bDATEADD\s*\(|\bDATEDIFF\s*\(`, "DATEADD/DATEDIFF are not available; use interval arithmetic")
+)
+
+// profiles are the built-in dialect profiles.
+var profiles = []*Profile{
+	{
+		Name:            "duckdb",
+		Engine:          "duckdb",
+		Title:           "DuckDB",
+		Version:         "1.x",
+		IdentifierQuote: `"`,
+		Functions: []string{
+			"date_trunc", "date_diff", "strftime", "strptime", "make_date", "epoch",
+			"string_agg", "list", "unnest", "regexp_matches", "struct_pack",
+			"QUALIFY", "GROUP BY ALL", "SELECT * EXCLUDE", "PIVOT", "ASOF JOIN",
+		},
+		Do: []string{
+			"Use QUALIFY to filter window function results",
+			"Use GROUP BY ALL when grouping by every non-aggregated column",
+		},
+		Dont: []string{
+			"Don't quote identifiers with backticks",
+		},
+	},
+	{
+		Name:            "postgresql-12",
+		Engine:          "postgresql",
+		Title:           "PostgreSQL 12",
+		Version:         "12",
+		IdentifierQuote: `"`,
+		Functions: []string{
+			"date_trunc", "to_char", "extract", "age", "generate_series", "string_agg",
+			"array_agg", "jsonb_extract_path_text", "->>", "FILTER (WHERE ...)", "DISTINCT ON", "ILIKE",
+		},
+		Do: []string{
+			"Use interval arithmetic such as now() - interval '7 days'",
+			"Use FILTER (WHERE ...) for conditional aggregates",
+		},
+		Dont: []string{
+			"Don't use QUALIFY, GROUP BY ALL or SELECT * EXCLUDE",
+			"Don't use MERGE or ANY_VALUE, which require PostgreSQL 15 and 16",
+			"Don't quote identifiers with backticks",
+		},
+		Forbidden: []Rule{
+			noQualify, noGroupByAll, noSelectExclude, noIfNull, noDateAdd,
+			rule(`\bMERGE\s+INTO\b`, "MERGE requires PostgreSQL 15"),
+			rule(`\bANY_VALUE\s*\(`, "ANY_VALUE requires PostgreSQL 16"),
+		},
+	},
+	{
+		Name:            "postgresql-16",
+		Engine:          "postgresql",
+		Title:           "PostgreSQL 16",
+		Version:         "16",
+		IdentifierQuote: `"`,
+		Functions: []string{
+			"date_trunc", "to_char", "extract", "age", "generate_series", "string_agg",
+			"array_agg", "any_value", "jsonb_path_query", "->>", "FILTER (WHERE ...)", "DISTINCT ON", "ILIKE", "MERGE",
+		},
+		Do: []string{
+			"Use interval arithmetic such as now() - interval '7 days'",
+			"Use FILTER (WHERE ...) for conditional aggregates",
+		},
+		Dont: []string{
+			"Don't use QUALIFY, GROUP BY ALL or SELECT * EXCLUDE",
+			"Don't quote identifiers with backticks",
+		},
+		Forbidden: []Rule{noQualify, noGroupByAll, noSelectExclude, noIfNull, noDateAdd},
+	},
+	{
+		Name:            "sqlite",
+		Engine:          "sqlite",
+		Title:           "SQLite 3",
+		Version:         "3",
+		IdentifierQuote: `"`,
+		Functions: []string{
+			"date", "datetime", "strftime", "julianday", "group_concat", "json_extract",
+			"iif", "coalesce", "printf", "window functions",
+		},
+		Do: []string{
+			"Use strftime('%Y-%m', col) to truncate dates",
+			"Use date('now', '-7 days') for relative dates",
+		},
+		Dont: []string{
+			"Don't use DATE_TRUNC, EXTRACT, INTERVAL or :: casts",
+			"Don't use QUALIFY or ILIKE",
+		},
+		Forbidden: []Rule{
+			noQualify, noILike, noCastOperator, noDateTrunc, noDistinctOn,
+			rule(`\bEXTRACT\s*\(`, "EXTRACT is not available; use strftime"),
+			rule(`\bINTERVAL\b`, "INTERVAL is not supported; use date modifiers such as date(col, '-7 days')"),
+		},
+	},
+	{
+		Name:            "mysql-8",
+		Engine:          "mysql",
+		Title:           "MySQL 8",
+		Version:         "8.0",
+		IdentifierQuote: "`",
+		Functions: []string{
+			"DATE_FORMAT", "DATE_ADD", "DATE_SUB", "TIMESTAMPDIFF", "GROUP_CONCAT",
+			"IFNULL", "JSON_EXTRACT", "->>", "window functions", "WITH (CTEs)",
+		},
+		Do: []string{
+			"Quote identifiers with backticks when needed",
+			"Use DATE_FORMAT(col, '%Y-%m-01') to truncate dates to months",
+		},
+		Dont: []string{
+			"Don't use DATE_TRUNC, :: casts, ILIKE, QUALIFY, FILTER (WHERE ...) or FULL JOIN",
+			"Don't quote identifiers with double quotes",
+		},
+		Forbidden: []Rule{
+			noQualify, noILike, noCastOperator, noDateTrunc, noDistinctOn, noFullJoin,
+			rule(`\bFILTER\s*\(\s*WHERE\b`, "FILTER (WHERE ...) is not supported; use SUM(CASE WHEN ...)"),
+			rule(`\bNULLS\s+(FIRST|LAST)\b`, "NULLS FIRST/LAST is not supported; order by col IS NULL first"),
+		},
+	},
+	{
+		Name:            "snowflake",
+		Engine:          "snowflake",
+		Title:           "Snowflake",
+		IdentifierQuote: `"`,
+		Functions: []string{
+			"DATE_TRUNC", "DATEADD", "DATEDIFF", "IFF", "LISTAGG", "ARRAY_AGG",
+			"FLATTEN", "TRY_CAST", "QUALIFY", "ILIKE", "::",
+		},
+		Do: []string{
+			"Use QUALIFY to filter window function results",
+			"Use DATEADD(day, -7, CURRENT_DATE()) for relative dates",
+		},
+		Dont: []string{
+			"Don't use DISTINCT ON or generate_series",
+			"Don't quote identifiers with backticks",
+		},
+		Forbidden: []Rule{
+			noDistinctOn,
+			rule(`\bGENERATE_SERIES\s*\(`, "generate_series is not available; use GENERATOR with ROW_NUMBER()"),
+		},
+	},
+	{
+		Name:            "bigquery",
+		Engine:          "bigquery",
+		Title:           "BigQuery (GoogleSQL)",
+		IdentifierQuote: "`",
+		Functions: []string{
+			"DATE_TRUNC(date, MONTH)", "DATE_SUB(date, INTERVAL 1 DAY)", "FORMAT_DATE", "SAFE_CAST",
+			"SAFE_DIVIDE", "COUNTIF", "IFNULL", "ARRAY_AGG", "STRING_AGG", "UNNEST", "QUALIFY",
+		},
+		Do: []string{
+			"Quote project.dataset.table paths with backticks",
+			"Use SAFE_DIVIDE to avoid division by zero",
+		},
+		Dont: []string{
+			"Don't use :: casts, ILIKE or DISTINCT ON",
+			"Don't quote identifiers with double quotes",
+		},
+		Forbidden: []Rule{
+			noILike, noCastOperator, noDistinctOn,
+			rule(`\bDATE_TRUNC\s*\(\s*'`, "DATE_TRUNC takes the date first and an unquoted part, e.g. DATE_TRUNC(d, MONTH)"),
+		},
+	},
+}
+
+// aliases maps normalized database names to profiles. Engines without a
+// version map to their newest profile, which comes last in profiles.
+var aliases = map[string]*Profile{}
+
+func init() {
+	for _, p := range profiles {
+		aliases[normalizeName(p.Name)] = p
+		aliases[normalizeName(p.Title)] = p
+		aliases[normalizeName(p.Engine)] = p
+	}
+	for alias, name := range map[string]string{
+		"postgres":   "postgresql-16",
+		"postgres12": "postgresql-12",
+		"postgres16": "postgresql-16",
+		"pg":         "postgresql-16",
+		"sqlite3":    "sqlite",
+		"mysql8":     "mysql-8",
+		"googlesql":  "bigquery",
+	} {
+		aliases[alias] = Lookup(name)
+	}
+}
+
+// normalizeName lower-cases a database name and drops separators, so that
+// "PostgreSQL 12", "postgresql-12" and "postgresql12" are equal.
+func normalizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '_', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.ToLower(name))
+}
+
+// Lookup returns the profile for a database name such as "DuckDB",
+// "PostgreSQL 12" or "mysql", or nil if there is none. Unknown databases
+// are still usable; they are only named in the prompt.
+func Lookup(name string) *Profile {
+	return aliases[normalizeName(name)]
+}
+
+// Names returns the names of all built-in profiles.
+func Names() []string {
+	names := make([]string, 0, len(profiles))
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check returns a message for every forbidden feature found in sql. Call it
+// on cleaned SQL.
+func (p *Profile) Check(sql string) []string {
+	code := stripLiterals(sql)
+
+	var violations []string
+	for _, r := range p.Forbidden {
+		if r.Pattern.MatchString(code) {
+			violations = append(violations, r.Message)
+		}
+	}
+	return violations
+}
+
+// Clean removes backticks from SQL for dialects that do not quote with
+// them, where models trained on MySQL tend to add them anyway. A nil
+// profile is treated as such a dialect.
+func (p *Profile) Clean(sql string) string {
+	if p != nil && p.IdentifierQuote == "`" {
+		return sql
+	}
+	return strings.ReplaceAll(sql, "`", "")
+}
+
+// stripLiterals blanks out string literals and comments so that rules only
+// match SQL syntax.
+func stripLiterals(sql string) string {
+	var b strings.Builder
+	runes := []rune(sql)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\'':
+			i++
+			for i < len(runes) {
+				if runes[i] == '\'' {
+					// A doubled quote is an escaped quote
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			b.WriteString("''")
+
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			b.WriteByte('\n')
+
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				i++
+			}
+			i++
+			b.WriteByte(' ')
+
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
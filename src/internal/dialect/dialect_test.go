@@ -0,0 +1,110 @@
+package dialect
+
+import (
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"DuckDB", "duckdb"},
+		{"PostgreSQL", "postgresql-16"},
+		{"postgres", "postgresql-16"},
+		{"PostgreSQL 12", "postgresql-12"},
+		{"postgresql-12", "postgresql-12"},
+		{"sqlite3", "sqlite"},
+		{"MySQL", "mysql-8"},
+		{"MySQL 8", "mysql-8"},
+		{"Snowflake", "snowflake"},
+		{"BigQuery", "bigquery"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := Lookup(tc.name)
+			if p == nil {
+				t.Fatalf("expected profile %s, got none", tc.expected)
+			}
+			if p.Name != tc.expected {
+				t.Errorf("expected profile %s, got %s", tc.expected, p.Name)
+			}
+		})
+	}
+
+	if p := Lookup("Oracle"); p != nil {
+		t.Errorf("expected no profile for Oracle, got %s", p.Name)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		database   string
+		sql        string
+		violations int
+	}{
+		{
+			name:       "qualify on postgres",
+			database:   "PostgreSQL 16",
+			sql:        "SELECT * FROM t QUALIFY ROW_NUMBER() OVER (PARTITION BY a ORDER BY b) = 1",
+			violations: 1,
+		},
+		{
+			name:       "qualify on duckdb",
+			database:   "DuckDB",
+			sql:        "SELECT * FROM t QUALIFY ROW_NUMBER() OVER (PARTITION BY a ORDER BY b) = 1",
+			violations: 0,
+		},
+		{
+			name:       "any_value needs postgres 16",
+			database:   "PostgreSQL 12",
+			sql:        "SELECT a, ANY_VALUE(b) FROM t GROUP BY a",
+			violations: 1,
+		},
+		{
+			name:       "any_value on postgres 16",
+			database:   "PostgreSQL 16",
+			sql:        "SELECT a, ANY_VALUE(b) FROM t GROUP BY a",
+			violations: 0,
+		},
+		{
+			name:       "keywords in literals and comments",
+			database:   "MySQL",
+			sql:        "SELECT 'qualify ilike ::' AS note -- QUALIFY\nFROM t /* ILIKE */",
+			violations: 0,
+		},
+		{
+			name:       "several violations",
+			database:   "SQLite",
+			sql:        "SELECT DATE_TRUNC('month', ts)::date FROM t WHERE name ILIKE 'a%'",
+			violations: 3,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			violations := Lookup(tc.database).Check(tc.sql)
+			if len(violations) != tc.violations {
+				t.Errorf("expected %d violations, got %v", tc.violations, violations)
+			}
+		})
+	}
+}
+
+func TestClean(t *testing.T) {
+	sql := "SELECT COUNT(*) FROM `aws_iam`.actions"
+
+	if got := Lookup("DuckDB").Clean(sql); got != "SELECT COUNT(*) FROM aws_iam.actions" {
+		t.Errorf("expected backticks removed for DuckDB, got %q", got)
+	}
+	if got := Lookup("MySQL").Clean(sql); got != sql {
+		t.Errorf("expected backticks kept for MySQL, got %q", got)
+	}
+
+	var unknown *Profile
+	if got := unknown.Clean(sql); got != "SELECT COUNT(*) FROM aws_iam.actions" {
+		t.Errorf("expected backticks removed without a profile, got %q", got)
+	}
+}
@@ -0,0 +1,190 @@
+// Package examples selects few-shot question/SQL examples for a request
+// from an operator-maintained library and the examples a request brings
+// along.
+package examples
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/dialect"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/schema"
+)
+
+// Example is a question answered with the SQL the model should learn from.
+type Example struct {
+	Question string `json:"question"`
+	SQL      string `json:"sql"`
+	// Database scopes the example to a target database. Databases with a
+	// dialect profile match every version of their engine.
+	Database string `json:"database,omitempty"`
+	// Tables scopes the example to schemas whose DDL creates all of the
+	// listed tables.
+	Tables []string `json:"tables,omitempty"`
+}
+
+// Tokens estimates the prompt tokens the example takes.
+func (e Example) Tokens() int {
+	return schema.Tokens(e.Question + e.SQL)
+}
+
+// Library holds the operator's examples. A nil Library has no examples.
+type Library struct {
+	examples []Example
+}
+
+// Load reads a JSONL file with one example per line. Blank lines and lines
+// starting with # are skipped. Files without a .jsonl extension, such as
+// YAML files, are rejected.
+func Load(path string) (*Library, error) {
+	if !strings.EqualFold(filepath.Ext(path), ".jsonl") {
+		return nil, fmt.Errorf("%s: only JSONL example files (.jsonl) are supported", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lib := &Library{}
+	for i, text := range strings.Split(string(data), "\n") {
+		line := i + 1
+		text = strings.TrimSpace(text)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var example Example
+		if err := json.Unmarshal([]byte(text), &example); err != nil {
+			return nil, fmt.Errorf("parse %s:%d: %w", path, line, err)
+		}
+		if example.Question == "" || example.SQL == "" {
+			return nil, fmt.Errorf("parse %s:%d: example needs a question and sql", path, line)
+		}
+		lib.examples = append(lib.examples, example)
+	}
+	return lib, nil
+}
+
+// Len returns the number of examples in the library.
+func (l *Library) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.examples)
+}
+
+// Select returns the examples to include in a prompt: the inline examples
+// in the given order, then the library examples in scope for the database
+// and DDL that share words with the question, most similar first. Examples
+// are added while their estimated tokens fit into budget.
+func (l *Library) Select(question, ddl, database string, inline []Example, budget int) []Example {
+	var selected []Example
+	add := func(e Example) bool {
+		if e.Tokens() > budget {
+			return false
+		}
+		budget -= e.Tokens()
+		selected = append(selected, e)
+		return true
+	}
+
+	for _, e := range inline {
+		if !add(e) {
+			break
+		}
+	}
+	if l == nil {
+		return selected
+	}
+
+	type candidate struct {
+		example Example
+		score   float64
+	}
+	words := wordSet(question)
+	tables := schema.Tables(ddl)
+	var candidates []candidate
+	for _, e := range l.examples {
+		if !inScope(e, database, tables) {
+			continue
+		}
+		if score := similarity(words, wordSet(e.Question)); score > 0 {
+			candidates = append(candidates, candidate{e, score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	for _, c := range candidates {
+		add(c.example)
+	}
+	return selected
+}
+
+// inScope reports whether an example applies to the database and to a
+// schema creating tables.
+func inScope(e Example, database string, tables map[string]bool) bool {
+	if e.Database != "" && !sameDatabase(e.Database, database) {
+		return false
+	}
+	for _, table := range e.Tables {
+		if !tables[strings.ToLower(table)] {
+			return false
+		}
+	}
+	return true
+}
+
+// sameDatabase reports whether two database names refer to the same
+// database, or to versions of the same engine.
+func sameDatabase(a, b string) bool {
+	if strings.EqualFold(a, b) {
+		return true
+	}
+	pa, pb := dialect.Lookup(a), dialect.Lookup(b)
+	return pa != nil && pb != nil && pa.Engine == pb.Engine
+}
+
+var wordPattern = regexp.MustCompile(`[\pL\pN_]+`)
+
+// stopWords are left out of the similarity since nearly every question
+// contains some of them.
+var stopWords = map[string]bool{
+	"a": true, "all": true, "an": true, "and": true, "are": true, "by": true,
+	"each": true, "for": true, "from": true, "how": true, "in": true,
+	"is": true, "me": true, "many": true, "of": true, "on": true, "per": true,
+	"show": true, "the": true, "to": true, "what": true, "which": true,
+	"with": true,
+}
+
+// wordSet returns the lower-cased words of text without stop words.
+func wordSet(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range wordPattern.FindAllString(strings.ToLower(text), -1) {
+		if !stopWords[word] {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// similarity is the Jaccard index of two word sets.
+func similarity(a, b map[string]bool) float64 {
+	shared := 0
+	for word := range a {
+		if b[word] {
+			shared++
+		}
+	}
+	if shared == 0 {
+		return 0
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -0,0 +1,147 @@
+package examples
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeLibrary(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "examples.jsonl")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func questions(examples []Example) string {
+	var names []string
+	for _, e := range examples {
+		names = append(names, e.Question)
+	}
+	return strings.Join(names, "|")
+}
+
+func TestLoad(t *testing.T) {
+	path := writeLibrary(t, `# fiscal year starts in February
+{"question": "Revenue per fiscal year", "sql": "SELECT 1"}
+
+{"question": "Active customers", "sql": "SELECT 2", "database": "PostgreSQL", "tables": ["customers"]}
+`)
+
+	lib, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lib.Len() != 2 {
+		t.Fatalf("expected 2 examples, got %d", lib.Len())
+	}
+	if got := lib.examples[1].Tables; len(got) != 1 || got[0] != "customers" {
+		t.Errorf("expected tables [customers], got %v", got)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"invalid json":     "{\"question\": \"a\", \"sql\": \"SELECT 1\"}\n{not json",
+		"missing sql":      `{"question": "a"}`,
+		"missing question": `{"sql": "SELECT 1"}`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writeLibrary(t, content)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestLoad_NotJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "examples.yaml")
+	if err := os.WriteFile(path, []byte("- question: a\n  sql: SELECT 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "only JSONL example files") {
+		t.Errorf("expected a YAML file to be rejected, got %v", err)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	lib := &Library{examples: []Example{
+		{Question: "Total revenue per fiscal year", SQL: "SELECT 1"},
+		{Question: "Number of orders", SQL: "SELECT 2"},
+		{Question: "Revenue of active customers", SQL: "SELECT 3", Tables: []string{"customers"}},
+		{Question: "Revenue per fiscal quarter", SQL: "SELECT 4", Database: "MySQL"},
+		{Question: "Revenue per fiscal month", SQL: "SELECT 5", Database: "PostgreSQL"},
+	}}
+	ddl := "CREATE TABLE IF NOT EXISTS sales.\"Orders\" (id INT);"
+
+	tests := []struct {
+		name     string
+		question string
+		ddl      string
+		database string
+		expected string
+	}{
+		{
+			name:     "most similar first",
+			question: "Revenue per fiscal year",
+			ddl:      ddl,
+			database: "DuckDB",
+			expected: "Total revenue per fiscal year",
+		},
+		{
+			name:     "database scope matches the engine",
+			question: "Revenue per fiscal year",
+			ddl:      ddl,
+			database: "PostgreSQL 12",
+			expected: "Total revenue per fiscal year|Revenue per fiscal month",
+		},
+		{
+			name:     "table scope",
+			question: "Revenue of customers",
+			ddl:      ddl + "\nCREATE TABLE customers (id INT);",
+			database: "DuckDB",
+			expected: "Revenue of active customers|Total revenue per fiscal year",
+		},
+		{
+			name:     "no shared words",
+			question: "List the users",
+			ddl:      ddl,
+			database: "DuckDB",
+			expected: "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := questions(lib.Select(tc.question, tc.ddl, tc.database, nil, 1000))
+			if got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSelect_InlineAndBudget(t *testing.T) {
+	lib := &Library{examples: []Example{
+		{Question: "Revenue per fiscal year", SQL: strings.Repeat("x", 400)},
+		{Question: "Revenue per fiscal quarter", SQL: "SELECT 2"},
+	}}
+	inline := []Example{{Question: "Inline", SQL: "SELECT 0"}}
+
+	got := questions(lib.Select("Revenue per fiscal year", "", "DuckDB", inline, 50))
+	if got != "Inline|Revenue per fiscal quarter" {
+		t.Errorf("expected the inline example and what fits the budget, got %q", got)
+	}
+
+	var empty *Library
+	if got := questions(empty.Select("Revenue", "", "DuckDB", inline, 0)); got != "" {
+		t.Errorf("expected no examples without budget, got %q", got)
+	}
+}
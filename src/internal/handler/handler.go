@@ -1,12 +1,29 @@
 package handler
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/tobilg/text-to-sql-proxy/src/internal/dialect"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/examples"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/hints"
 	"github.com/tobilg/text-to-sql-proxy/src/internal/provider"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/schema"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/semantic"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/session"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/strategy"
 )
 
 // SQLRequest represents the incoming request payload.
@@ -14,43 +31,397 @@ type SQLRequest struct {
 	DDL      string `json:"ddl"`
 	Question string `json:"question"`
 	Provider string `json:"provider,omitempty"`
+	// Strategy selects how providers are used: "fallback" (default), "race",
+	// "consensus" or "critic". Providers lists the providers to race or to
+	// ask for a consensus; Samples is how often Provider is asked for a
+	// consensus when Providers is empty. Reviewer reviews the SQL Provider
+	// drafts with the critic strategy.
+	Strategy  string   `json:"strategy,omitempty"`
+	Providers []string `json:"providers,omitempty"`
+	Samples   int      `json:"samples,omitempty"`
+	Reviewer  string   `json:"reviewer,omitempty"`
+	// Model overrides the model of the requested providers. Providers only
+	// reached through a fallback chain keep their configured model, since
+	// model names are provider-specific. Effort is the reasoning effort
+	// ("low", "medium" or "high") for providers that support one.
+	Model  string `json:"model,omitempty"`
+	Effort string `json:"effort,omitempty"`
+	// Database overrides the configured target database. It must be the
+	// configured database or one of the allowed databases.
+	Database string `json:"database,omitempty"`
+	// Examples are few-shot examples for this request. They are included
+	// before any examples from the configured library.
+	Examples []examples.Example `json:"examples,omitempty"`
+	// SampleRows and ColumnValues are data from the schema's tables that
+	// are rendered next to the DDL, cut to the caps of package hints.
+	// Sample rows are not called "samples", which is the consensus count.
+	SampleRows   []hints.SampleRows   `json:"sample_rows,omitempty"`
+	ColumnValues []hints.ColumnValues `json:"column_values,omitempty"`
+	// Now is the RFC 3339 time relative dates in the question refer to,
+	// the server clock by default. Timezone is the IANA timezone the dates
+	// are in, e.g. Europe/Berlin; by default that of Now or the server.
+	Now      string `json:"now,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+	// SessionID continues the conversation of an earlier response: the
+	// question follows up on its turns, and DDL and Database default to
+	// the conversation's. Session starts a new conversation; requests with
+	// neither are not kept.
+	SessionID string `json:"session_id,omitempty"`
+	Session   bool   `json:"session,omitempty"`
+	// Clarify allows providers to answer an ambiguous question with a
+	// clarifying question instead of guessing SQL.
+	Clarify bool `json:"clarify,omitempty"`
+	// Debug adds the provider's raw output to the response.
+	Debug bool `json:"debug,omitempty"`
+
+	// conversation is the session SessionID names, rawDDL the DDL before
+	// normalizing and pruning, and search the text the schema, examples
+	// and semantic layer are matched against: the conversation's earlier
+	// questions and Question. native collects the sessions providers
+	// report, and schemaKey identifies the DDL and data hints they are
+	// given, which resumed sessions are not sent again.
+	conversation *session.Session
+	rawDDL       string
+	search       string
+	native       *nativeSessions
+	schemaKey    string
+
+	// currentTime is Now or the server clock, in Timezone.
+	currentTime time.Time
+
+	// tokensSaved are the estimated tokens normalizing the DDL removed.
+	tokensSaved int
+	// fullDDL is the normalized DDL before pruning, which generated SQL is
+	// validated against: the SQL may rightly use tables pruning dropped,
+	// e.g. those of the semantic layer or of earlier turns.
+	fullDDL string
+	// tables are the tables kept when the DDL was pruned, and joins the
+	// join paths between the tables the question mentions.
+	tables []string
+	joins  []schema.Join
+
+	// draft is the SQL a critic run's reviewer is asked to review.
+	draft string
 }
 
+// Strategies accepted in SQLRequest.Strategy.
+const (
+	StrategyFallback  = "fallback"
+	StrategyRace      = "race"
+	StrategyConsensus = "consensus"
+	StrategyCritic    = "critic"
+)
+
+// defaultSamples is how often a single provider is asked for a consensus.
+// maxConsensusRuns and maxRaceProviders bound the provider calls a
+// consensus or race request may make.
+const (
+	defaultSamples   = 3
+	maxConsensusRuns = 10
+	maxRaceProviders = 10
+)
+
+// defaultExamplesBudget is the estimated tokens of few-shot examples added
+// to a prompt unless WithExamples sets another budget.
+const defaultExamplesBudget = 1000
+
+// Response types of SQLResponse.Type.
+const (
+	ResponseSQL           = "sql"
+	ResponseClarification = "clarification"
+)
+
 // SQLResponse represents the response payload.
 type SQLResponse struct {
-	SQL   string `json:"sql,omitempty"`
-	Error string `json:"error,omitempty"`
+	// Type is ResponseSQL for generated SQL and ResponseClarification when
+	// the provider asked Question back, with Options as likely answers.
+	// Error responses have no type.
+	Type     string   `json:"type,omitempty"`
+	SQL      string   `json:"sql,omitempty"`
+	Question string   `json:"question,omitempty"`
+	Options  []string `json:"options,omitempty"`
+	Error    string   `json:"error,omitempty"`
+	// Explanation, Assumptions, Confidence and ReferencedTables are what
+	// the provider reported about the SQL; only providers with structured
+	// output report them.
+	Explanation      string   `json:"explanation,omitempty"`
+	Assumptions      []string `json:"assumptions,omitempty"`
+	Confidence       float64  `json:"confidence,omitempty"`
+	ReferencedTables []string `json:"referenced_tables,omitempty"`
+	// Model is the model the provider was asked to use and DurationMS how
+	// long it took. Raw is its unparsed output, only sent to requests with
+	// Debug set.
+	Provider   string             `json:"provider,omitempty"`
+	Model      string             `json:"model,omitempty"`
+	DurationMS int64              `json:"duration_ms,omitempty"`
+	Raw        string             `json:"raw,omitempty"`
+	Attempts   []strategy.Attempt `json:"attempts,omitempty"`
+	// Agreement and Dissent are only set by the consensus strategy and
+	// Review by the critic strategy.
+	Agreement float64            `json:"agreement,omitempty"`
+	Dissent   []strategy.Variant `json:"dissent,omitempty"`
+	Review    *strategy.Review   `json:"review,omitempty"`
+	// Warnings lists features of the SQL the target database does not
+	// support, according to its dialect profile.
+	Warnings []string `json:"warnings,omitempty"`
+	// Corrections are the provider's drafts that failed schema validation
+	// and were sent back to it, and ValidationErrors the problems that
+	// remain in SQL after the last round.
+	Corrections      []schema.Correction `json:"corrections,omitempty"`
+	ValidationErrors []string            `json:"validation_errors,omitempty"`
+	// DDLTokensSaved is the estimated tokens normalizing a schema dump
+	// removed from the DDL.
+	DDLTokensSaved int `json:"ddl_tokens_saved,omitempty"`
+	// Tables lists the tables whose DDL was sent when the schema was
+	// pruned.
+	Tables []string `json:"tables,omitempty"`
+	// Joins are the join paths added to the prompt.
+	Joins []schema.Join `json:"joins,omitempty"`
+	// Now is the current time given to the provider, to repeat the request
+	// with the same date ranges.
+	Now string `json:"now,omitempty"`
+	// SessionID names the conversation to send follow-up questions to.
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// nativeSessions collects the sessions providers report for a request,
+// keyed by provider.
+type nativeSessions struct {
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+func (n *nativeSessions) set(provider, id string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.ids[provider] = id
+}
+
+func (n *nativeSessions) get(provider string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.ids[provider]
+}
+
+// modelPattern matches the accepted values of SQLRequest.Model. Models are
+// passed to CLIs as arguments, so they must not start with a dash that
+// would make them a flag.
+var modelPattern = regexp.MustCompile(`^[A-Za-z0-9._:/@][A-Za-z0-9._:/@-]*$`)
+
+// validEfforts lists the accepted values of SQLRequest.Effort.
+var validEfforts = map[string]bool{
+	provider.EffortLow:    true,
+	provider.EffortMedium: true,
+	provider.EffortHigh:   true,
 }
 
-// ProviderInfo represents a provider with its metadata.
+// ProviderInfo represents a provider with its metadata. The availability
+// fields are only present when the handler has a StatusSource.
 type ProviderInfo struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	// DefaultModel is the configured model and Models the models requests
+	// may pick; any model is accepted when Models is empty.
+	DefaultModel   string   `json:"default_model,omitempty"`
+	Models         []string `json:"models,omitempty"`
+	SupportsEffort bool     `json:"supports_effort,omitempty"`
+	*provider.Status
 }
 
 // providerDescriptions maps provider names to human-readable descriptions.
 var providerDescriptions = map[string]string{
-	"claude":   "Claude Code",
-	"gemini":   "Google Gemini",
-	"codex":    "OpenAI Codex",
-	"continue": "Continue",
-	"opencode": "OpenCode",
+	"claude":    "Claude Code",
+	"gemini":    "Google Gemini",
+	"codex":     "OpenAI Codex",
+	"continue":  "Continue",
+	"opencode":  "OpenCode",
+	"anthropic": "Anthropic API",
+	"openai":    "OpenAI-compatible API",
+}
+
+// StatusSource reports the last known availability of providers.
+type StatusSource interface {
+	Status(name string) (provider.Status, bool)
 }
 
 // Handler holds dependencies for HTTP handlers.
 type Handler struct {
-	providers       map[string]provider.SQLGenerator
-	defaultProvider string
-	allowedOrigin   string
+	providers          map[string]provider.SQLGenerator
+	defaultProvider    string
+	allowedOrigin      string
+	status             StatusSource
+	fallbacks          map[string][]string
+	attemptTimeout     time.Duration
+	defaultStrategy    string
+	raceProviders      []string
+	consensusProviders []string
+	criticReviewer     string
+	models             map[string]string
+	allowedModels      map[string][]string
+	database           string
+	allowedDatabases   []string
+	examples           *examples.Library
+	examplesBudget     int
+	semantic           *semantic.Layer
+	schemaBudget       int
+	schemaMaxTables    int
+	normalizeDDL       bool
+	sessions           *session.Store
+	dates              string
+	correctionRounds   int
+	clock              func() time.Time
+}
+
+// Option configures optional Handler dependencies.
+type Option func(*Handler)
+
+// WithStatus reports provider availability on /providers and rejects
+// requests for providers that are known to be missing.
+func WithStatus(status StatusSource) Option {
+	return func(h *Handler) {
+		h.status = status
+	}
+}
+
+// WithFallbackChains makes requests for a provider transparently continue
+// with the providers listed for it in chains when it fails. attemptTimeout,
+// if non-zero, also hands over to the next provider when one runs too long.
+func WithFallbackChains(chains map[string][]string, attemptTimeout time.Duration) Option {
+	return func(h *Handler) {
+		h.fallbacks = chains
+		h.attemptTimeout = attemptTimeout
+	}
+}
+
+// WithDefaultStrategy sets the strategy used for requests that do not name
+// one.
+func WithDefaultStrategy(name string) Option {
+	return func(h *Handler) {
+		h.defaultStrategy = name
+	}
+}
+
+// WithRaceProviders sets the providers raced when a race request does not
+// list any. Without it all installed providers race.
+func WithRaceProviders(names []string) Option {
+	return func(h *Handler) {
+		h.raceProviders = names
+	}
+}
+
+// WithConsensusProviders sets the providers asked when a consensus request
+// names neither providers nor a provider. Without it the default provider
+// is sampled.
+func WithConsensusProviders(names []string) Option {
+	return func(h *Handler) {
+		h.consensusProviders = names
+	}
+}
+
+// WithCriticReviewer sets the provider that reviews the draft when a critic
+// request names no reviewer. Without it such requests are rejected.
+func WithCriticReviewer(name string) Option {
+	return func(h *Handler) {
+		h.criticReviewer = name
+	}
+}
+
+// WithModels sets the model every provider uses by default and the models
+// requests may pick per provider.
+func WithModels(defaults map[string]string, allowed map[string][]string) Option {
+	return func(h *Handler) {
+		h.models = defaults
+		h.allowedModels = allowed
+	}
+}
+
+// WithDatabase sets the default target database and the other databases
+// requests may target. Databases with a dialect profile get their SQL
+// checked for unsupported features.
+func WithDatabase(name string, allowed []string) Option {
+	return func(h *Handler) {
+		h.database = name
+		h.allowedDatabases = allowed
+	}
+}
+
+// WithExamples sets the library of few-shot examples and the estimated
+// tokens the examples added to a prompt may take.
+func WithExamples(library *examples.Library, budget int) Option {
+	return func(h *Handler) {
+		h.examples = library
+		h.examplesBudget = budget
+	}
+}
+
+// WithSchemaPruning limits the estimated tokens and the number of tables of
+// the DDL sent to providers. Larger schemas are pruned to the tables most
+// relevant to the question; 0 disables a limit.
+func WithSchemaPruning(budget, maxTables int) Option {
+	return func(h *Handler) {
+		h.schemaBudget = budget
+		h.schemaMaxTables = maxTables
+	}
+}
+
+// WithDDLNormalization enables or disables compacting schema dumps such as
+// pg_dump output before they are pruned and sent to providers. It is
+// disabled by default.
+func WithDDLNormalization(enabled bool) Option {
+	return func(h *Handler) {
+		h.normalizeDDL = enabled
+	}
+}
+
+// WithSessions keeps the turns of conversations in store, so requests can
+// follow up on earlier ones by their session ID.
+func WithSessions(store *session.Store) Option {
+	return func(h *Handler) {
+		h.sessions = store
+	}
+}
+
+// WithSemanticLayer adds the entries of the semantic layer that are relevant
+// to a request's question and schema to its prompt.
+func WithSemanticLayer(layer *semantic.Layer) Option {
+	return func(h *Handler) {
+		h.semantic = layer
+	}
+}
+
+// WithDates sets how prompts ask for relative dates such as "last month":
+// prompt.DatesLiteral or prompt.DatesFunctions.
+func WithDates(style string) Option {
+	return func(h *Handler) {
+		h.dates = style
+	}
+}
+
+// WithCorrection validates generated SQL against the request's DDL and
+// sends SQL with unknown tables or columns, ambiguous columns, a missing
+// GROUP BY or unbalanced parentheses back to the provider with the
+// problems, up to rounds times. Racing providers only win with SQL that
+// passes validation. Zero disables validation.
+func WithCorrection(rounds int) Option {
+	return func(h *Handler) {
+		h.correctionRounds = rounds
+	}
 }
 
 // New creates a new Handler with the given dependencies.
-func New(providers map[string]provider.SQLGenerator, defaultProvider, allowedOrigin string) *Handler {
-	return &Handler{
+func New(providers map[string]provider.SQLGenerator, defaultProvider, allowedOrigin string, opts ...Option) *Handler {
+	h := &Handler{
 		providers:       providers,
 		defaultProvider: defaultProvider,
 		allowedOrigin:   allowedOrigin,
+		defaultStrategy: StrategyFallback,
+		examplesBudget:  defaultExamplesBudget,
+		clock:           time.Now,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 // HandleGenerateSQL handles POST /generate-sql requests.
@@ -67,43 +438,660 @@ func (h *Handler) HandleGenerateSQL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req, names, ok := h.decodeSQLRequest(w, r)
+	if !ok {
+		return
+	}
+
+	log.Printf("[INFO] Generating SQL (%s) using %s for question: %q", req.Strategy, strings.Join(names, ", "), req.Question)
+
+	outcome, err := h.execute(r.Context(), req, names, func(ctx context.Context, req SQLRequest, name string) (provider.Result, error) {
+		return h.generate(ctx, req, name, nil)
+	})
+	logAttempts(outcome.Attempts)
+	if errors.Is(err, context.Canceled) {
+		log.Printf("[INFO] Request cancelled by client, provider CLI stopped")
+		return
+	}
+	var clarification *provider.Clarification
+	if errors.As(err, &clarification) {
+		log.Printf("[INFO] %s asked for clarification: %q", clarification.Provider, clarification.Question)
+		h.sendJSON(w, h.clarificationResponse(clarification, outcome, req))
+		return
+	}
+	if err != nil {
+		message, statusCode := generationError(err)
+		h.sendResponse(w, SQLResponse{Error: message, Attempts: outcome.Attempts}, statusCode)
+		return
+	}
+
+	log.Printf("[INFO] Successfully generated SQL using %s", outcome.Provider)
+	h.sendJSON(w, h.successResponse(outcome, req))
+}
+
+// execute runs the request's strategy over the resolved providers, calling
+// generate for every provider call. The reviewer of a critic run gets the
+// request with the draft to review.
+func (h *Handler) execute(ctx context.Context, req SQLRequest, names []string, generate func(ctx context.Context, req SQLRequest, name string) (provider.Result, error)) (strategy.Outcome, error) {
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		return generate(ctx, req, name)
+	}
+	switch req.Strategy {
+	case StrategyRace:
+		validate := strategy.NotEmpty
+		if h.correctionRounds > 0 {
+			validate = validSQL(req)
+		}
+		return strategy.Race(ctx, names, validate, run)
+	case StrategyConsensus:
+		return strategy.Consensus(ctx, names, strategy.NotEmpty, run)
+	case StrategyCritic:
+		// The draft is cleaned like the final SQL, so the review's draft
+		// and diff only show the reviewer's changes
+		profile := dialect.Lookup(req.Database)
+		clean := func(result provider.Result, err error) (provider.Result, error) {
+			result.SQL = profile.Clean(result.SQL)
+			return result, err
+		}
+		return strategy.Critic(ctx, names[0], names[1], strategy.NotEmpty, func(ctx context.Context, name string) (provider.Result, error) {
+			return clean(run(ctx, name))
+		}, func(ctx context.Context, name string, draft provider.Result) (provider.Result, error) {
+			review := req
+			review.draft = draft.SQL
+			return clean(generate(ctx, review, name))
+		})
+	}
+	return strategy.Fallback(ctx, names, h.attemptTimeout, run)
+}
+
+// validSQL returns the validator of a race with validation enabled: it
+// rejects blank SQL and SQL that fails validation against the request's
+// full DDL, so the first answer that fits the schema wins.
+func validSQL(req SQLRequest) strategy.Validator {
+	parsed := schema.Parse(req.fullDDL)
+	profile := dialect.Lookup(req.Database)
+	return func(sql string) error {
+		if err := strategy.NotEmpty(sql); err != nil {
+			return err
+		}
+		if problems := parsed.Check(profile.Clean(sql)); len(problems) > 0 {
+			return fmt.Errorf("%w: %s", strategy.ErrInvalidSQL, strings.Join(problems, "; "))
+		}
+		return nil
+	}
+}
+
+// generate runs the request against the named provider, passing emit to
+// provider.Generate. With correction rounds configured, SQL that fails
+// validation against the request's full DDL is sent back to the provider
+// with its problems until it passes or the rounds are used up; the rejected
+// drafts are kept in the result's Corrections. A failed round keeps the
+// previous result unless the request was cancelled.
+func (h *Handler) generate(ctx context.Context, req SQLRequest, name string, emit func(provider.StreamEvent)) (provider.Result, error) {
+	preq := h.providerRequest(req, name)
+	result, err := provider.Generate(ctx, name, h.providers[name], preq, emit)
+	result, err = askedBack(req, result, err)
+	if err != nil || h.correctionRounds <= 0 {
+		return result, err
+	}
+
+	parsed := schema.Parse(req.fullDDL)
+	profile := dialect.Lookup(req.Database)
+	duration := result.Duration
+	for len(preq.Corrections) < h.correctionRounds {
+		sql := profile.Clean(result.SQL)
+		problems := parsed.Check(sql)
+		if len(problems) == 0 {
+			break
+		}
+		log.Printf("[WARN] SQL of %s failed validation: %s", name, strings.Join(problems, "; "))
+		if emit != nil {
+			emit(provider.StreamEvent{Type: provider.EventProgress, Stage: "correction", Text: "Correcting: " + strings.Join(problems, "; ")})
+		}
+
+		preq.Corrections = append(preq.Corrections, schema.Correction{SQL: sql, Errors: problems})
+		next, err := provider.Generate(ctx, name, h.providers[name], preq, emit)
+		next, err = askedBack(req, next, err)
+		duration += next.Duration
+		if errors.Is(err, context.Canceled) {
+			return provider.Result{}, err
+		}
+		if err != nil {
+			log.Printf("[WARN] Correcting the SQL of %s failed: %v", name, err)
+			preq.Corrections = preq.Corrections[:len(preq.Corrections)-1]
+			break
+		}
+		result = next
+	}
+	result.Duration = duration
+	result.Corrections = preq.Corrections
+	return result, nil
+}
+
+// providerRequest builds the generation request for one provider, picking
+// the requested model for the providers the request asked for and the
+// configured default otherwise. Like fallbacks, the reviewer of a critic
+// run keeps its configured model.
+func (h *Handler) providerRequest(req SQLRequest, name string) provider.Request {
+	model := h.models[name]
+	if req.Model != "" && ((req.Strategy != StrategyFallback && req.Strategy != StrategyCritic) || name == req.Provider) {
+		model = req.Model
+	}
+
+	preq := provider.Request{
+		DDL:          req.DDL,
+		Question:     req.Question,
+		Database:     req.Database,
+		Examples:     req.Examples,
+		SampleRows:   req.SampleRows,
+		ColumnValues: req.ColumnValues,
+		Semantic:     h.semantic.Relevant(req.search, req.DDL),
+		Joins:        req.joins,
+		Now:          req.currentTime,
+		Timezone:     req.Timezone,
+		Dates:        h.dates,
+		Clarify:      req.Clarify,
+		Draft:        req.draft,
+		Model:        model,
+		Effort:       req.Effort,
+	}
+	if req.conversation != nil {
+		preq.History = req.conversation.Turns
+		preq.SessionID = req.conversation.Resume(name, req.schemaKey)
+	}
+	if req.native != nil {
+		preq.OnSession = func(id string) { req.native.set(name, id) }
+	}
+	return preq
+}
+
+// successResponse builds the response for a successful outcome, cleaning
+// the SQL for the target database's dialect and flagging features it does
+// not support. If the request is part of a conversation, the turn is added
+// to it.
+func (h *Handler) successResponse(outcome strategy.Outcome, req SQLRequest) SQLResponse {
+	profile := dialect.Lookup(req.Database)
+	result := outcome.Result
+	response := SQLResponse{
+		Type:             ResponseSQL,
+		SQL:              profile.Clean(result.SQL),
+		Explanation:      result.Explanation,
+		Assumptions:      result.Assumptions,
+		Confidence:       result.Confidence,
+		ReferencedTables: result.Tables,
+		Provider:         outcome.Provider,
+		Model:            result.Model,
+		DurationMS:       result.Duration.Milliseconds(),
+		Attempts:         outcome.Attempts,
+		Agreement:        outcome.Agreement,
+		Dissent:          outcome.Dissent,
+		Review:           outcome.Review,
+		DDLTokensSaved:   req.tokensSaved,
+		Tables:           req.tables,
+		Joins:            req.joins,
+		Now:              req.currentTime.Format(time.RFC3339),
+	}
+	if req.Debug {
+		response.Raw = result.Raw
+	}
+	if profile != nil {
+		response.Warnings = profile.Check(response.SQL)
+		for _, warning := range response.Warnings {
+			log.Printf("[WARN] %s: %s", profile.Title, warning)
+		}
+	}
+	if h.correctionRounds > 0 {
+		response.Corrections = result.Corrections
+		response.ValidationErrors = schema.Parse(req.fullDDL).Check(response.SQL)
+	}
+	response.SessionID = h.saveTurn(req, session.Turn{Question: req.Question, SQL: response.SQL}, outcome.Provider)
+	return response
+}
+
+// clarificationResponse builds the response for a provider that asked a
+// clarifying question. If the request is part of a conversation, the
+// question is added to it, so the client can answer it in a follow-up.
+func (h *Handler) clarificationResponse(clarification *provider.Clarification, outcome strategy.Outcome, req SQLRequest) SQLResponse {
+	response := SQLResponse{
+		Type:           ResponseClarification,
+		Question:       clarification.Question,
+		Options:        clarification.Options,
+		Provider:       clarification.Provider,
+		Attempts:       outcome.Attempts,
+		DDLTokensSaved: req.tokensSaved,
+		Tables:         req.tables,
+		Joins:          req.joins,
+		Now:            req.currentTime.Format(time.RFC3339),
+	}
+	turn := session.Turn{Question: req.Question, Clarification: clarification.Question}
+	response.SessionID = h.saveTurn(req, turn, clarification.Provider)
+	return response
+}
+
+// saveTurn adds a turn answered by the named provider to the request's
+// conversation and returns the conversation's ID, or "" if sessions are
+// disabled or the request is not part of a conversation.
+func (h *Handler) saveTurn(req SQLRequest, turn session.Turn, name string) string {
+	if req.native == nil {
+		return ""
+	}
+	return h.sessions.Save(req.SessionID, req.rawDDL, req.Database, turn, name, req.native.get(name), req.schemaKey)
+}
+
+// schemaKey returns a hash of the DDL and data hints sent with the
+// request's prompt.
+func schemaKey(req SQLRequest) string {
+	hash := sha256.New()
+	hash.Write([]byte(req.DDL))
+	json.NewEncoder(hash).Encode([]any{req.SampleRows, req.ColumnValues})
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// askedBack turns a result of provider.Generate that asks a clarifying
+// question into a *provider.Clarification error, if the request allows
+// clarifications, and records the provider on clarifications.
+func askedBack(req SQLRequest, result provider.Result, err error) (provider.Result, error) {
+	if err == nil && req.Clarify {
+		if clarification := provider.ParseClarification(result.SQL); clarification != nil {
+			err = clarification
+		}
+	}
+	var clarification *provider.Clarification
+	if errors.As(err, &clarification) {
+		clarification.Provider = result.Provider
+		return provider.Result{}, clarification
+	}
+	return result, err
+}
+
+// generationError maps a failed generation to the client-facing message and
+// HTTP status code.
+func generationError(err error) (string, int) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("[ERROR] SQL generation timed out: %v", err)
+		return "SQL generation timed out", http.StatusGatewayTimeout
+	}
+	log.Printf("[ERROR] SQL generation failed: %v", err)
+	return "Failed to generate SQL", http.StatusInternalServerError
+}
+
+// logAttempts logs every provider attempt that failed, with the full error
+// the response only classifies.
+func logAttempts(attempts []strategy.Attempt) {
+	for _, attempt := range attempts {
+		switch {
+		case attempt.Err != nil:
+			log.Printf("[WARN] %s failed after %dms: %v", attempt.Provider, attempt.DurationMS, attempt.Err)
+		case attempt.Error != "":
+			log.Printf("[WARN] %s failed after %dms: %s", attempt.Provider, attempt.DurationMS, attempt.Error)
+		}
+	}
+}
+
+// decodeSQLRequest decodes and validates a generation request, fills in the
+// default strategy and provider and resolves the providers to use: the
+// fallback chain, or the providers to race. On failure it writes the error
+// response and returns false.
+func (h *Handler) decodeSQLRequest(w http.ResponseWriter, r *http.Request) (SQLRequest, []string, bool) {
 	var req SQLRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Printf("[ERROR] Invalid JSON: %v", err)
 		h.sendError(w, "Invalid JSON", http.StatusBadRequest)
-		return
+		return req, nil, false
 	}
 
-	if req.DDL == "" || req.Question == "" {
+	if (req.DDL == "" && req.SessionID == "") || req.Question == "" {
 		log.Printf("[ERROR] Missing required fields: ddl=%q, question=%q", req.DDL, req.Question)
 		h.sendError(w, "Both 'ddl' and 'question' fields are required", http.StatusBadRequest)
-		return
+		return req, nil, false
+	}
+
+	if req.Model != "" && !modelPattern.MatchString(req.Model) {
+		log.Printf("[ERROR] Invalid model: %q", req.Model)
+		h.sendError(w, "Invalid 'model': expected letters, digits and . _ : / @ -, not starting with -", http.StatusBadRequest)
+		return req, nil, false
+	}
+
+	if req.Effort != "" && !validEfforts[req.Effort] {
+		log.Printf("[ERROR] Unknown effort: %s", req.Effort)
+		h.sendError(w, fmt.Sprintf("Unknown effort: %s", req.Effort), http.StatusBadRequest)
+		return req, nil, false
+	}
+
+	for _, example := range req.Examples {
+		if example.Question == "" || example.SQL == "" {
+			log.Printf("[ERROR] Example without question or SQL")
+			h.sendError(w, "Each example needs 'question' and 'sql' fields", http.StatusBadRequest)
+			return req, nil, false
+		}
+	}
+
+	if err := hints.Validate(req.SampleRows, req.ColumnValues); err != nil {
+		log.Printf("[ERROR] Invalid data hints: %v", err)
+		h.sendError(w, "Invalid data hints: "+err.Error(), http.StatusBadRequest)
+		return req, nil, false
+	}
+	var truncated bool
+	if req.SampleRows, req.ColumnValues, truncated = hints.Truncate(req.SampleRows, req.ColumnValues); truncated {
+		log.Printf("[INFO] Sample rows and column values cut to their size caps")
+	}
+
+	req.currentTime = h.clock()
+	if req.Now != "" {
+		now, err := time.Parse(time.RFC3339, req.Now)
+		if err != nil {
+			log.Printf("[ERROR] Invalid now: %s", req.Now)
+			h.sendError(w, "Invalid 'now': expected an RFC 3339 timestamp", http.StatusBadRequest)
+			return req, nil, false
+		}
+		req.currentTime = now
+	}
+	if req.Timezone != "" {
+		location, err := time.LoadLocation(req.Timezone)
+		if err != nil {
+			log.Printf("[ERROR] Unknown timezone: %s", req.Timezone)
+			h.sendError(w, fmt.Sprintf("Unknown timezone: %s", req.Timezone), http.StatusBadRequest)
+			return req, nil, false
+		}
+		req.currentTime = req.currentTime.In(location)
 	}
 
-	// Determine which provider to use
-	providerName := req.Provider
-	if providerName == "" {
-		providerName = h.defaultProvider
+	req.search = req.Question
+	if req.SessionID != "" {
+		conversation, ok := h.sessions.Get(req.SessionID)
+		if !ok {
+			log.Printf("[ERROR] Session %s not found", req.SessionID)
+			h.sendError(w, fmt.Sprintf("Session %s not found or expired", req.SessionID), http.StatusNotFound)
+			return req, nil, false
+		}
+		req.conversation = conversation
+		if req.DDL == "" {
+			req.DDL = conversation.DDL
+		}
+		if req.Database == "" {
+			req.Database = conversation.Database
+		}
+		var questions []string
+		for _, turn := range conversation.Turns {
+			questions = append(questions, turn.Question)
+		}
+		req.search = strings.Join(append(questions, req.Question), "\n")
+	}
+	if h.sessions != nil && (req.SessionID != "" || req.Session) {
+		req.native = &nativeSessions{ids: make(map[string]string)}
 	}
+	req.rawDDL = req.DDL
 
-	p, ok := h.providers[providerName]
+	database, ok := h.resolveDatabase(req.Database)
 	if !ok {
+		log.Printf("[ERROR] Database %s is not allowed", req.Database)
+		h.sendError(w, fmt.Sprintf("Database %s is not allowed", req.Database), http.StatusBadRequest)
+		return req, nil, false
+	}
+	req.Database = database
+
+	if h.normalizeDDL {
+		if normalized := schema.Normalize(req.DDL); normalized.DDL != req.DDL {
+			log.Printf("[INFO] DDL normalized: %d statements removed, %d estimated tokens saved", normalized.Removed, normalized.Saved)
+			req.DDL, req.tokensSaved = normalized.DDL, normalized.Saved
+		}
+	}
+
+	req.fullDDL = req.DDL
+	if pruned := schema.Prune(req.DDL, req.search, h.schemaBudget, h.schemaMaxTables); pruned.Tables != nil {
+		log.Printf("[INFO] Schema pruned to %d of %d tables: %s", len(pruned.Tables), pruned.Total, strings.Join(pruned.Tables, ", "))
+		req.DDL, req.tables = pruned.DDL, pruned.Tables
+	}
+	req.joins = schema.Parse(req.DDL).JoinPaths(req.search)
+	if req.native != nil {
+		req.schemaKey = schemaKey(req)
+	}
+	req.Examples = h.examples.Select(req.search, req.DDL, req.Database, req.Examples, h.examplesBudget)
+
+	if req.Strategy == "" {
+		req.Strategy = h.defaultStrategy
+	}
+
+	var names []string
+	switch req.Strategy {
+	case StrategyFallback:
+		if req.Provider == "" {
+			req.Provider = h.defaultProvider
+		}
+		names, ok = h.resolveFallback(w, req.Provider)
+	case StrategyRace:
+		var candidates []string
+		if candidates, ok = h.raceCandidates(w, req); ok {
+			names, ok = h.resolveProviders(w, candidates)
+		}
+	case StrategyConsensus:
+		var candidates []string
+		if candidates, ok = h.consensusCandidates(w, req); ok {
+			names, ok = h.resolveProviders(w, candidates)
+		}
+	case StrategyCritic:
+		if req.Provider == "" {
+			req.Provider = h.defaultProvider
+		}
+		if req.Reviewer == "" {
+			req.Reviewer = h.criticReviewer
+		}
+		names, ok = h.criticProviders(w, req)
+	default:
+		log.Printf("[ERROR] Unknown strategy: %s", req.Strategy)
+		h.sendError(w, fmt.Sprintf("Unknown strategy: %s", req.Strategy), http.StatusBadRequest)
+		return req, nil, false
+	}
+	if !ok {
+		return req, nil, false
+	}
+
+	if req.Model != "" {
+		targets := names
+		if req.Strategy == StrategyFallback || req.Strategy == StrategyCritic {
+			targets = []string{req.Provider}
+		}
+		for _, name := range targets {
+			if !h.modelAllowed(name, req.Model) {
+				log.Printf("[ERROR] Model %s is not allowed for provider %s", req.Model, name)
+				h.sendError(w, fmt.Sprintf("Model %s is not allowed for provider %s", req.Model, name), http.StatusBadRequest)
+				return req, nil, false
+			}
+		}
+	}
+
+	return req, names, true
+}
+
+// resolveFallback checks that the provider is known and returns it followed
+// by its installed fallbacks. On failure it writes the error response and
+// returns false.
+func (h *Handler) resolveFallback(w http.ResponseWriter, providerName string) ([]string, bool) {
+	if _, ok := h.providers[providerName]; !ok {
 		log.Printf("[ERROR] Unknown provider: %s", providerName)
 		h.sendError(w, fmt.Sprintf("Unknown provider: %s", providerName), http.StatusBadRequest)
-		return
+		return nil, false
 	}
 
-	log.Printf("[INFO] Generating SQL using %s for question: %q", providerName, req.Question)
+	chain := h.resolveChain(providerName)
+	if len(chain) == 0 {
+		log.Printf("[ERROR] Provider %s is not installed and has no installed fallback", providerName)
+		h.sendError(w, fmt.Sprintf("Provider %s is not installed", providerName), http.StatusServiceUnavailable)
+		return nil, false
+	}
 
-	sql, err := p.GenerateSQL(req.DDL, req.Question)
-	if err != nil {
-		log.Printf("[ERROR] %s CLI failed: %v", providerName, err)
-		h.sendError(w, "Failed to generate SQL", http.StatusInternalServerError)
-		return
+	return chain, true
+}
+
+// resolveDatabase returns the configured spelling of a requested database,
+// or the default database if none was requested. Names are matched
+// case-insensitively; it returns false for databases that are not allowed.
+func (h *Handler) resolveDatabase(name string) (string, bool) {
+	if name == "" {
+		return h.database, true
+	}
+	for _, allowed := range append([]string{h.database}, h.allowedDatabases...) {
+		if allowed != "" && strings.EqualFold(allowed, name) {
+			return allowed, true
+		}
+	}
+	return "", false
+}
+
+// modelAllowed reports whether a request may pick model for the provider.
+// Providers without an allowlist accept any model.
+func (h *Handler) modelAllowed(name, model string) bool {
+	allowed := h.allowedModels[name]
+	return len(allowed) == 0 || slices.Contains(allowed, model)
+}
+
+// resolveChain returns the provider followed by its configured fallbacks,
+// skipping providers that are known not to be installed.
+func (h *Handler) resolveChain(providerName string) []string {
+	candidates := append([]string{providerName}, h.fallbacks[providerName]...)
+
+	chain := make([]string, 0, len(candidates))
+	for _, name := range candidates {
+		if h.installed(name) {
+			chain = append(chain, name)
+		}
+	}
+	return chain
+}
+
+// raceCandidates returns the providers to race: those named in the request
+// without duplicates, else the configured race providers, else every
+// provider. On failure it writes the error response and returns false.
+func (h *Handler) raceCandidates(w http.ResponseWriter, req SQLRequest) ([]string, bool) {
+	if len(req.Providers) > 0 {
+		var candidates []string
+		for _, name := range req.Providers {
+			if !slices.Contains(candidates, name) {
+				candidates = append(candidates, name)
+			}
+		}
+		if len(candidates) > maxRaceProviders {
+			h.sendError(w, fmt.Sprintf("At most %d providers can race", maxRaceProviders), http.StatusBadRequest)
+			return nil, false
+		}
+		return candidates, true
+	}
+	if len(h.raceProviders) > 0 {
+		return h.raceProviders, true
+	}
+
+	candidates := make([]string, 0, len(h.providers))
+	for name := range h.providers {
+		candidates = append(candidates, name)
+	}
+	sort.Strings(candidates)
+	return candidates, true
+}
+
+// consensusCandidates returns the provider calls that vote on a consensus:
+// the providers named in the request, else the requested provider sampled
+// req.Samples times, else the configured consensus providers, else the
+// default provider sampled. On failure it writes the error response and
+// returns false.
+func (h *Handler) consensusCandidates(w http.ResponseWriter, req SQLRequest) ([]string, bool) {
+	if len(req.Providers) > 0 {
+		if len(req.Providers) > maxConsensusRuns {
+			h.sendError(w, fmt.Sprintf("At most %d providers can vote", maxConsensusRuns), http.StatusBadRequest)
+			return nil, false
+		}
+		return req.Providers, true
+	}
+	if req.Provider == "" && req.Samples == 0 && len(h.consensusProviders) > 0 {
+		return h.consensusProviders, true
+	}
+
+	samples := req.Samples
+	if samples == 0 {
+		samples = defaultSamples
+	}
+	if samples < 1 || samples > maxConsensusRuns {
+		h.sendError(w, fmt.Sprintf("'samples' must be between 1 and %d", maxConsensusRuns), http.StatusBadRequest)
+		return nil, false
+	}
+
+	name := req.Provider
+	if name == "" {
+		name = h.defaultProvider
+	}
+	candidates := make([]string, samples)
+	for i := range candidates {
+		candidates[i] = name
+	}
+	return candidates, true
+}
+
+// criticProviders returns the generator and the reviewer of a critic
+// request. On failure, including a reviewer that is neither requested nor
+// configured, it writes the error response and returns false.
+func (h *Handler) criticProviders(w http.ResponseWriter, req SQLRequest) ([]string, bool) {
+	if req.Reviewer == "" {
+		log.Printf("[ERROR] Critic request without reviewer")
+		h.sendError(w, "The critic strategy needs a 'reviewer'", http.StatusBadRequest)
+		return nil, false
 	}
 
-	log.Printf("[INFO] Successfully generated SQL")
-	h.sendJSON(w, SQLResponse{SQL: sql})
+	candidates := []string{req.Provider, req.Reviewer}
+	names, ok := h.resolveProviders(w, candidates)
+	if !ok {
+		return nil, false
+	}
+	for _, name := range candidates {
+		if !slices.Contains(names, name) {
+			log.Printf("[ERROR] Provider %s is not installed", name)
+			h.sendError(w, fmt.Sprintf("Provider %s is not installed", name), http.StatusServiceUnavailable)
+			return nil, false
+		}
+	}
+	return candidates, true
+}
+
+// resolveProviders checks that every candidate is a known provider and
+// drops those known not to be installed. On failure it writes the error
+// response and returns false.
+func (h *Handler) resolveProviders(w http.ResponseWriter, candidates []string) ([]string, bool) {
+	names := make([]string, 0, len(candidates))
+	for _, name := range candidates {
+		if _, ok := h.providers[name]; !ok {
+			log.Printf("[ERROR] Unknown provider: %s", name)
+			h.sendError(w, fmt.Sprintf("Unknown provider: %s", name), http.StatusBadRequest)
+			return nil, false
+		}
+		if h.installed(name) {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		log.Printf("[ERROR] None of the providers is installed: %s", strings.Join(candidates, ", "))
+		h.sendError(w, "None of the providers is installed", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	return names, true
+}
+
+// installed reports whether a provider may be used. Providers with an
+// unknown status are assumed to be installed.
+func (h *Handler) installed(name string) bool {
+	if h.status == nil {
+		return true
+	}
+	status, ok := h.status.Status(name)
+	return !ok || status.Installed
+}
+
+// WithTimeout bounds every request's context by d, so provider subprocesses
+// are killed before the server's write deadline passes.
+func WithTimeout(next http.Handler, d time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
 
 // setCORSHeaders sets the required CORS and Private Network Access headers.
@@ -116,9 +1104,14 @@ func (h *Handler) setCORSHeaders(w http.ResponseWriter) {
 
 // sendError sends an error response as JSON.
 func (h *Handler) sendError(w http.ResponseWriter, message string, statusCode int) {
+	h.sendResponse(w, SQLResponse{Error: message}, statusCode)
+}
+
+// sendResponse sends a response as JSON with the given status code.
+func (h *Handler) sendResponse(w http.ResponseWriter, response SQLResponse, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(SQLResponse{Error: message})
+	json.NewEncoder(w).Encode(response)
 }
 
 // sendJSON sends a successful JSON response.
@@ -160,13 +1153,27 @@ func (h *Handler) HandleProviders(w http.ResponseWriter, r *http.Request) {
 	providers := make([]ProviderInfo, 0, len(h.providers))
 	for name := range h.providers {
 		description := providerDescriptions[name]
+		if d, ok := h.providers[name].(provider.Describer); ok {
+			description = d.Description()
+		}
 		if description == "" {
 			description = name
 		}
-		providers = append(providers, ProviderInfo{
-			Name:        name,
-			Description: description,
-		})
+		info := ProviderInfo{
+			Name:         name,
+			Description:  description,
+			DefaultModel: h.models[name],
+			Models:       h.allowedModels[name],
+		}
+		if es, ok := h.providers[name].(provider.EffortSupporter); ok {
+			info.SupportsEffort = es.SupportsEffort()
+		}
+		if h.status != nil {
+			if status, ok := h.status.Status(name); ok {
+				info.Status = &status
+			}
+		}
+		providers = append(providers, info)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -2,13 +2,25 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/tobilg/text-to-sql-proxy/src/internal/examples"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/hints"
 	"github.com/tobilg/text-to-sql-proxy/src/internal/provider"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/schema"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/semantic"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/session"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/strategy"
 )
 
 // mockSQLGenerator implements provider.SQLGenerator for testing.
@@ -17,8 +29,8 @@ type mockSQLGenerator struct {
 	err error
 }
 
-func (m *mockSQLGenerator) GenerateSQL(ddl, question string) (string, error) {
-	return m.sql, m.err
+func (m *mockSQLGenerator) GenerateSQL(ctx context.Context, req provider.Request) (provider.Result, error) {
+	return provider.Result{SQL: m.sql}, m.err
 }
 
 func newTestHandler(mock *mockSQLGenerator) *Handler {
@@ -150,6 +162,45 @@ func TestHandleGenerateSQL_Success(t *testing.T) {
 	}
 }
 
+// resultSQLGenerator returns a result with structured fields.
+type resultSQLGenerator struct {
+	result provider.Result
+}
+
+func (r *resultSQLGenerator) GenerateSQL(ctx context.Context, req provider.Request) (provider.Result, error) {
+	return r.result, nil
+}
+
+func TestHandleGenerateSQL_ResultFields(t *testing.T) {
+	handler := newTestHandlerWithProviders(map[string]provider.SQLGenerator{"claude": &resultSQLGenerator{result: provider.Result{
+		SQL:         "SELECT SUM(total) FROM orders",
+		Explanation: "Sums the order totals.",
+		Assumptions: []string{"Totals are net"},
+		Confidence:  0.9,
+		Tables:      []string{"orders"},
+		Model:       "sonnet",
+		Raw:         `{"structured_output":{}}`,
+	}}}, "claude")
+
+	for _, debug := range []bool{false, true} {
+		body, _ := json.Marshal(SQLRequest{DDL: "CREATE TABLE orders (total INT)", Question: "Revenue", Debug: debug})
+		req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		handler.HandleGenerateSQL(w, req)
+
+		var resp SQLResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp.Explanation != "Sums the order totals." || len(resp.Assumptions) != 1 || resp.Confidence != 0.9 ||
+			len(resp.ReferencedTables) != 1 || resp.Model != "sonnet" || resp.Provider != "claude" {
+			t.Errorf("unexpected response %+v", resp)
+		}
+		if (resp.Raw != "") != debug {
+			t.Errorf("expected raw output only with debug, got %q (debug %v)", resp.Raw, debug)
+		}
+	}
+}
+
 func TestHandleGenerateSQL_ProviderError(t *testing.T) {
 	handler := newTestHandler(&mockSQLGenerator{err: errors.New("CLI failed")})
 
@@ -307,3 +358,1413 @@ func TestHandleProviders_MethodNotAllowed(t *testing.T) {
 		t.Errorf("expected status 405, got %d", w.Code)
 	}
 }
+
+// blockingSQLGenerator blocks until the request context is cancelled.
+type blockingSQLGenerator struct {
+	started chan struct{}
+}
+
+func (b *blockingSQLGenerator) GenerateSQL(ctx context.Context, req provider.Request) (provider.Result, error) {
+	close(b.started)
+	<-ctx.Done()
+	return provider.Result{}, errors.Join(provider.ErrCLIExecution, ctx.Err())
+}
+
+func TestHandleGenerateSQL_ClientCancelled(t *testing.T) {
+	blocking := &blockingSQLGenerator{started: make(chan struct{})}
+	handler := newTestHandlerWithProviders(map[string]provider.SQLGenerator{"claude": blocking}, "claude")
+
+	body, _ := json.Marshal(SQLRequest{
+		DDL:      "CREATE TABLE users (id INT)",
+		Question: "Select all users",
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body)).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		handler.HandleGenerateSQL(w, req)
+		close(done)
+	}()
+
+	<-blocking.started
+	cancel()
+	<-done
+
+	if w.Body.Len() != 0 {
+		t.Errorf("expected no response body for cancelled request, got %q", w.Body.String())
+	}
+}
+
+func TestHandleGenerateSQL_Timeout(t *testing.T) {
+	blocking := &blockingSQLGenerator{started: make(chan struct{})}
+	handler := newTestHandlerWithProviders(map[string]provider.SQLGenerator{"claude": blocking}, "claude")
+
+	body, _ := json.Marshal(SQLRequest{
+		DDL:      "CREATE TABLE users (id INT)",
+		Question: "Select all users",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	WithTimeout(http.HandlerFunc(handler.HandleGenerateSQL), 50*time.Millisecond).ServeHTTP(w, req)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status 504, got %d", w.Code)
+	}
+
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Error != "SQL generation timed out" {
+		t.Errorf("expected timeout error, got %q", resp.Error)
+	}
+}
+
+// describedSQLGenerator is a mock provider that carries its own description.
+type describedSQLGenerator struct {
+	mockSQLGenerator
+	description string
+}
+
+func (d *describedSQLGenerator) Description() string {
+	return d.description
+}
+
+func TestHandleProviders_CustomDescription(t *testing.T) {
+	providers := map[string]provider.SQLGenerator{
+		"llm": &describedSQLGenerator{description: "Simon Willison's llm"},
+	}
+	handler := newTestHandlerWithProviders(providers, "llm")
+
+	req := httptest.NewRequest(http.MethodGet, "/providers", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleProviders(w, req)
+
+	var resp struct {
+		Providers []ProviderInfo `json:"providers"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(resp.Providers) != 1 || resp.Providers[0].Description != "Simon Willison's llm" {
+		t.Errorf("expected custom description, got %+v", resp.Providers)
+	}
+}
+
+// mockStatusSource returns fixed provider statuses.
+type mockStatusSource map[string]provider.Status
+
+func (m mockStatusSource) Status(name string) (provider.Status, bool) {
+	status, ok := m[name]
+	return status, ok
+}
+
+func TestHandleProviders_WithStatus(t *testing.T) {
+	authenticated := true
+	providers := map[string]provider.SQLGenerator{
+		"claude": &mockSQLGenerator{},
+		"gemini": &mockSQLGenerator{},
+	}
+	status := mockStatusSource{
+		"claude": {Installed: true, Version: "2.0.1 (Claude Code)", Path: "/usr/local/bin/claude", Authenticated: &authenticated},
+		"gemini": {Installed: false, LastError: `exec: "gemini": executable file not found in $PATH`},
+	}
+	handler := New(providers, "claude", "https://sql-workbench.com", WithStatus(status))
+
+	req := httptest.NewRequest(http.MethodGet, "/providers", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleProviders(w, req)
+
+	var resp struct {
+		Providers []map[string]any `json:"providers"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	for _, p := range resp.Providers {
+		switch p["name"] {
+		case "claude":
+			if p["installed"] != true || p["version"] != "2.0.1 (Claude Code)" || p["path"] != "/usr/local/bin/claude" || p["authenticated"] != true {
+				t.Errorf("unexpected claude status: %v", p)
+			}
+		case "gemini":
+			if p["installed"] != false || p["last_error"] == nil {
+				t.Errorf("unexpected gemini status: %v", p)
+			}
+			if _, ok := p["authenticated"]; ok {
+				t.Errorf("expected authenticated to be omitted when unknown: %v", p)
+			}
+		}
+	}
+}
+
+func TestHandleGenerateSQL_ProviderNotInstalled(t *testing.T) {
+	providers := map[string]provider.SQLGenerator{"gemini": &mockSQLGenerator{sql: "SELECT 1"}}
+	status := mockStatusSource{"gemini": {Installed: false}}
+	handler := New(providers, "gemini", "https://sql-workbench.com", WithStatus(status))
+
+	body, _ := json.Marshal(SQLRequest{DDL: "CREATE TABLE users (id INT)", Question: "Select all"})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Error != "Provider gemini is not installed" {
+		t.Errorf("unexpected error: %q", resp.Error)
+	}
+}
+
+func TestHandleGenerateSQL_FallbackChain(t *testing.T) {
+	providers := map[string]provider.SQLGenerator{
+		"claude": &mockSQLGenerator{err: errors.Join(provider.ErrCLIExecution, errors.New("usage limit reached"))},
+		"codex":  &mockSQLGenerator{err: provider.ErrParsing},
+		"gemini": &mockSQLGenerator{sql: "SELECT gemini"},
+	}
+	chains := map[string][]string{"claude": {"codex", "gemini"}}
+	handler := New(providers, "claude", "https://sql-workbench.com", WithFallbackChains(chains, 0))
+
+	body, _ := json.Marshal(SQLRequest{DDL: "CREATE TABLE users (id INT)", Question: "Select all"})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.SQL != "SELECT gemini" || resp.Provider != "gemini" {
+		t.Errorf("expected gemini to answer, got %+v", resp)
+	}
+	if len(resp.Attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %+v", resp.Attempts)
+	}
+	for i, name := range []string{"claude", "codex", "gemini"} {
+		if resp.Attempts[i].Provider != name {
+			t.Errorf("attempt %d: expected %s, got %s", i, name, resp.Attempts[i].Provider)
+		}
+		if failed := resp.Attempts[i].Error != ""; failed != (name != "gemini") {
+			t.Errorf("attempt %d: unexpected error state %q", i, resp.Attempts[i].Error)
+		}
+	}
+}
+
+func TestHandleGenerateSQL_FallbackAllFail(t *testing.T) {
+	providers := map[string]provider.SQLGenerator{
+		"claude": &mockSQLGenerator{err: errors.Join(provider.ErrCLIExecution, errors.New("token expired for alice@example.com"))},
+		"codex":  &mockSQLGenerator{err: provider.ErrCLIExecution},
+	}
+	handler := New(providers, "claude", "https://sql-workbench.com",
+		WithFallbackChains(map[string][]string{"claude": {"codex"}}, 0))
+
+	body, _ := json.Marshal(SQLRequest{DDL: "CREATE TABLE users (id INT)", Question: "Select all"})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
+	}
+
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Error != "Failed to generate SQL" || len(resp.Attempts) != 2 {
+		t.Errorf("expected error with both attempts, got %+v", resp)
+	}
+	for _, attempt := range resp.Attempts {
+		if attempt.Error != "cli failed" {
+			t.Errorf("expected the attempt error to be classified, got %q", attempt.Error)
+		}
+	}
+}
+
+func TestHandleGenerateSQL_FallbackSkipsMissingProvider(t *testing.T) {
+	providers := map[string]provider.SQLGenerator{
+		"claude": &mockSQLGenerator{sql: "SELECT claude"},
+		"codex":  &mockSQLGenerator{sql: "SELECT codex"},
+	}
+	status := mockStatusSource{"claude": {Installed: false}, "codex": {Installed: true}}
+	handler := New(providers, "claude", "https://sql-workbench.com",
+		WithStatus(status), WithFallbackChains(map[string][]string{"claude": {"codex"}}, 0))
+
+	body, _ := json.Marshal(SQLRequest{DDL: "CREATE TABLE users (id INT)", Question: "Select all"})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Provider != "codex" || len(resp.Attempts) != 1 {
+		t.Errorf("expected codex without a claude attempt, got %+v", resp)
+	}
+}
+
+func TestHandleGenerateSQL_Race(t *testing.T) {
+	blocking := &blockingSQLGenerator{started: make(chan struct{})}
+	providers := map[string]provider.SQLGenerator{
+		"claude": blocking,
+		"codex":  &mockSQLGenerator{sql: "SELECT codex"},
+		"gemini": &mockSQLGenerator{sql: "SELECT gemini"},
+	}
+	handler := newTestHandlerWithProviders(providers, "claude")
+
+	body, _ := json.Marshal(SQLRequest{
+		DDL:       "CREATE TABLE users (id INT)",
+		Question:  "Select all",
+		Strategy:  StrategyRace,
+		Providers: []string{"claude", "codex"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.SQL != "SELECT codex" || resp.Provider != "codex" {
+		t.Errorf("expected codex to win, got %+v", resp)
+	}
+	if len(resp.Attempts) != 2 || resp.Attempts[0].Provider != "claude" || resp.Attempts[0].Error != "cancelled" {
+		t.Errorf("expected claude to be cancelled, got %+v", resp.Attempts)
+	}
+}
+
+func TestHandleGenerateSQL_RaceDeduplicates(t *testing.T) {
+	providers := map[string]provider.SQLGenerator{
+		"claude": &mockSQLGenerator{err: provider.ErrCLIExecution},
+		"codex":  &mockSQLGenerator{sql: "SELECT codex"},
+	}
+	handler := newTestHandlerWithProviders(providers, "claude")
+
+	body, _ := json.Marshal(SQLRequest{
+		DDL:       "CREATE TABLE users (id INT)",
+		Question:  "Select all",
+		Strategy:  StrategyRace,
+		Providers: []string{"codex", "claude", "codex", "claude"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Provider != "codex" || len(resp.Attempts) != 2 {
+		t.Errorf("expected every provider to race once, got %+v", resp)
+	}
+}
+
+func TestHandleGenerateSQL_RaceValidatesAgainstSchema(t *testing.T) {
+	providers := map[string]provider.SQLGenerator{
+		"claude": &mockSQLGenerator{sql: "SELECT amount FROM users"},
+		"codex":  &mockSQLGenerator{sql: "SELECT id FROM users"},
+	}
+	handler := New(providers, "claude", "https://sql-workbench.com", WithCorrection(1))
+
+	body, _ := json.Marshal(SQLRequest{
+		DDL:       "CREATE TABLE users (id INT)",
+		Question:  "Select all",
+		Strategy:  StrategyRace,
+		Providers: []string{"claude", "codex", "claude", "codex"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Provider != "codex" || resp.SQL != "SELECT id FROM users" {
+		t.Errorf("expected the SQL that fits the schema to win, got %+v", resp)
+	}
+	if len(resp.Attempts) != 2 || resp.Attempts[0].Error == "" {
+		t.Errorf("expected one attempt per provider with claude failing, got %+v", resp.Attempts)
+	}
+}
+
+func TestHandleGenerateSQL_RaceDefaults(t *testing.T) {
+	providers := map[string]provider.SQLGenerator{
+		"claude": &mockSQLGenerator{err: provider.ErrCLIExecution},
+		"codex":  &mockSQLGenerator{sql: "SELECT codex"},
+		"gemini": &mockSQLGenerator{sql: "SELECT gemini"},
+	}
+	handler := New(providers, "claude", "https://sql-workbench.com",
+		WithDefaultStrategy(StrategyRace), WithRaceProviders([]string{"claude", "codex"}))
+
+	body, _ := json.Marshal(SQLRequest{DDL: "CREATE TABLE users (id INT)", Question: "Select all"})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Provider != "codex" || len(resp.Attempts) != 2 {
+		t.Errorf("expected codex to win a race against claude only, got %+v", resp)
+	}
+}
+
+func TestHandleGenerateSQL_InvalidStrategy(t *testing.T) {
+	tests := []struct {
+		name    string
+		request SQLRequest
+		status  int
+		error   string
+	}{
+		{
+			name:    "unknown strategy",
+			request: SQLRequest{Strategy: "vote"},
+			status:  http.StatusBadRequest,
+			error:   "Unknown strategy: vote",
+		},
+		{
+			name:    "unknown race provider",
+			request: SQLRequest{Strategy: StrategyRace, Providers: []string{"claude", "invalid"}},
+			status:  http.StatusBadRequest,
+			error:   "Unknown provider: invalid",
+		},
+		{
+			name:    "too many race providers",
+			request: SQLRequest{Strategy: StrategyRace, Providers: []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"}},
+			status:  http.StatusBadRequest,
+			error:   "At most 10 providers can race",
+		},
+	}
+
+	handler := newTestHandler(&mockSQLGenerator{sql: "SELECT 1"})
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.request.DDL = "CREATE TABLE users (id INT)"
+			tc.request.Question = "Select all"
+			body, _ := json.Marshal(tc.request)
+			req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			handler.HandleGenerateSQL(w, req)
+
+			if w.Code != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, w.Code)
+			}
+			var resp SQLResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Error != tc.error {
+				t.Errorf("expected error %q, got %q", tc.error, resp.Error)
+			}
+		})
+	}
+}
+
+func TestHandleGenerateSQL_Consensus(t *testing.T) {
+	providers := map[string]provider.SQLGenerator{
+		"claude": &mockSQLGenerator{sql: "SELECT name FROM users"},
+		"gemini": &mockSQLGenerator{sql: "select NAME\nfrom users;"},
+		"codex":  &mockSQLGenerator{sql: "SELECT * FROM users"},
+	}
+	handler := newTestHandlerWithProviders(providers, "claude")
+
+	body, _ := json.Marshal(SQLRequest{
+		DDL:       "CREATE TABLE users (id INT, name TEXT)",
+		Question:  "List user names",
+		Strategy:  StrategyConsensus,
+		Providers: []string{"claude", "gemini", "codex"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.SQL != "SELECT name FROM users" || resp.Provider != "claude" {
+		t.Errorf("expected the majority answer, got %+v", resp)
+	}
+	if resp.Agreement < 0.66 || resp.Agreement > 0.67 {
+		t.Errorf("expected agreement 2/3, got %f", resp.Agreement)
+	}
+	if len(resp.Dissent) != 1 || resp.Dissent[0].Providers[0] != "codex" {
+		t.Errorf("expected codex to dissent, got %+v", resp.Dissent)
+	}
+}
+
+// countingSQLGenerator counts how often it is called.
+type countingSQLGenerator struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *countingSQLGenerator) GenerateSQL(ctx context.Context, req provider.Request) (provider.Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	return provider.Result{SQL: "SELECT 1"}, nil
+}
+
+func TestHandleGenerateSQL_ConsensusSamples(t *testing.T) {
+	counting := &countingSQLGenerator{}
+	handler := newTestHandlerWithProviders(map[string]provider.SQLGenerator{"claude": counting}, "claude")
+
+	body, _ := json.Marshal(SQLRequest{
+		DDL:      "CREATE TABLE users (id INT)",
+		Question: "Select one",
+		Strategy: StrategyConsensus,
+		Samples:  4,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if counting.calls != 4 || len(resp.Attempts) != 4 {
+		t.Errorf("expected claude to be asked 4 times, got %d calls and %+v", counting.calls, resp.Attempts)
+	}
+	if resp.Agreement != 1 {
+		t.Errorf("expected unanimous agreement, got %f", resp.Agreement)
+	}
+}
+
+func TestHandleGenerateSQL_ConsensusInvalidSamples(t *testing.T) {
+	handler := newTestHandler(&mockSQLGenerator{sql: "SELECT 1"})
+
+	body, _ := json.Marshal(SQLRequest{
+		DDL:      "CREATE TABLE users (id INT)",
+		Question: "Select one",
+		Strategy: StrategyConsensus,
+		Samples:  50,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+// recordingSQLGenerator records the request it was called with.
+type recordingSQLGenerator struct {
+	err error
+	req provider.Request
+}
+
+func (r *recordingSQLGenerator) GenerateSQL(ctx context.Context, req provider.Request) (provider.Result, error) {
+	r.req = req
+	return provider.Result{SQL: "SELECT 1"}, r.err
+}
+
+func TestHandleGenerateSQL_Model(t *testing.T) {
+	claude := &recordingSQLGenerator{err: provider.ErrCLIExecution}
+	codex := &recordingSQLGenerator{}
+	providers := map[string]provider.SQLGenerator{"claude": claude, "codex": codex}
+	handler := New(providers, "claude", "https://sql-workbench.com",
+		WithFallbackChains(map[string][]string{"claude": {"codex"}}, 0),
+		WithModels(map[string]string{"claude": "sonnet", "codex": "gpt-5-codex"}, nil))
+
+	body, _ := json.Marshal(SQLRequest{
+		DDL:      "CREATE TABLE users (id INT)",
+		Question: "Select all",
+		Model:    "opus",
+		Effort:   provider.EffortHigh,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if claude.req.Model != "opus" {
+		t.Errorf("expected claude to use the requested model, got %q", claude.req.Model)
+	}
+	if codex.req.Model != "gpt-5-codex" {
+		t.Errorf("expected the fallback to keep its default model, got %q", codex.req.Model)
+	}
+	if codex.req.Effort != provider.EffortHigh {
+		t.Errorf("expected effort high, got %q", codex.req.Effort)
+	}
+}
+
+func TestHandleGenerateSQL_InvalidModel(t *testing.T) {
+	tests := []struct {
+		name    string
+		request SQLRequest
+		error   string
+	}{
+		{
+			name:    "model not allowed",
+			request: SQLRequest{Model: "gpt-4o"},
+			error:   "Model gpt-4o is not allowed for provider claude",
+		},
+		{
+			name:    "model not allowed for raced provider",
+			request: SQLRequest{Model: "haiku", Strategy: StrategyRace, Providers: []string{"claude", "codex"}},
+			error:   "Model haiku is not allowed for provider codex",
+		},
+		{
+			name:    "model that is a flag",
+			request: SQLRequest{Model: "--dangerously-skip-permissions", Provider: "codex"},
+			error:   "Invalid 'model': expected letters, digits and . _ : / @ -, not starting with -",
+		},
+		{
+			name:    "model with spaces",
+			request: SQLRequest{Model: "opus --verbose", Provider: "codex"},
+			error:   "Invalid 'model': expected letters, digits and . _ : / @ -, not starting with -",
+		},
+		{
+			name:    "unknown effort",
+			request: SQLRequest{Effort: "maximum"},
+			error:   "Unknown effort: maximum",
+		},
+	}
+
+	providers := map[string]provider.SQLGenerator{
+		"claude": &mockSQLGenerator{sql: "SELECT 1"},
+		"codex":  &mockSQLGenerator{sql: "SELECT 1"},
+	}
+	handler := New(providers, "claude", "https://sql-workbench.com",
+		WithModels(nil, map[string][]string{"claude": {"haiku", "opus"}, "codex": {"gpt-5-codex"}}))
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.request.DDL = "CREATE TABLE users (id INT)"
+			tc.request.Question = "Select all"
+			body, _ := json.Marshal(tc.request)
+			req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			handler.HandleGenerateSQL(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", w.Code)
+			}
+			var resp SQLResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Error != tc.error {
+				t.Errorf("expected error %q, got %q", tc.error, resp.Error)
+			}
+		})
+	}
+}
+
+// effortSQLGenerator is a provider that accepts a reasoning effort.
+type effortSQLGenerator struct {
+	mockSQLGenerator
+}
+
+func (e *effortSQLGenerator) SupportsEffort() bool {
+	return true
+}
+
+func TestHandleProviders_Models(t *testing.T) {
+	providers := map[string]provider.SQLGenerator{
+		"claude": &mockSQLGenerator{},
+		"codex":  &effortSQLGenerator{},
+	}
+	handler := New(providers, "claude", "https://sql-workbench.com",
+		WithModels(map[string]string{"claude": "sonnet"}, map[string][]string{"claude": {"haiku", "sonnet", "opus"}}))
+
+	req := httptest.NewRequest(http.MethodGet, "/providers", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleProviders(w, req)
+
+	var resp struct {
+		Providers []ProviderInfo `json:"providers"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	for _, p := range resp.Providers {
+		switch p.Name {
+		case "claude":
+			if p.DefaultModel != "sonnet" || len(p.Models) != 3 || p.SupportsEffort {
+				t.Errorf("unexpected claude info: %+v", p)
+			}
+		case "codex":
+			if p.DefaultModel != "" || len(p.Models) != 0 || !p.SupportsEffort {
+				t.Errorf("unexpected codex info: %+v", p)
+			}
+		}
+	}
+}
+
+func TestHandleGenerateSQL_DialectWarnings(t *testing.T) {
+	tests := []struct {
+		database string
+		sql      string
+		expected string
+		warnings int
+	}{
+		{"PostgreSQL 12", "SELECT * FROM t QUALIFY ROW_NUMBER() OVER () = 1", "SELECT * FROM t QUALIFY ROW_NUMBER() OVER () = 1", 1},
+		{"DuckDB", "SELECT * FROM `t` QUALIFY ROW_NUMBER() OVER () = 1", "SELECT * FROM t QUALIFY ROW_NUMBER() OVER () = 1", 0},
+		{"MySQL", "SELECT * FROM `order`", "SELECT * FROM `order`", 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.database, func(t *testing.T) {
+			providers := map[string]provider.SQLGenerator{"claude": &mockSQLGenerator{sql: tc.sql}}
+			handler := New(providers, "claude", "https://sql-workbench.com", WithDatabase(tc.database, nil))
+
+			body, _ := json.Marshal(SQLRequest{DDL: "CREATE TABLE t (id INT)", Question: "Select all"})
+			req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			handler.HandleGenerateSQL(w, req)
+
+			var resp SQLResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.SQL != tc.expected {
+				t.Errorf("expected SQL %q, got %q", tc.expected, resp.SQL)
+			}
+			if len(resp.Warnings) != tc.warnings {
+				t.Errorf("expected %d warnings, got %v", tc.warnings, resp.Warnings)
+			}
+		})
+	}
+}
+
+func TestHandleGenerateSQL_Database(t *testing.T) {
+	tests := []struct {
+		name     string
+		database string
+		status   int
+		expected string
+	}{
+		{"default", "", http.StatusOK, "DuckDB"},
+		{"allowed", "postgresql 16", http.StatusOK, "PostgreSQL 16"},
+		{"default by name", "duckdb", http.StatusOK, "DuckDB"},
+		{"not allowed", "MySQL", http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			claude := &recordingSQLGenerator{}
+			providers := map[string]provider.SQLGenerator{"claude": claude}
+			handler := New(providers, "claude", "https://sql-workbench.com",
+				WithDatabase("DuckDB", []string{"PostgreSQL 16"}))
+
+			body, _ := json.Marshal(SQLRequest{DDL: "CREATE TABLE t (id INT)", Question: "Select all", Database: tc.database})
+			req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			handler.HandleGenerateSQL(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, w.Code)
+			}
+			if tc.status != http.StatusOK {
+				var resp SQLResponse
+				json.NewDecoder(w.Body).Decode(&resp)
+				if resp.Error != "Database MySQL is not allowed" {
+					t.Errorf("unexpected error %q", resp.Error)
+				}
+				return
+			}
+			if claude.req.Database != tc.expected {
+				t.Errorf("expected database %q, got %q", tc.expected, claude.req.Database)
+			}
+		})
+	}
+}
+
+func TestHandleGenerateSQL_Examples(t *testing.T) {
+	claude := &recordingSQLGenerator{}
+	providers := map[string]provider.SQLGenerator{"claude": claude}
+	path := filepath.Join(t.TempDir(), "examples.jsonl")
+	content := `{"question": "Revenue per fiscal year", "sql": "SELECT fy, SUM(total) FROM orders GROUP BY fy"}
+{"question": "Deleted users", "sql": "SELECT * FROM users WHERE deleted_at IS NOT NULL"}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	library, err := examples.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := New(providers, "claude", "https://sql-workbench.com", WithExamples(library, 1000))
+
+	body, _ := json.Marshal(SQLRequest{
+		DDL:      "CREATE TABLE orders (fy INT, total DECIMAL)",
+		Question: "Revenue per fiscal year and region",
+		Examples: []examples.Example{{Question: "Orders by region", SQL: "SELECT region, COUNT(*) FROM orders GROUP BY region"}},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if len(claude.req.Examples) != 2 {
+		t.Fatalf("expected the inline and one library example, got %v", claude.req.Examples)
+	}
+	if claude.req.Examples[0].Question != "Orders by region" || claude.req.Examples[1].Question != "Revenue per fiscal year" {
+		t.Errorf("unexpected examples %v", claude.req.Examples)
+	}
+}
+
+func TestHandleGenerateSQL_InvalidExample(t *testing.T) {
+	providers := map[string]provider.SQLGenerator{"claude": &mockSQLGenerator{sql: "SELECT 1"}}
+	handler := New(providers, "claude", "https://sql-workbench.com")
+
+	body, _ := json.Marshal(SQLRequest{
+		DDL:      "CREATE TABLE users (id INT)",
+		Question: "Select all",
+		Examples: []examples.Example{{Question: "Count users"}},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestHandleGenerateSQL_DataHints(t *testing.T) {
+	claude := &recordingSQLGenerator{}
+	providers := map[string]provider.SQLGenerator{"claude": claude}
+	handler := New(providers, "claude", "https://sql-workbench.com")
+
+	body := `{
+		"ddl": "CREATE TABLE orders (id INT, state TEXT)",
+		"question": "Orders from California",
+		"sample_rows": [{"table": "orders", "columns": ["id", "state"], "rows": [[1, "CA"], [2, "NY"], [3, "CA"], [4, "TX"], [5, "CA"], [6, "WA"]]}],
+		"column_values": [{"table": "orders", "column": "state", "values": ["CA", "NY", "TX", "WA"]}]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if len(claude.req.SampleRows) != 1 || len(claude.req.SampleRows[0].Rows) != hints.MaxSampleRows {
+		t.Errorf("expected sample rows cut to %d rows, got %v", hints.MaxSampleRows, claude.req.SampleRows)
+	}
+	if len(claude.req.ColumnValues) != 1 || len(claude.req.ColumnValues[0].Values) != 4 {
+		t.Errorf("expected the column values, got %v", claude.req.ColumnValues)
+	}
+}
+
+func TestHandleGenerateSQL_InvalidDataHints(t *testing.T) {
+	providers := map[string]provider.SQLGenerator{"claude": &mockSQLGenerator{sql: "SELECT 1"}}
+	handler := New(providers, "claude", "https://sql-workbench.com")
+
+	body := `{
+		"ddl": "CREATE TABLE orders (id INT, state TEXT)",
+		"question": "Orders from California",
+		"sample_rows": [{"table": "orders", "columns": ["id", "state"], "rows": [[1]]}]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Error != "Invalid data hints: sample row of table orders has 1 values, expected 2" {
+		t.Errorf("unexpected error %q", resp.Error)
+	}
+}
+
+func TestHandleGenerateSQL_SemanticLayer(t *testing.T) {
+	claude := &recordingSQLGenerator{}
+	providers := map[string]provider.SQLGenerator{"claude": claude}
+	layer := &semantic.Layer{
+		Metrics: []semantic.Metric{
+			{Name: "net revenue", Definition: "SUM(total) - SUM(refunds)"},
+			{Name: "churn rate", Definition: "AVG(churned::INT)"},
+		},
+	}
+	handler := New(providers, "claude", "https://sql-workbench.com", WithSemanticLayer(layer))
+
+	body, _ := json.Marshal(SQLRequest{DDL: "CREATE TABLE orders (total DECIMAL, refunds DECIMAL)", Question: "Net revenue per month"})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if metrics := claude.req.Semantic.Metrics; len(metrics) != 1 || metrics[0].Name != "net revenue" {
+		t.Errorf("expected the net revenue metric, got %+v", metrics)
+	}
+}
+
+func TestHandleGenerateSQL_SchemaPruning(t *testing.T) {
+	claude := &recordingSQLGenerator{}
+	providers := map[string]provider.SQLGenerator{"claude": claude}
+	handler := New(providers, "claude", "https://sql-workbench.com", WithSchemaPruning(0, 1))
+
+	body, _ := json.Marshal(SQLRequest{
+		DDL:      "CREATE TABLE users (id INT, name TEXT); CREATE TABLE invoices (id INT, total DECIMAL);",
+		Question: "Sum of invoice totals",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if claude.req.DDL != "CREATE TABLE invoices (id INT, total DECIMAL);" {
+		t.Errorf("expected the pruned DDL, got %q", claude.req.DDL)
+	}
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Tables) != 1 || resp.Tables[0] != "invoices" {
+		t.Errorf("expected tables [invoices], got %v", resp.Tables)
+	}
+}
+
+func TestHandleGenerateSQL_Time(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("timezone database not available")
+	}
+	clock := time.Date(2026, 3, 1, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		now      string
+		timezone string
+		expected time.Time
+	}{
+		{name: "server clock", expected: clock},
+		{name: "server clock in timezone", timezone: "Europe/Berlin", expected: clock.In(berlin)},
+		{name: "request time", now: "2025-12-31T12:00:00-05:00", expected: time.Date(2025, 12, 31, 12, 0, 0, 0, time.FixedZone("", -5*3600))},
+		{name: "request time in timezone", now: "2025-12-31T23:30:00Z", timezone: "Europe/Berlin", expected: time.Date(2025, 12, 31, 23, 30, 0, 0, time.UTC).In(berlin)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			claude := &recordingSQLGenerator{}
+			providers := map[string]provider.SQLGenerator{"claude": claude}
+			handler := New(providers, "claude", "https://sql-workbench.com", WithDates("functions"))
+			handler.clock = func() time.Time { return clock }
+
+			body, _ := json.Marshal(SQLRequest{DDL: "CREATE TABLE orders (id INT)", Question: "Orders of last month", Now: tc.now, Timezone: tc.timezone})
+			req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			handler.HandleGenerateSQL(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			got := claude.req.Now
+			if !got.Equal(tc.expected) || got.Format(time.RFC3339) != tc.expected.Format(time.RFC3339) {
+				t.Errorf("expected now %s, got %s", tc.expected.Format(time.RFC3339), got.Format(time.RFC3339))
+			}
+			if claude.req.Timezone != tc.timezone || claude.req.Dates != "functions" {
+				t.Errorf("expected timezone %q and functions dates, got %q and %q", tc.timezone, claude.req.Timezone, claude.req.Dates)
+			}
+			var resp SQLResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Now != tc.expected.Format(time.RFC3339) {
+				t.Errorf("expected now %s in the response, got %s", tc.expected.Format(time.RFC3339), resp.Now)
+			}
+		})
+	}
+}
+
+func TestHandleGenerateSQL_InvalidTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		now      string
+		timezone string
+		expected string
+	}{
+		{name: "invalid now", now: "yesterday", expected: "Invalid 'now': expected an RFC 3339 timestamp"},
+		{name: "unknown timezone", timezone: "Mars/Olympus_Mons", expected: "Unknown timezone: Mars/Olympus_Mons"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			providers := map[string]provider.SQLGenerator{"claude": &recordingSQLGenerator{}}
+			handler := New(providers, "claude", "https://sql-workbench.com")
+
+			body, _ := json.Marshal(SQLRequest{DDL: "CREATE TABLE orders (id INT)", Question: "Orders of last month", Now: tc.now, Timezone: tc.timezone})
+			req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			handler.HandleGenerateSQL(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d", w.Code)
+			}
+			var resp SQLResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Error != tc.expected {
+				t.Errorf("expected error %q, got %q", tc.expected, resp.Error)
+			}
+		})
+	}
+}
+
+func TestHandleGenerateSQL_NormalizeDDL(t *testing.T) {
+	ddl := "SET client_encoding = 'UTF8';\n" +
+		"CREATE TABLE public.users (\n    id integer NOT NULL,\n    name text COLLATE pg_catalog.\"default\"\n);\n" +
+		"ALTER TABLE public.users OWNER TO postgres;\n" +
+		"COMMENT ON COLUMN public.users.name IS 'Full name';\n" +
+		"GRANT SELECT ON TABLE public.users TO reporting;"
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		expected string
+	}{
+		{name: "enabled", enabled: true, expected: "CREATE TABLE public.users (\n  id integer NOT NULL,\n  name text -- Full name\n);"},
+		{name: "disabled", expected: ddl},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			claude := &recordingSQLGenerator{}
+			providers := map[string]provider.SQLGenerator{"claude": claude}
+			handler := New(providers, "claude", "https://sql-workbench.com", WithDDLNormalization(tc.enabled))
+
+			body, _ := json.Marshal(SQLRequest{DDL: ddl, Question: "List user names"})
+			req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			handler.HandleGenerateSQL(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if claude.req.DDL != tc.expected {
+				t.Errorf("expected DDL %q, got %q", tc.expected, claude.req.DDL)
+			}
+			var resp SQLResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if tc.enabled != (resp.DDLTokensSaved > 0) {
+				t.Errorf("expected saved tokens only with normalization, got %d", resp.DDLTokensSaved)
+			}
+		})
+	}
+}
+
+// sessionSQLGenerator records its requests and reports a new session of
+// its own for every request it answers outside of one.
+type sessionSQLGenerator struct {
+	reqs     []provider.Request
+	sessions int
+}
+
+func (s *sessionSQLGenerator) GenerateSQL(ctx context.Context, req provider.Request) (provider.Result, error) {
+	s.reqs = append(s.reqs, req)
+	id := req.SessionID
+	if id == "" {
+		s.sessions++
+		id = fmt.Sprintf("native-%d", s.sessions)
+	}
+	if req.OnSession != nil {
+		req.OnSession(id)
+	}
+	return provider.Result{SQL: fmt.Sprintf("SELECT %d", len(s.reqs))}, nil
+}
+
+func TestHandleGenerateSQL_Sessions(t *testing.T) {
+	claude := &sessionSQLGenerator{}
+	codex := &recordingSQLGenerator{}
+	providers := map[string]provider.SQLGenerator{"claude": claude, "codex": codex}
+	handler := New(providers, "claude", "https://sql-workbench.com", WithSessions(session.NewStore(time.Minute, 0, 0)))
+
+	send := func(body SQLRequest) SQLResponse {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(data))
+		w := httptest.NewRecorder()
+		handler.HandleGenerateSQL(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp SQLResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+
+	ddl := "CREATE TABLE orders (id INT, total DECIMAL, created_at TIMESTAMP)"
+	if stateless := send(SQLRequest{DDL: ddl, Question: "Total revenue"}); stateless.SessionID != "" {
+		t.Errorf("expected no session without opting in, got %s", stateless.SessionID)
+	}
+	claude.reqs, claude.sessions = nil, 0
+
+	first := send(SQLRequest{DDL: ddl, Question: "Total revenue", Session: true})
+	if first.SessionID == "" {
+		t.Fatal("expected a session ID")
+	}
+
+	// claude resumes its own session, which holds the first turn
+	second := send(SQLRequest{SessionID: first.SessionID, Question: "Now per month"})
+	if second.SessionID != first.SessionID {
+		t.Errorf("expected session %s, got %s", first.SessionID, second.SessionID)
+	}
+	got := claude.reqs[1]
+	if got.DDL != ddl || got.SessionID != "native-1" || len(got.History) != 1 || got.History[0].SQL != "SELECT 1" {
+		t.Errorf("expected the conversation's DDL, turns and session, got %+v", got)
+	}
+
+	// codex has no session of its own, so the turns are replayed
+	send(SQLRequest{SessionID: first.SessionID, Question: "Only 2026", Provider: "codex"})
+	if codex.req.SessionID != "" || len(codex.req.History) != 2 || codex.req.History[1].Question != "Now per month" {
+		t.Errorf("expected both turns replayed to codex, got %+v", codex.req)
+	}
+
+	// claude's session misses codex's turn and is not resumed
+	send(SQLRequest{SessionID: first.SessionID, Question: "And per week"})
+	if got := claude.reqs[2]; got.SessionID != "" || len(got.History) != 3 {
+		t.Errorf("expected a new session with three turns replayed, got %+v", got)
+	}
+}
+
+func TestHandleGenerateSQL_SessionSchemaChange(t *testing.T) {
+	claude := &sessionSQLGenerator{}
+	handler := New(map[string]provider.SQLGenerator{"claude": claude}, "claude", "https://sql-workbench.com",
+		WithSessions(session.NewStore(time.Minute, 0, 0)))
+
+	send := func(body SQLRequest) SQLResponse {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(data))
+		w := httptest.NewRecorder()
+		handler.HandleGenerateSQL(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp SQLResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+
+	ddl := "CREATE TABLE orders (id INT, total DECIMAL)"
+	first := send(SQLRequest{DDL: ddl, Question: "Total of orders", Session: true})
+
+	// The same schema resumes claude's session
+	send(SQLRequest{SessionID: first.SessionID, Question: "Only orders above 100"})
+	if got := claude.reqs[1]; got.SessionID != "native-1" {
+		t.Fatalf("expected claude to resume its session, got %+v", got)
+	}
+
+	// New sample rows are not in claude's session, so the turns are replayed
+	rows := []hints.SampleRows{{Table: "orders", Columns: []string{"id", "total"}, Rows: [][]any{{1, 150}}}}
+	send(SQLRequest{SessionID: first.SessionID, Question: "Only orders above 200", SampleRows: rows})
+	if got := claude.reqs[2]; got.SessionID != "" || len(got.SampleRows) != 1 || len(got.History) != 2 {
+		t.Errorf("expected a new session with the sample rows and turns, got %+v", got)
+	}
+}
+
+func TestHandleGenerateSQL_Clarification(t *testing.T) {
+	claude := &mockSQLGenerator{sql: "```json\n{\"type\": \"clarification\", \"question\": \"Do you mean gross or net revenue?\", \"options\": [\"gross\", \"net\"]}\n```"}
+	codex := &recordingSQLGenerator{}
+	providers := map[string]provider.SQLGenerator{"claude": claude, "codex": codex}
+	handler := New(providers, "claude", "https://sql-workbench.com",
+		WithFallbackChains(map[string][]string{"claude": {"codex"}}, 0),
+		WithSessions(session.NewStore(time.Minute, 0, 0)))
+
+	send := func(body SQLRequest) SQLResponse {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(data))
+		w := httptest.NewRecorder()
+		handler.HandleGenerateSQL(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp SQLResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+
+	// Without clarify the answer is taken as it is
+	if resp := send(SQLRequest{DDL: "CREATE TABLE orders (total DECIMAL)", Question: "Revenue"}); resp.Type != ResponseSQL {
+		t.Errorf("expected SQL, got %+v", resp)
+	}
+
+	resp := send(SQLRequest{DDL: "CREATE TABLE orders (total DECIMAL)", Question: "Revenue", Clarify: true, Session: true})
+	if resp.Type != ResponseClarification || resp.SQL != "" || resp.Provider != "claude" {
+		t.Fatalf("expected a clarification by claude, got %+v", resp)
+	}
+	if resp.Question != "Do you mean gross or net revenue?" || len(resp.Options) != 2 {
+		t.Errorf("unexpected clarification %+v", resp)
+	}
+	if resp.SessionID == "" {
+		t.Fatal("expected a session ID")
+	}
+	if codex.req.Question != "" {
+		t.Error("expected the fallback chain to stop at the clarification")
+	}
+
+	// The answer follows up on the clarifying question
+	send(SQLRequest{SessionID: resp.SessionID, Question: "Net revenue", Provider: "codex"})
+	if len(codex.req.History) != 1 || codex.req.History[0].Clarification != "Do you mean gross or net revenue?" {
+		t.Errorf("expected the clarifying question in the history, got %+v", codex.req.History)
+	}
+}
+
+func TestHandleGenerateSQL_UnknownSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+	}{
+		{name: "expired", options: []Option{WithSessions(session.NewStore(time.Minute, 0, 0))}},
+		{name: "sessions disabled"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			providers := map[string]provider.SQLGenerator{"claude": &recordingSQLGenerator{}}
+			handler := New(providers, "claude", "https://sql-workbench.com", tc.options...)
+
+			body, _ := json.Marshal(SQLRequest{SessionID: "abc", Question: "Now per month"})
+			req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			handler.HandleGenerateSQL(w, req)
+
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("expected status 404, got %d", w.Code)
+			}
+			var resp SQLResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Error != "Session abc not found or expired" {
+				t.Errorf("unexpected error %q", resp.Error)
+			}
+		})
+	}
+}
+
+func TestHandleGenerateSQL_JoinPaths(t *testing.T) {
+	claude := &recordingSQLGenerator{}
+	providers := map[string]provider.SQLGenerator{"claude": claude}
+	handler := New(providers, "claude", "https://sql-workbench.com")
+
+	body, _ := json.Marshal(SQLRequest{
+		DDL:      "CREATE TABLE users (id INT, name TEXT); CREATE TABLE orders (id INT, user_id INT, total DECIMAL);",
+		Question: "Order totals per user",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	expected := schema.Join{From: "orders", FromColumn: "user_id", To: "users", ToColumn: "id", Inferred: true}
+	if len(claude.req.Joins) != 1 || claude.req.Joins[0] != expected {
+		t.Errorf("expected join %+v in the provider request, got %+v", expected, claude.req.Joins)
+	}
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Joins) != 1 || resp.Joins[0] != expected {
+		t.Errorf("expected join %+v in the response, got %+v", expected, resp.Joins)
+	}
+}
+
+// draftingSQLGenerator answers with its drafts in turn, repeating the last
+// one, and records its requests.
+type draftingSQLGenerator struct {
+	drafts []string
+	reqs   []provider.Request
+}
+
+func (d *draftingSQLGenerator) GenerateSQL(ctx context.Context, req provider.Request) (provider.Result, error) {
+	d.reqs = append(d.reqs, req)
+	return provider.Result{SQL: d.drafts[min(len(d.reqs), len(d.drafts))-1]}, nil
+}
+
+func TestHandleGenerateSQL_Correction(t *testing.T) {
+	ddl := "CREATE TABLE orders (id INT, region TEXT, total DECIMAL)"
+	tests := []struct {
+		name        string
+		rounds      int
+		drafts      []string
+		sql         string
+		corrections int
+		remaining   []string
+	}{
+		{
+			name:        "corrected",
+			rounds:      2,
+			drafts:      []string{"SELECT region, SUM(amount) FROM orders GROUP BY region", "SELECT region, SUM(total) FROM orders GROUP BY region"},
+			sql:         "SELECT region, SUM(total) FROM orders GROUP BY region",
+			corrections: 1,
+		},
+		{
+			name:        "rounds used up",
+			rounds:      2,
+			drafts:      []string{"SELECT region, SUM(total) FROM orders", "SELECT region, SUM(amount) FROM orders GROUP BY region"},
+			sql:         "SELECT region, SUM(amount) FROM orders GROUP BY region",
+			corrections: 2,
+			remaining:   []string{"column amount does not exist in orders (columns: id, region, total)"},
+		},
+		{
+			name:   "valid",
+			rounds: 2,
+			drafts: []string{"SELECT SUM(total) FROM orders"},
+			sql:    "SELECT SUM(total) FROM orders",
+		},
+		{
+			name:   "disabled",
+			drafts: []string{"SELECT SUM(amount) FROM orders"},
+			sql:    "SELECT SUM(amount) FROM orders",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			claude := &draftingSQLGenerator{drafts: tc.drafts}
+			handler := New(map[string]provider.SQLGenerator{"claude": claude}, "claude", "https://sql-workbench.com", WithCorrection(tc.rounds))
+
+			body, _ := json.Marshal(SQLRequest{DDL: ddl, Question: "Revenue per region"})
+			req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			handler.HandleGenerateSQL(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			var resp SQLResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.SQL != tc.sql || len(resp.Corrections) != tc.corrections || fmt.Sprint(resp.ValidationErrors) != fmt.Sprint(tc.remaining) {
+				t.Errorf("expected %q after %d corrections with problems %q, got %+v", tc.sql, tc.corrections, tc.remaining, resp)
+			}
+			if len(claude.reqs) != tc.corrections+1 {
+				t.Fatalf("expected %d requests, got %d", tc.corrections+1, len(claude.reqs))
+			}
+			for i, correction := range resp.Corrections {
+				got := claude.reqs[i+1].Corrections
+				if len(got) != i+1 || got[i].SQL != tc.drafts[i] || got[i].SQL != correction.SQL || len(got[i].Errors) == 0 {
+					t.Errorf("expected round %d to send the drafts rejected so far, got %+v", i+1, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleGenerateSQL_CorrectionWithPrunedSchema(t *testing.T) {
+	ddl := "CREATE TABLE orders (id INT, customer_id INT, total DECIMAL);\nCREATE TABLE customers (id INT, name TEXT);"
+	claude := &draftingSQLGenerator{drafts: []string{"SELECT c.name, SUM(o.total) FROM orders o JOIN customers c ON c.id = o.customer_id GROUP BY c.name"}}
+	handler := New(map[string]provider.SQLGenerator{"claude": claude}, "claude", "https://sql-workbench.com",
+		WithCorrection(2), WithSchemaPruning(0, 1))
+
+	body, _ := json.Marshal(SQLRequest{DDL: ddl, Question: "Order totals"})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Tables) != 1 || resp.Tables[0] != "orders" {
+		t.Fatalf("expected the schema to be pruned to orders, got %+v", resp)
+	}
+	// customers was pruned from the prompt but exists in the schema
+	if len(claude.reqs) != 1 || len(resp.Corrections) != 0 || len(resp.ValidationErrors) != 0 {
+		t.Errorf("expected the SQL to pass validation against the full schema, got %+v", resp)
+	}
+}
+
+func TestHandleGenerateSQL_Critic(t *testing.T) {
+	// The backticks are cleaned from the draft like from the final SQL, so
+	// the diff only shows the reviewer's change
+	gemini := &draftingSQLGenerator{drafts: []string{"SELECT SUM(`total`)\nFROM `orders`"}}
+	claude := &draftingSQLGenerator{drafts: []string{"-- Only paid orders count as revenue\nSELECT SUM(total)\nFROM orders\nWHERE status = 'paid'"}}
+	providers := map[string]provider.SQLGenerator{"claude": claude, "gemini": gemini}
+	handler := New(providers, "claude", "https://sql-workbench.com",
+		WithCriticReviewer("claude"),
+		WithModels(map[string]string{"claude": "sonnet"}, nil))
+
+	body, _ := json.Marshal(SQLRequest{
+		DDL:      "CREATE TABLE orders (id INT, total DECIMAL, status TEXT)",
+		Question: "Revenue",
+		Strategy: StrategyCritic,
+		Provider: "gemini",
+		Model:    "gemini-2.5-pro",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.SQL != "SELECT SUM(total)\nFROM orders\nWHERE status = 'paid'" || resp.Provider != "claude" {
+		t.Errorf("expected claude's correction, got %+v", resp)
+	}
+	if resp.Review == nil || resp.Review.Verdict != strategy.ReviewCorrected || resp.Review.Generator != "gemini" ||
+		resp.Review.Rationale != "Only paid orders count as revenue" || resp.Review.Draft != "SELECT SUM(total)\nFROM orders" ||
+		resp.Review.Diff != " SELECT SUM(total)\n FROM orders\n+WHERE status = 'paid'" {
+		t.Errorf("unexpected review %+v", resp.Review)
+	}
+	if len(resp.Attempts) != 2 {
+		t.Errorf("expected the draft and the review attempts, got %+v", resp.Attempts)
+	}
+
+	if got := gemini.reqs[0]; got.Draft != "" || got.Model != "gemini-2.5-pro" {
+		t.Errorf("expected gemini to draft with the requested model, got %+v", got)
+	}
+	if got := claude.reqs[0]; got.Draft != "SELECT SUM(total)\nFROM orders" || got.Model != "sonnet" {
+		t.Errorf("expected claude to review the draft with its own model, got %+v", got)
+	}
+}
+
+func TestHandleGenerateSQL_CriticWithoutReviewer(t *testing.T) {
+	handler := newTestHandler(&mockSQLGenerator{sql: "SELECT 1"})
+
+	body, _ := json.Marshal(SQLRequest{DDL: "CREATE TABLE t (a INT)", Question: "q", Strategy: StrategyCritic})
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQL(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+	var resp SQLResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Error != "The critic strategy needs a 'reviewer'" {
+		t.Errorf("unexpected error %q", resp.Error)
+	}
+}
@@ -50,6 +50,22 @@ const OpenAPISpec = `{
                     "question": "Calculate total sales per month",
                     "provider": "gemini"
                   }
+                },
+                "clarify": {
+                  "summary": "Allow a clarifying question",
+                  "value": {
+                    "ddl": "CREATE TABLE orders (id INT, amount_gross DECIMAL, amount_net DECIMAL);",
+                    "question": "Revenue per month",
+                    "clarify": true,
+                    "session": true
+                  }
+                },
+                "follow_up": {
+                  "summary": "Follow-up question in a conversation",
+                  "value": {
+                    "session_id": "3f2b8c1e9a7d4e6f8b0c2d4e6f8a0b1c",
+                    "question": "Now break that down by month"
+                  }
                 }
               }
             }
@@ -64,13 +80,19 @@ const OpenAPISpec = `{
                   "$ref": "#/components/schemas/SQLResponse"
                 },
                 "example": {
-                  "sql": "SELECT * FROM users WHERE name LIKE 'A%'"
+                  "type": "sql",
+                  "sql": "SELECT * FROM users WHERE name LIKE 'A%'",
+                  "explanation": "Selects the users whose name starts with an upper-case A.",
+                  "confidence": 0.95,
+                  "referenced_tables": ["users"],
+                  "provider": "claude",
+                  "duration_ms": 7310
                 }
               }
             }
           },
           "400": {
-            "description": "Bad request - invalid JSON, missing fields, or unknown provider",
+            "description": "Bad request - invalid JSON, missing fields, unknown provider, unknown strategy or database not allowed",
             "content": {
               "application/json": {
                 "schema": {
@@ -94,6 +116,42 @@ const OpenAPISpec = `{
                     "value": {
                       "error": "Unknown provider: invalid"
                     }
+                  },
+                  "unknown_strategy": {
+                    "summary": "Unknown strategy",
+                    "value": {
+                      "error": "Unknown strategy: invalid"
+                    }
+                  },
+                  "invalid_example": {
+                    "summary": "Example without question or SQL",
+                    "value": {
+                      "error": "Each example needs 'question' and 'sql' fields"
+                    }
+                  },
+                  "invalid_data_hints": {
+                    "summary": "Sample row that does not match its columns",
+                    "value": {
+                      "error": "Invalid data hints: sample row of table orders has 1 values, expected 2"
+                    }
+                  },
+                  "database_not_allowed": {
+                    "summary": "Database not allowed",
+                    "value": {
+                      "error": "Database Oracle is not allowed"
+                    }
+                  },
+                  "invalid_now": {
+                    "summary": "Current time that is not an RFC 3339 timestamp",
+                    "value": {
+                      "error": "Invalid 'now': expected an RFC 3339 timestamp"
+                    }
+                  },
+                  "unknown_timezone": {
+                    "summary": "Unknown timezone",
+                    "value": {
+                      "error": "Unknown timezone: Europe/Atlantis"
+                    }
                   }
                 }
               }
@@ -112,6 +170,32 @@ const OpenAPISpec = `{
               }
             }
           },
+          "404": {
+            "description": "Not found - the session is unknown or expired",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                },
+                "example": {
+                  "error": "Session 3f2b8c1e9a7d4e6f8b0c2d4e6f8a0b1c not found or expired"
+                }
+              }
+            }
+          },
+          "503": {
+            "description": "Service unavailable - the selected provider's CLI is not installed",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                },
+                "example": {
+                  "error": "Provider gemini is not installed"
+                }
+              }
+            }
+          },
           "500": {
             "description": "Internal server error - AI CLI execution failed",
             "content": {
@@ -124,6 +208,19 @@ const OpenAPISpec = `{
                 }
               }
             }
+          },
+          "504": {
+            "description": "Gateway timeout - AI CLI did not answer before the server timeout and was stopped",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                },
+                "example": {
+                  "error": "SQL generation timed out"
+                }
+              }
+            }
           }
         }
       },
@@ -138,6 +235,76 @@ const OpenAPISpec = `{
         }
       }
     },
+    "/generate-sql/stream": {
+      "post": {
+        "summary": "Generate SQL Query (streaming)",
+        "description": "Same request as /generate-sql, but the response is a Server-Sent Events stream. Providers that support streaming (claude, codex, opencode) send 'partial' events with model output fragments and 'progress' events with the step the CLI is performing; the proxy sends a 'progress' event with the stage 'correction' before every schema validation round. With the critic strategy the draft streams like this and the review is announced by a 'progress' event with the stage 'review'; race and consensus only send the final event. The stream always ends with a single 'sql' event carrying an SQLResponse, a 'clarification' event carrying an SQLResponse of type 'clarification' if the request set 'clarify', or an 'error' event carrying an ErrorResponse. Validation errors are returned as JSON before the stream starts.",
+        "operationId": "generateSQLStream",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/SQLRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Server-Sent Events stream",
+            "content": {
+              "text/event-stream": {
+                "schema": {
+                  "type": "string"
+                },
+                "example": "event: partial\ndata: {\"text\":\"SELECT\"}\n\nevent: sql\ndata: {\"sql\":\"SELECT * FROM users WHERE name LIKE 'A%'\"}\n\n"
+              }
+            }
+          },
+          "400": {
+            "description": "Bad request - invalid JSON, missing fields, or unknown provider",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "Not found - the session is unknown or expired",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          },
+          "405": {
+            "description": "Method not allowed - only POST and OPTIONS are supported",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      },
+      "options": {
+        "summary": "CORS Preflight",
+        "description": "Handle CORS preflight requests for cross-origin access.",
+        "operationId": "generateSQLStreamOptions",
+        "responses": {
+          "200": {
+            "description": "CORS preflight response with appropriate headers"
+          }
+        }
+      }
+    },
     "/openapi.json": {
       "get": {
         "summary": "OpenAPI Specification",
@@ -160,7 +327,7 @@ const OpenAPISpec = `{
     "/providers": {
       "get": {
         "summary": "List Providers",
-        "description": "Returns the list of available AI providers with their descriptions.",
+        "description": "Returns the list of configured AI providers with their descriptions and availability: whether the CLI is installed, its version and path, and optionally whether it is authenticated. Availability is checked at startup and then periodically.",
         "operationId": "listProviders",
         "responses": {
           "200": {
@@ -176,7 +343,9 @@ const OpenAPISpec = `{
                     {"name": "gemini", "description": "Google Gemini CLI"},
                     {"name": "codex", "description": "OpenAI Codex CLI"},
                     {"name": "continue", "description": "Continue CLI"},
-                    {"name": "opencode", "description": "OpenCode CLI"}
+                    {"name": "opencode", "description": "OpenCode CLI"},
+                    {"name": "anthropic", "description": "Anthropic API"},
+                    {"name": "openai", "description": "OpenAI-compatible API"}
                   ]
                 }
               }
@@ -202,11 +371,11 @@ const OpenAPISpec = `{
     "schemas": {
       "SQLRequest": {
         "type": "object",
-        "required": ["ddl", "question"],
+        "required": ["question"],
         "properties": {
           "ddl": {
             "type": "string",
-            "description": "DDL schema definition (CREATE TABLE statements)",
+            "description": "DDL schema definition (CREATE TABLE statements). Required unless 'session_id' is set, which defaults it to the conversation's DDL.",
             "example": "CREATE TABLE users (id INT, name TEXT, email TEXT);"
           },
           "question": {
@@ -216,23 +385,439 @@ const OpenAPISpec = `{
           },
           "provider": {
             "type": "string",
-            "description": "AI provider to use for SQL generation. If omitted, uses the default configured provider.",
-            "enum": ["claude", "gemini", "codex", "continue", "opencode"],
+            "description": "AI provider to use for SQL generation. If omitted, uses the default configured provider. 'anthropic' is only available when TEXT_TO_SQL_PROXY_ANTHROPIC_API_KEY is set and 'openai' when TEXT_TO_SQL_PROXY_OPENAI_BASE_URL is set. Command providers declared in TEXT_TO_SQL_PROXY_COMMANDS_FILE are accepted as well, so the names are not an enum; GET /providers lists the providers of this server.",
+            "example": "claude"
+          },
+          "strategy": {
+            "type": "string",
+            "description": "How providers are used. 'fallback' tries the provider and then its configured fallback chain. 'race' starts several providers at once and returns the first SQL that passes schema validation, if TEXT_TO_SQL_PROXY_CORRECTION_ROUNDS enables it, cancelling the others. 'consensus' asks several providers (or one provider several times) and returns the majority answer. 'critic' has the provider draft the SQL and the reviewer approve or correct it. If omitted, uses TEXT_TO_SQL_PROXY_STRATEGY.",
+            "enum": ["fallback", "race", "consensus", "critic"],
+            "example": "race"
+          },
+          "providers": {
+            "type": "array",
+            "description": "Providers to race or to ask for a consensus, at most 10. A provider may be listed several times for a consensus; duplicates race once. If omitted, race uses TEXT_TO_SQL_PROXY_RACE_PROVIDERS or every installed provider, and consensus samples the provider.",
+            "items": {
+              "type": "string"
+            },
+            "example": ["claude", "codex"]
+          },
+          "samples": {
+            "type": "integer",
+            "description": "How often the provider is asked for a consensus when 'providers' is omitted",
+            "minimum": 1,
+            "maximum": 10,
+            "default": 3
+          },
+          "reviewer": {
+            "type": "string",
+            "description": "Critic only: provider that reviews the provider's draft against the DDL and question. If omitted, uses TEXT_TO_SQL_PROXY_CRITIC_REVIEWER; requests without either are rejected.",
             "example": "claude"
+          },
+          "model": {
+            "type": "string",
+            "description": "Model to use, passed to the CLI as --model=<model> or in the API request. Letters, digits and . _ : / @ - only, not starting with -. Applies to the requested providers; providers reached through a fallback chain and critic reviewers keep their configured model. Must be listed in the provider's 'models' on GET /providers if that list is present.",
+            "pattern": "^[A-Za-z0-9._:/@][A-Za-z0-9._:/@-]*$",
+            "example": "opus"
+          },
+          "effort": {
+            "type": "string",
+            "description": "Reasoning effort for providers that support one (see 'supports_effort' on GET /providers); other providers ignore it",
+            "enum": ["low", "medium", "high"],
+            "example": "high"
+          },
+          "database": {
+            "type": "string",
+            "description": "Target database for this request, overriding TEXT_TO_SQL_PROXY_DATABASE. Must be the configured database or one listed in TEXT_TO_SQL_PROXY_ALLOWED_DATABASES (case-insensitive). Selects the dialect profile used for the prompt and the returned warnings.",
+            "example": "PostgreSQL 16"
+          },
+          "examples": {
+            "type": "array",
+            "description": "Few-shot examples for this request. They are added to the prompt before the examples picked from TEXT_TO_SQL_PROXY_EXAMPLES_FILE, within the TEXT_TO_SQL_PROXY_EXAMPLES_BUDGET token budget.",
+            "items": {
+              "$ref": "#/components/schemas/Example"
+            }
+          },
+          "sample_rows": {
+            "type": "array",
+            "description": "A few rows per table, rendered next to the DDL. At most 20 tables with 5 rows and 30 columns each are used; nested arrays and objects are sent as their JSON text, and strings longer than 80 characters are cut.",
+            "items": {
+              "$ref": "#/components/schemas/SampleRows"
+            }
+          },
+          "column_values": {
+            "type": "array",
+            "description": "Distinct values of low-cardinality columns, rendered next to the DDL. At most 50 columns with 25 values each are used; nested arrays and objects are sent as their JSON text, and strings longer than 80 characters are cut.",
+            "items": {
+              "$ref": "#/components/schemas/ColumnValues"
+            }
+          },
+          "now": {
+            "type": "string",
+            "format": "date-time",
+            "description": "Current time that relative dates such as \"last month\" refer to. Defaults to the server clock.",
+            "example": "2026-03-02T09:30:00+01:00"
+          },
+          "timezone": {
+            "type": "string",
+            "description": "IANA timezone of the dates in the question. Defaults to the offset of 'now' or the server's timezone.",
+            "example": "Europe/Berlin"
+          },
+          "session_id": {
+            "type": "string",
+            "description": "The 'session_id' of an earlier response, to ask a follow-up question that refines its SQL. 'ddl' and 'database' default to those of the conversation.",
+            "example": "3f2b8c1e9a7d4e6f8b0c2d4e6f8a0b1c"
+          },
+          "session": {
+            "type": "boolean",
+            "description": "Start a conversation: the response carries a 'session_id' for follow-up questions. Requests with neither 'session' nor 'session_id' are not kept.",
+            "default": false
+          },
+          "clarify": {
+            "type": "boolean",
+            "description": "Allow the provider to answer an ambiguous question with a clarifying question (a response of type 'clarification') instead of guessing SQL.",
+            "default": false
+          },
+          "debug": {
+            "type": "boolean",
+            "description": "Add the provider's unparsed output to the response as 'raw'.",
+            "default": false
+          }
+        }
+      },
+      "SampleRows": {
+        "type": "object",
+        "required": ["table", "columns", "rows"],
+        "properties": {
+          "table": {
+            "type": "string",
+            "example": "orders"
+          },
+          "columns": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            },
+            "example": ["id", "state"]
+          },
+          "rows": {
+            "type": "array",
+            "description": "Rows with one value per column",
+            "items": {
+              "type": "array",
+              "items": {}
+            },
+            "example": [[1, "CA"], [2, "NY"]]
+          }
+        }
+      },
+      "Correction": {
+        "type": "object",
+        "required": ["sql", "errors"],
+        "properties": {
+          "sql": {
+            "type": "string",
+            "description": "The rejected SQL",
+            "example": "SELECT region, SUM(amount) FROM orders GROUP BY region"
+          },
+          "errors": {
+            "type": "array",
+            "description": "Unknown tables or columns, ambiguous columns, a missing GROUP BY or unbalanced parentheses",
+            "items": {
+              "type": "string"
+            },
+            "example": ["column amount does not exist in orders (columns: id, region, total)"]
+          }
+        }
+      },
+      "Join": {
+        "type": "object",
+        "required": ["from", "from_column", "to", "to_column"],
+        "properties": {
+          "from": {
+            "type": "string",
+            "example": "orders"
+          },
+          "from_column": {
+            "type": "string",
+            "example": "user_id"
+          },
+          "to": {
+            "type": "string",
+            "example": "users"
+          },
+          "to_column": {
+            "type": "string",
+            "example": "id"
+          },
+          "inferred": {
+            "type": "boolean",
+            "description": "Set for joins inferred from column names and types instead of declared foreign keys"
+          }
+        }
+      },
+      "ColumnValues": {
+        "type": "object",
+        "required": ["table", "column", "values"],
+        "properties": {
+          "table": {
+            "type": "string",
+            "example": "orders"
+          },
+          "column": {
+            "type": "string",
+            "example": "state"
+          },
+          "values": {
+            "type": "array",
+            "items": {},
+            "example": ["CA", "NY", "TX"]
+          }
+        }
+      },
+      "Example": {
+        "type": "object",
+        "required": ["question", "sql"],
+        "properties": {
+          "question": {
+            "type": "string",
+            "description": "Natural language question",
+            "example": "Revenue per fiscal year"
+          },
+          "sql": {
+            "type": "string",
+            "description": "SQL answering the question",
+            "example": "SELECT fiscal_year(created_at) AS fy, SUM(total) FROM orders WHERE deleted_at IS NULL GROUP BY fy"
           }
         }
       },
       "SQLResponse": {
         "type": "object",
         "properties": {
+          "type": {
+            "type": "string",
+            "enum": ["sql", "clarification"],
+            "description": "'sql' for generated SQL, 'clarification' if the provider asked a clarifying question instead. Not set on errors.",
+            "example": "sql"
+          },
           "sql": {
             "type": "string",
             "description": "Generated DuckDB-compatible SQL query",
             "example": "SELECT * FROM users WHERE name LIKE 'A%'"
           },
+          "question": {
+            "type": "string",
+            "description": "Clarification only: the question the provider asked back. Answer it as the next question of the conversation.",
+            "example": "Do you mean gross or net revenue?"
+          },
+          "options": {
+            "type": "array",
+            "description": "Clarification only: likely answers to the question",
+            "items": {
+              "type": "string"
+            },
+            "example": ["Gross revenue", "Net revenue"]
+          },
           "error": {
             "type": "string",
             "description": "Error message if the request failed"
+          },
+          "explanation": {
+            "type": "string",
+            "description": "Structured output only: what the query does, in one or two sentences",
+            "example": "Selects the users whose name starts with an upper-case A."
+          },
+          "assumptions": {
+            "type": "array",
+            "description": "Structured output only: what the model assumed where the question or the schema is not explicit",
+            "items": {
+              "type": "string"
+            },
+            "example": ["Names are case-sensitive"]
+          },
+          "confidence": {
+            "type": "number",
+            "minimum": 0,
+            "maximum": 1,
+            "description": "Structured output only: the model's confidence that the query answers the question",
+            "example": 0.95
+          },
+          "referenced_tables": {
+            "type": "array",
+            "description": "Structured output only: the tables the query reads",
+            "items": {
+              "type": "string"
+            },
+            "example": ["users"]
+          },
+          "provider": {
+            "type": "string",
+            "description": "Provider that produced the SQL",
+            "example": "codex"
+          },
+          "model": {
+            "type": "string",
+            "description": "Model the provider was asked to use. Not set when the CLI used its default model.",
+            "example": "gpt-5-codex"
+          },
+          "duration_ms": {
+            "type": "integer",
+            "description": "How long the provider that produced the SQL took, including its correction rounds",
+            "example": 7310
+          },
+          "raw": {
+            "type": "string",
+            "description": "Only with 'debug': the provider's unparsed output"
+          },
+          "attempts": {
+            "type": "array",
+            "description": "Every provider call made for the request with its timing: the fallback chain in order, or all racing providers.",
+            "items": {
+              "$ref": "#/components/schemas/Attempt"
+            }
+          },
+          "agreement": {
+            "type": "number",
+            "description": "Consensus only: share of valid answers that match the returned SQL after normalization",
+            "example": 0.67
+          },
+          "dissent": {
+            "type": "array",
+            "description": "Consensus only: the answers that differ from the returned SQL",
+            "items": {
+              "$ref": "#/components/schemas/Variant"
+            }
+          },
+          "review": {
+            "$ref": "#/components/schemas/Review"
+          },
+          "warnings": {
+            "type": "array",
+            "description": "Constructs in the SQL that the target database's dialect profile does not support. The SQL is still returned.",
+            "items": {
+              "type": "string"
+            },
+            "example": ["QUALIFY is not supported; filter window function results in a subquery or CTE"]
+          },
+          "corrections": {
+            "type": "array",
+            "description": "Drafts of the provider that failed validation against the DDL and were sent back to it with their problems, up to TEXT_TO_SQL_PROXY_CORRECTION_ROUNDS. Only set when the server enables validation, which is off by default.",
+            "items": {
+              "$ref": "#/components/schemas/Correction"
+            }
+          },
+          "validation_errors": {
+            "type": "array",
+            "description": "Problems found in the returned SQL by validation against the DDL, left after the last correction round. The SQL is still returned.",
+            "items": {
+              "type": "string"
+            },
+            "example": ["column amount does not exist in orders (columns: id, region, total)"]
+          },
+          "tables": {
+            "type": "array",
+            "description": "Only set when the schema was pruned: the tables whose DDL was sent to the provider. Tables missing here were left out as less relevant to the question.",
+            "items": {
+              "type": "string"
+            },
+            "example": ["dim_customer", "fct_orders"]
+          },
+          "ddl_tokens_saved": {
+            "type": "integer",
+            "description": "Estimated tokens removed from the DDL by compacting a schema dump, such as pg_dump output",
+            "example": 391
+          },
+          "now": {
+            "type": "string",
+            "format": "date-time",
+            "description": "Current time given to the provider. Send it as 'now' to repeat the request with the same date ranges.",
+            "example": "2026-03-02T09:30:00+01:00"
+          },
+          "joins": {
+            "type": "array",
+            "description": "Joins on the shortest join paths between the tables the question mentions, as added to the prompt",
+            "items": {
+              "$ref": "#/components/schemas/Join"
+            }
+          },
+          "session_id": {
+            "type": "string",
+            "description": "Conversation of the request, set for requests with 'session' or 'session_id'. Send it with the next question to ask a follow-up. Not set when sessions are disabled.",
+            "example": "3f2b8c1e9a7d4e6f8b0c2d4e6f8a0b1c"
+          }
+        }
+      },
+      "Review": {
+        "type": "object",
+        "description": "Critic only: the reviewer's verdict on the generator's draft. If the reviewer failed, the draft is returned with the verdict 'unreviewed'; clients that need reviewed SQL must check the verdict.",
+        "required": ["generator", "reviewer", "verdict"],
+        "properties": {
+          "generator": {
+            "type": "string",
+            "example": "gemini"
+          },
+          "reviewer": {
+            "type": "string",
+            "example": "claude"
+          },
+          "verdict": {
+            "type": "string",
+            "description": "'approved' if the reviewer kept the draft, compared after normalization; 'corrected' if it returned other SQL, which the response carries instead of the draft; 'unreviewed' if the reviewer failed and the response carries the draft as it is",
+            "enum": ["approved", "corrected", "unreviewed"],
+            "example": "corrected"
+          },
+          "draft": {
+            "type": "string",
+            "description": "Corrected only: the generator's SQL",
+            "example": "SELECT SUM(total) FROM orders"
+          },
+          "diff": {
+            "type": "string",
+            "description": "Corrected only: line diff from the draft to the returned SQL, with lines prefixed by ' ', '-' or '+'",
+            "example": "-SELECT SUM(total) FROM orders\n+SELECT SUM(total) FROM orders WHERE status = 'paid'"
+          },
+          "rationale": {
+            "type": "string",
+            "description": "The reviewer's explanation, taken from the SQL comments it started its answer with or, without them, from the explanation it reported",
+            "example": "Only paid orders count as revenue."
+          }
+        }
+      },
+      "Variant": {
+        "type": "object",
+        "properties": {
+          "sql": {
+            "type": "string",
+            "description": "SQL as returned by the first provider that gave this answer",
+            "example": "SELECT * FROM users"
+          },
+          "providers": {
+            "type": "array",
+            "description": "Providers that gave this answer",
+            "items": {
+              "type": "string"
+            },
+            "example": ["codex"]
+          }
+        }
+      },
+      "Attempt": {
+        "type": "object",
+        "properties": {
+          "provider": {
+            "type": "string",
+            "description": "Provider name",
+            "example": "claude"
+          },
+          "error": {
+            "type": "string",
+            "description": "Why the attempt failed, if it did: 'cli failed', 'api error', 'parse error', 'timed out', 'cancelled', 'asked for clarification', 'provider returned no SQL' or 'failed'. Racing providers stopped after another one won report 'cancelled', and those that finished after it 'lost the race'. The full error is only logged by the server.",
+            "example": "cli failed"
+          },
+          "duration_ms": {
+            "type": "integer",
+            "description": "Time spent on the attempt in milliseconds",
+            "example": 1830
           }
         }
       },
@@ -270,6 +855,54 @@ const OpenAPISpec = `{
             "type": "string",
             "description": "Human-readable description of the provider",
             "example": "Anthropic Claude CLI"
+          },
+          "default_model": {
+            "type": "string",
+            "description": "Model used when a request does not name one. Omitted when the CLI's own default is used.",
+            "example": "sonnet"
+          },
+          "models": {
+            "type": "array",
+            "description": "Models a request may pick. Omitted when any model is accepted.",
+            "items": {
+              "type": "string"
+            },
+            "example": ["haiku", "sonnet", "opus"]
+          },
+          "supports_effort": {
+            "type": "boolean",
+            "description": "Whether the provider honours the request's 'effort'",
+            "example": false
+          },
+          "installed": {
+            "type": "boolean",
+            "description": "Whether the provider's CLI was found on PATH (always true for HTTP API providers)",
+            "example": true
+          },
+          "version": {
+            "type": "string",
+            "description": "First line of the CLI's --version output",
+            "example": "2.0.1 (Claude Code)"
+          },
+          "path": {
+            "type": "string",
+            "description": "Resolved path of the CLI binary",
+            "example": "/usr/local/bin/claude"
+          },
+          "authenticated": {
+            "type": "boolean",
+            "description": "Result of the auth probe. Omitted when no probe ran (probes are disabled by default or unsupported by the provider).",
+            "example": true
+          },
+          "last_error": {
+            "type": "string",
+            "description": "Error from the last availability check, if any",
+            "example": "exec: \"gemini\": executable file not found in $PATH"
+          },
+          "checked_at": {
+            "type": "string",
+            "format": "date-time",
+            "description": "When the provider was last checked"
           }
         }
       }
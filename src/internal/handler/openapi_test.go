@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -81,12 +82,16 @@ func TestHandleOpenAPI_HasPaths(t *testing.T) {
 		t.Error("missing '/generate-sql' path")
 	}
 
+	if _, ok := paths["/generate-sql/stream"]; !ok {
+		t.Error("missing '/generate-sql/stream' path")
+	}
+
 	if _, ok := paths["/openapi.json"]; !ok {
 		t.Error("missing '/openapi.json' path")
 	}
 }
 
-func TestHandleOpenAPI_HasProviderEnum(t *testing.T) {
+func TestHandleOpenAPI_ProviderIsOpen(t *testing.T) {
 	handler := newTestHandler(&mockSQLGenerator{})
 
 	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
@@ -97,23 +102,19 @@ func TestHandleOpenAPI_HasProviderEnum(t *testing.T) {
 	var spec map[string]interface{}
 	json.Unmarshal(w.Body.Bytes(), &spec)
 
-	// Navigate to components.schemas.SQLRequest.properties.provider.enum
+	// Command providers have configured names, so
+	// components.schemas.SQLRequest.properties.provider must not be an enum
 	components := spec["components"].(map[string]interface{})
 	schemas := components["schemas"].(map[string]interface{})
 	sqlRequest := schemas["SQLRequest"].(map[string]interface{})
 	properties := sqlRequest["properties"].(map[string]interface{})
 	provider := properties["provider"].(map[string]interface{})
-	enum := provider["enum"].([]interface{})
 
-	expectedProviders := []string{"claude", "gemini", "codex", "continue", "opencode"}
-	if len(enum) != len(expectedProviders) {
-		t.Errorf("expected %d providers, got %d", len(expectedProviders), len(enum))
+	if _, ok := provider["enum"]; ok {
+		t.Errorf("expected no provider enum, got %v", provider["enum"])
 	}
-
-	for i, expected := range expectedProviders {
-		if enum[i].(string) != expected {
-			t.Errorf("expected provider %q at index %d, got %q", expected, i, enum[i])
-		}
+	if description, _ := provider["description"].(string); !strings.Contains(description, "GET /providers") {
+		t.Errorf("expected the description to point to GET /providers, got %q", description)
 	}
 }
 
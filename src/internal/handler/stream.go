@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/provider"
+)
+
+// SSE event names sent by HandleGenerateSQLStream, in addition to the
+// provider.EventPartial and provider.EventProgress events.
+const (
+	eventSQL           = "sql"
+	eventClarification = "clarification"
+	eventError         = "error"
+)
+
+// sseWriter writes Server-Sent Events and flushes after each one.
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// send writes a single event with a JSON-encoded data payload.
+func (s *sseWriter) send(event string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("[ERROR] Failed to encode %s event: %v", event, err)
+		return
+	}
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+	s.flusher.Flush()
+}
+
+// HandleGenerateSQLStream handles POST /generate-sql/stream requests. It
+// streams partial output and progress as SSE events and finishes with a
+// final "sql", "clarification" or "error" event.
+func (h *Handler) HandleGenerateSQLStream(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.sendError(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	req, names, ok := h.decodeSQLRequest(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	sse := &sseWriter{w: w, flusher: flusher}
+
+	log.Printf("[INFO] Streaming SQL (%s) using %s for question: %q", req.Strategy, strings.Join(names, ", "), req.Question)
+
+	switch req.Strategy {
+	case StrategyRace:
+		sse.send(provider.EventProgress, provider.StreamEvent{Stage: "race", Text: "Racing " + strings.Join(names, ", ")})
+	case StrategyConsensus:
+		sse.send(provider.EventProgress, provider.StreamEvent{Stage: "consensus", Text: "Asking " + strings.Join(names, ", ")})
+	case StrategyCritic:
+		sse.send(provider.EventProgress, provider.StreamEvent{Stage: "critic", Text: names[0] + " drafts, " + names[1] + " reviews"})
+	}
+
+	outcome, err := h.execute(r.Context(), req, names, func(ctx context.Context, req SQLRequest, name string) (provider.Result, error) {
+		return h.streamProvider(ctx, sse, req, names, name)
+	})
+	logAttempts(outcome.Attempts)
+	if errors.Is(err, context.Canceled) {
+		log.Printf("[INFO] Request cancelled by client, provider CLI stopped")
+		return
+	}
+	var clarification *provider.Clarification
+	if errors.As(err, &clarification) {
+		log.Printf("[INFO] %s asked for clarification: %q", clarification.Provider, clarification.Question)
+		sse.send(eventClarification, h.clarificationResponse(clarification, outcome, req))
+		return
+	}
+	if err != nil {
+		message, _ := generationError(err)
+		sse.send(eventError, SQLResponse{Error: message, Attempts: outcome.Attempts})
+		return
+	}
+
+	log.Printf("[INFO] Successfully generated SQL using %s", outcome.Provider)
+	sse.send(eventSQL, h.successResponse(outcome, req))
+}
+
+// streamProvider generates SQL with the named provider of names, sending
+// its output as it arrives.
+func (h *Handler) streamProvider(ctx context.Context, sse *sseWriter, req SQLRequest, names []string, name string) (provider.Result, error) {
+	switch req.Strategy {
+	case StrategyFallback:
+		if name != names[0] {
+			sse.send(provider.EventProgress, provider.StreamEvent{Stage: "fallback", Text: "Trying " + name})
+		}
+	case StrategyCritic:
+		// The draft streams like a single provider; the reviewer's output
+		// would read as more of the draft, so only its stage is sent
+		if req.draft != "" {
+			sse.send(provider.EventProgress, provider.StreamEvent{Stage: "review", Text: name + " reviews the draft"})
+			return h.generate(ctx, req, name, nil)
+		}
+	default:
+		// Interleaved partial output of concurrent providers would be
+		// unreadable, so only the final SQL is sent
+		return h.generate(ctx, req, name, nil)
+	}
+
+	// Providers without streaming support only produce the final event
+	return h.generate(ctx, req, name, func(event provider.StreamEvent) {
+		sse.send(event.Type, event)
+	})
+}
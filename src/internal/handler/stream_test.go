@@ -0,0 +1,282 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/provider"
+)
+
+// mockStreamingSQLGenerator emits a fixed list of events before returning.
+type mockStreamingSQLGenerator struct {
+	mockSQLGenerator
+	events []provider.StreamEvent
+}
+
+func (m *mockStreamingSQLGenerator) GenerateSQLStream(ctx context.Context, req provider.Request, emit func(provider.StreamEvent)) (provider.Result, error) {
+	for _, event := range m.events {
+		emit(event)
+	}
+	return provider.Result{SQL: m.sql}, m.err
+}
+
+// sseEvent is a parsed Server-Sent Event.
+type sseEvent struct {
+	name string
+	data string
+}
+
+func parseSSE(t *testing.T, body string) []sseEvent {
+	t.Helper()
+
+	var events []sseEvent
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var event sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func postStream(t *testing.T, handler *Handler, body SQLRequest) *httptest.ResponseRecorder {
+	t.Helper()
+
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/generate-sql/stream", bytes.NewBuffer(data))
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQLStream(w, req)
+	return w
+}
+
+func TestHandleGenerateSQLStream_StreamsEvents(t *testing.T) {
+	mock := &mockStreamingSQLGenerator{
+		mockSQLGenerator: mockSQLGenerator{sql: "SELECT * FROM users"},
+		events: []provider.StreamEvent{
+			{Type: provider.EventProgress, Stage: "reasoning", Text: "Reading schema"},
+			{Type: provider.EventPartial, Text: "SELECT"},
+		},
+	}
+	handler := newTestHandlerWithProviders(map[string]provider.SQLGenerator{"claude": mock}, "claude")
+
+	w := postStream(t, handler, SQLRequest{DDL: "CREATE TABLE users (id INT)", Question: "Select all users"})
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected Content-Type text/event-stream, got %q", ct)
+	}
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %q", len(events), w.Body.String())
+	}
+
+	expected := []sseEvent{
+		{"progress", `{"stage":"reasoning","text":"Reading schema"}`},
+		{"partial", `{"text":"SELECT"}`},
+	}
+	for i, e := range expected {
+		if events[i] != e {
+			t.Errorf("event %d: expected %+v, got %+v", i, e, events[i])
+		}
+	}
+
+	final := decodeSSEResponse(t, events[2], "sql")
+	if final.SQL != "SELECT * FROM users" || final.Provider != "claude" {
+		t.Errorf("unexpected final event: %+v", final)
+	}
+}
+
+// decodeSSEResponse checks the event name and decodes its SQLResponse payload.
+func decodeSSEResponse(t *testing.T, event sseEvent, name string) SQLResponse {
+	t.Helper()
+
+	if event.name != name {
+		t.Fatalf("expected %s event, got %+v", name, event)
+	}
+
+	var resp SQLResponse
+	if err := json.Unmarshal([]byte(event.data), &resp); err != nil {
+		t.Fatalf("failed to decode %s event: %v", name, err)
+	}
+	return resp
+}
+
+func TestHandleGenerateSQLStream_BufferedFallback(t *testing.T) {
+	handler := newTestHandler(&mockSQLGenerator{sql: "SELECT 1"})
+
+	w := postStream(t, handler, SQLRequest{DDL: "CREATE TABLE users (id INT)", Question: "Select one"})
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 1 {
+		t.Fatalf("expected a single event, got %+v", events)
+	}
+	if resp := decodeSSEResponse(t, events[0], "sql"); resp.SQL != "SELECT 1" {
+		t.Errorf("expected SELECT 1, got %q", resp.SQL)
+	}
+}
+
+func TestHandleGenerateSQLStream_ProviderError(t *testing.T) {
+	handler := newTestHandler(&mockSQLGenerator{err: errors.New("CLI failed")})
+
+	w := postStream(t, handler, SQLRequest{DDL: "CREATE TABLE users (id INT)", Question: "Select one"})
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 1 {
+		t.Fatalf("expected a single event, got %+v", events)
+	}
+	if resp := decodeSSEResponse(t, events[0], "error"); resp.Error != "Failed to generate SQL" {
+		t.Errorf("expected 'Failed to generate SQL', got %q", resp.Error)
+	}
+}
+
+func TestHandleGenerateSQLStream_Clarification(t *testing.T) {
+	handler := newTestHandler(&mockSQLGenerator{err: &provider.Clarification{Question: "Gross or net revenue?"}})
+
+	w := postStream(t, handler, SQLRequest{DDL: "CREATE TABLE orders (total DECIMAL)", Question: "Revenue", Clarify: true})
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 1 {
+		t.Fatalf("expected a single event, got %+v", events)
+	}
+	if resp := decodeSSEResponse(t, events[0], "clarification"); resp.Type != ResponseClarification || resp.Question != "Gross or net revenue?" || resp.Provider != "claude" {
+		t.Errorf("unexpected clarification %+v", resp)
+	}
+}
+
+func TestHandleGenerateSQLStream_ValidationError(t *testing.T) {
+	handler := newTestHandler(&mockSQLGenerator{})
+
+	w := postStream(t, handler, SQLRequest{Question: "Select one"})
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON error before streaming starts, got %q", ct)
+	}
+}
+
+func TestHandleGenerateSQLStream_MethodNotAllowed(t *testing.T) {
+	handler := newTestHandler(&mockSQLGenerator{})
+
+	req := httptest.NewRequest(http.MethodGet, "/generate-sql/stream", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleGenerateSQLStream(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", w.Code)
+	}
+}
+
+func TestHandleGenerateSQLStream_Fallback(t *testing.T) {
+	providers := map[string]provider.SQLGenerator{
+		"claude": &mockSQLGenerator{err: provider.ErrCLIExecution},
+		"codex":  &mockSQLGenerator{sql: "SELECT codex"},
+	}
+	handler := New(providers, "claude", "https://sql-workbench.com",
+		WithFallbackChains(map[string][]string{"claude": {"codex"}}, 0))
+
+	w := postStream(t, handler, SQLRequest{DDL: "CREATE TABLE users (id INT)", Question: "Select one"})
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 2 {
+		t.Fatalf("expected fallback progress and sql events, got %+v", events)
+	}
+	if events[0] != (sseEvent{"progress", `{"stage":"fallback","text":"Trying codex"}`}) {
+		t.Errorf("unexpected fallback event: %+v", events[0])
+	}
+	if resp := decodeSSEResponse(t, events[1], "sql"); resp.Provider != "codex" || len(resp.Attempts) != 2 {
+		t.Errorf("unexpected final event: %+v", resp)
+	}
+}
+
+func TestHandleGenerateSQLStream_Race(t *testing.T) {
+	providers := map[string]provider.SQLGenerator{
+		"claude": &mockStreamingSQLGenerator{
+			mockSQLGenerator: mockSQLGenerator{err: provider.ErrCLIExecution},
+			events:           []provider.StreamEvent{{Type: provider.EventPartial, Text: "SEL"}},
+		},
+		"codex": &mockSQLGenerator{sql: "SELECT codex"},
+	}
+	handler := newTestHandlerWithProviders(providers, "claude")
+
+	w := postStream(t, handler, SQLRequest{DDL: "CREATE TABLE users (id INT)", Question: "Select one", Strategy: StrategyRace})
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 2 {
+		t.Fatalf("expected race progress and sql events without partials, got %+v", events)
+	}
+	if events[0] != (sseEvent{"progress", `{"stage":"race","text":"Racing claude, codex"}`}) {
+		t.Errorf("unexpected race event: %+v", events[0])
+	}
+	if resp := decodeSSEResponse(t, events[1], "sql"); resp.Provider != "codex" || len(resp.Attempts) != 2 {
+		t.Errorf("unexpected final event: %+v", resp)
+	}
+}
+
+func TestHandleGenerateSQLStream_Critic(t *testing.T) {
+	providers := map[string]provider.SQLGenerator{
+		"claude": &mockStreamingSQLGenerator{
+			mockSQLGenerator: mockSQLGenerator{sql: "SELECT 1;"},
+			events:           []provider.StreamEvent{{Type: provider.EventPartial, Text: "SELECT 1;"}},
+		},
+		"gemini": &mockStreamingSQLGenerator{
+			mockSQLGenerator: mockSQLGenerator{sql: "SELECT 1"},
+			events:           []provider.StreamEvent{{Type: provider.EventPartial, Text: "SEL"}},
+		},
+	}
+	handler := newTestHandlerWithProviders(providers, "gemini")
+
+	w := postStream(t, handler, SQLRequest{DDL: "CREATE TABLE users (id INT)", Question: "Select one", Strategy: StrategyCritic, Reviewer: "claude"})
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 4 {
+		t.Fatalf("expected critic progress, the draft's partial, review progress and sql events, got %+v", events)
+	}
+	expected := []sseEvent{
+		{"progress", `{"stage":"critic","text":"gemini drafts, claude reviews"}`},
+		{"partial", `{"text":"SEL"}`},
+		{"progress", `{"stage":"review","text":"claude reviews the draft"}`},
+	}
+	for i, e := range expected {
+		if events[i] != e {
+			t.Errorf("event %d: expected %+v, got %+v", i, e, events[i])
+		}
+	}
+	if resp := decodeSSEResponse(t, events[3], "sql"); resp.Provider != "gemini" || resp.Review == nil || resp.Review.Verdict != "approved" {
+		t.Errorf("expected gemini's approved draft, got %+v", resp)
+	}
+}
+
+func TestHandleGenerateSQLStream_Correction(t *testing.T) {
+	claude := &draftingSQLGenerator{drafts: []string{"SELECT amount FROM orders", "SELECT total FROM orders"}}
+	handler := New(map[string]provider.SQLGenerator{"claude": claude}, "claude", "https://sql-workbench.com", WithCorrection(1))
+
+	w := postStream(t, handler, SQLRequest{DDL: "CREATE TABLE orders (id INT, total DECIMAL)", Question: "Order totals"})
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 2 {
+		t.Fatalf("expected a correction and the final event, got %+v", events)
+	}
+	expected := sseEvent{"progress", `{"stage":"correction","text":"Correcting: column amount does not exist in orders (columns: id, total)"}`}
+	if events[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, events[0])
+	}
+	if resp := decodeSSEResponse(t, events[1], "sql"); resp.SQL != "SELECT total FROM orders" || len(resp.Corrections) != 1 {
+		t.Errorf("unexpected final event: %+v", resp)
+	}
+}
@@ -0,0 +1,155 @@
+// Package hints holds data-derived context a request can send along with
+// its DDL: sample rows per table and the distinct values of low-cardinality
+// columns. Both are capped in size before they reach a prompt.
+package hints
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Size caps applied by Truncate.
+const (
+	MaxSampleTables  = 20
+	MaxSampleRows    = 5
+	MaxSampleColumns = 30
+	MaxColumnSets    = 50
+	MaxColumnValues  = 25
+	// MaxValueLength is the length in runes, including a trailing "...",
+	// that longer string values are cut to.
+	MaxValueLength = 80
+)
+
+// SampleRows holds a few rows of a table. Each row has one value per column.
+type SampleRows struct {
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+// ColumnValues holds the distinct values of a column.
+type ColumnValues struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	Values []any  `json:"values"`
+}
+
+// Validate checks that samples and column values name their table and
+// column and that every sample row matches its columns.
+func Validate(samples []SampleRows, values []ColumnValues) error {
+	for _, s := range samples {
+		if s.Table == "" || len(s.Columns) == 0 {
+			return fmt.Errorf("sample rows need 'table' and 'columns' fields")
+		}
+		for _, row := range s.Rows {
+			if len(row) != len(s.Columns) {
+				return fmt.Errorf("sample row of table %s has %d values, expected %d", s.Table, len(row), len(s.Columns))
+			}
+		}
+	}
+	for _, v := range values {
+		if v.Table == "" || v.Column == "" {
+			return fmt.Errorf("column values need 'table' and 'column' fields")
+		}
+	}
+	return nil
+}
+
+// Truncate applies the size caps, dropping tables, rows, columns and values
+// beyond them and shortening long strings. Nested arrays and objects, e.g.
+// the values of JSON columns, become their JSON text and are shortened like
+// strings. It reports whether anything was cut. The arguments are not
+// modified.
+func Truncate(samples []SampleRows, values []ColumnValues) ([]SampleRows, []ColumnValues, bool) {
+	truncated := false
+	if len(samples) > MaxSampleTables {
+		samples, truncated = samples[:MaxSampleTables], true
+	}
+	if len(values) > MaxColumnSets {
+		values, truncated = values[:MaxColumnSets], true
+	}
+
+	cutSamples := make([]SampleRows, len(samples))
+	for i, s := range samples {
+		rows, columns := s.Rows, s.Columns
+		if len(rows) > MaxSampleRows {
+			rows, truncated = rows[:MaxSampleRows], true
+		}
+		if len(columns) > MaxSampleColumns {
+			columns, truncated = columns[:MaxSampleColumns], true
+		}
+		cut := SampleRows{Table: s.Table, Columns: columns, Rows: make([][]any, len(rows))}
+		for j, row := range rows {
+			var t bool
+			cut.Rows[j], t = truncateValues(row[:min(len(row), len(columns))])
+			truncated = truncated || t
+		}
+		cutSamples[i] = cut
+	}
+
+	cutValues := make([]ColumnValues, len(values))
+	for i, v := range values {
+		vals := v.Values
+		if len(vals) > MaxColumnValues {
+			vals, truncated = vals[:MaxColumnValues], true
+		}
+		cut := ColumnValues{Table: v.Table, Column: v.Column}
+		var t bool
+		cut.Values, t = truncateValues(vals)
+		truncated = truncated || t
+		cutValues[i] = cut
+	}
+
+	return cutSamples, cutValues, truncated
+}
+
+// truncateValues returns a copy of values with nested values turned into
+// their JSON text and long strings shortened.
+func truncateValues(values []any) ([]any, bool) {
+	truncated := false
+	cut := make([]any, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case []any, map[string]any:
+			data, _ := json.Marshal(v)
+			value = string(data)
+		}
+		if s, ok := value.(string); ok {
+			if runes := []rune(s); len(runes) > MaxValueLength {
+				value, truncated = string(runes[:MaxValueLength-3])+"...", true
+			}
+		}
+		cut[i] = value
+	}
+	return cut, truncated
+}
+
+// Literals formats values as comma-separated SQL literals, e.g.
+// 'CA', 42, NULL.
+func Literals(values []any) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = literal(value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// literal formats a JSON-decoded value as an SQL literal.
+func literal(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return fmt.Sprint(value)
+}
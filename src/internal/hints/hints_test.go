@@ -0,0 +1,137 @@
+package hints
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []SampleRows
+		values  []ColumnValues
+		valid   bool
+	}{
+		{
+			name:    "valid",
+			samples: []SampleRows{{Table: "orders", Columns: []string{"id", "state"}, Rows: [][]any{{1.0, "CA"}}}},
+			values:  []ColumnValues{{Table: "orders", Column: "state", Values: []any{"CA", "NY"}}},
+			valid:   true,
+		},
+		{
+			name:    "row does not match columns",
+			samples: []SampleRows{{Table: "orders", Columns: []string{"id", "state"}, Rows: [][]any{{1.0}}}},
+		},
+		{
+			name:    "sample without table",
+			samples: []SampleRows{{Columns: []string{"id"}}},
+		},
+		{
+			name:   "values without column",
+			values: []ColumnValues{{Table: "orders", Values: []any{"CA"}}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate(tc.samples, tc.values)
+			if tc.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	rows := make([][]any, MaxSampleRows+3)
+	for i := range rows {
+		rows[i] = []any{strings.Repeat("x", MaxValueLength+10)}
+	}
+	samples := []SampleRows{{Table: "notes", Columns: []string{"body"}, Rows: rows}}
+	values := []ColumnValues{{Table: "orders", Column: "state", Values: make([]any, MaxColumnValues+1)}}
+
+	cutSamples, cutValues, truncated := Truncate(samples, values)
+
+	if !truncated {
+		t.Error("expected truncation to be reported")
+	}
+	if len(cutSamples[0].Rows) != MaxSampleRows {
+		t.Errorf("expected %d rows, got %d", MaxSampleRows, len(cutSamples[0].Rows))
+	}
+	if got := cutSamples[0].Rows[0][0].(string); len(got) != MaxValueLength || !strings.HasSuffix(got, "...") {
+		t.Errorf("expected a shortened value, got %q", got)
+	}
+	if len(cutValues[0].Values) != MaxColumnValues {
+		t.Errorf("expected %d values, got %d", MaxColumnValues, len(cutValues[0].Values))
+	}
+	if len(samples[0].Rows[0][0].(string)) != MaxValueLength+10 {
+		t.Error("expected the input to be left unchanged")
+	}
+
+	if _, _, truncated := Truncate(cutSamples, cutValues); truncated {
+		t.Error("expected no truncation within the caps")
+	}
+}
+
+func TestTruncate_Columns(t *testing.T) {
+	columns := make([]string, MaxSampleColumns+10)
+	row := make([]any, len(columns))
+	for i := range columns {
+		columns[i] = fmt.Sprintf("c%d", i)
+		row[i] = float64(i)
+	}
+	samples := []SampleRows{{Table: "wide", Columns: columns, Rows: [][]any{row, row}}}
+
+	cutSamples, _, truncated := Truncate(samples, nil)
+
+	if !truncated {
+		t.Error("expected truncation to be reported")
+	}
+	cut := cutSamples[0]
+	if len(cut.Columns) != MaxSampleColumns || cut.Columns[MaxSampleColumns-1] != fmt.Sprintf("c%d", MaxSampleColumns-1) {
+		t.Errorf("expected the first %d columns, got %v", MaxSampleColumns, cut.Columns)
+	}
+	for _, row := range cut.Rows {
+		if len(row) != MaxSampleColumns {
+			t.Errorf("expected rows cut to %d values, got %d", MaxSampleColumns, len(row))
+		}
+	}
+	if len(samples[0].Columns) != MaxSampleColumns+10 || len(samples[0].Rows[0]) != MaxSampleColumns+10 {
+		t.Error("expected the input to be left unchanged")
+	}
+}
+
+func TestTruncate_Nested(t *testing.T) {
+	long := make([]any, MaxValueLength)
+	for i := range long {
+		long[i] = "x"
+	}
+	values := []ColumnValues{{Table: "events", Column: "payload", Values: []any{
+		map[string]any{"tags": []any{"a", "b"}},
+		long,
+	}}}
+
+	_, cutValues, truncated := Truncate(nil, values)
+
+	if !truncated {
+		t.Error("expected truncation to be reported")
+	}
+	if got := cutValues[0].Values[0]; got != `{"tags":["a","b"]}` {
+		t.Errorf("expected the object's JSON text, got %#v", got)
+	}
+	if got, ok := cutValues[0].Values[1].(string); !ok || len(got) != MaxValueLength || !strings.HasPrefix(got, `["x","x"`) || !strings.HasSuffix(got, "...") {
+		t.Errorf("expected the array's shortened JSON text, got %#v", cutValues[0].Values[1])
+	}
+}
+
+func TestLiterals(t *testing.T) {
+	got := Literals([]any{"CA", "O'Brien", 42.0, 1.5, true, nil})
+	expected := "'CA', 'O''Brien', 42, 1.5, TRUE, NULL"
+	if got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
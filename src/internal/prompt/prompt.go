@@ -0,0 +1,235 @@
+// Package prompt renders the prompts sent to providers from text/template
+// files. Defaults are embedded in the binary and can be overridden per
+// provider and per target database from a directory.
+package prompt
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/dialect"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/examples"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/hints"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/schema"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/semantic"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/session"
+)
+
+// Template names. Providers with a separate system prompt (claude,
+// anthropic, openai) use System, Schema and Question; CLIs that take a
+// single prompt use Prompt.
+const (
+	System   = "system"
+	Schema   = "schema"
+	Question = "question"
+	Prompt   = "prompt"
+)
+
+// Ways of expressing relative dates such as "last month", selected by
+// Data.Dates: as literals computed from Data.Now, which keeps date ranges
+// reproducible, or with the database's date functions.
+const (
+	DatesLiteral   = "literal"
+	DatesFunctions = "functions"
+)
+
+// templateExt is the file extension of prompt templates.
+const templateExt = ".tmpl"
+
+//go:embed templates/*.tmpl
+var defaults embed.FS
+
+// Data is the data prompt templates are executed with. Values are inserted
+// verbatim and never parsed as templates themselves, so a DDL or question
+// containing "%s" or "{{" cannot change the prompt's structure.
+type Data struct {
+	Provider string
+	Database string
+	// Dialect is the profile of Database, or nil for databases without one.
+	Dialect *dialect.Profile
+	DDL     string
+	// SampleRows and ColumnValues are data from the schema's tables.
+	SampleRows   []hints.SampleRows
+	ColumnValues []hints.ColumnValues
+	Question     string
+	// Examples are few-shot question/SQL pairs relevant to Question.
+	Examples []examples.Example
+	// Semantic holds the semantic layer entries relevant to Question.
+	Semantic semantic.Layer
+	// Joins are the join paths between the tables Question mentions.
+	Joins []schema.Join
+	// Now is the current time in the request's timezone, named by Timezone
+	// if the request set one; the zero time leaves the date out. Dates is
+	// DatesLiteral or DatesFunctions.
+	Now      time.Time
+	Timezone string
+	Dates    string
+	// History holds the earlier turns of the conversation Question follows
+	// up on.
+	History []session.Turn
+	// Clarify allows the model to answer an ambiguous question with a
+	// clarifying question instead of SQL.
+	Clarify bool
+	// Corrections are the provider's earlier answers to Question that failed
+	// validation against the DDL, with their problems.
+	Corrections []schema.Correction
+	// Draft is SQL another model generated for Question, for this one to
+	// review and correct.
+	Draft string
+}
+
+// databaseKeys returns the names database-specific templates are looked up
+// by, most specific first: the profile name and engine (e.g. postgresql-12
+// and postgresql), or the lower-cased database.
+func (d Data) databaseKeys() []string {
+	if d.Dialect != nil {
+		return []string{d.Dialect.Name, d.Dialect.Engine}
+	}
+	return []string{strings.ToLower(d.Database)}
+}
+
+// funcs are the functions available to templates.
+var funcs = template.FuncMap{
+	"join":     strings.Join,
+	"literals": hints.Literals,
+}
+
+// Set holds parsed prompt templates keyed by their path relative to the
+// prompt directory, e.g. "prompt.tmpl" or "codex/prompt.duckdb.tmpl".
+//
+// Files whose name starts with an underscore are partials: they are not
+// looked up by Render, but every template can include them by path, e.g.
+// {{template "_dialect.tmpl" .}}.
+type Set struct {
+	templates map[string]*template.Template
+}
+
+// Default returns the embedded default templates.
+func Default() *Set {
+	sources := make(map[string]string)
+	if err := readSources(defaults, "templates", sources); err != nil {
+		panic(err)
+	}
+	set, err := compile(sources)
+	if err != nil {
+		panic(err)
+	}
+	return set
+}
+
+// Load returns the embedded defaults overridden by the *.tmpl files in dir.
+// An empty dir only loads the defaults.
+//
+// A template named N for provider P and database D is looked up as, in
+// order: P/N.D.tmpl, P/N.tmpl, N.D.tmpl and N.tmpl. D is the dialect
+// profile name, then its engine (e.g. prompt.postgresql-12.tmpl, then
+// prompt.postgresql.tmpl), or the lower-cased database without a profile.
+func Load(dir string) (*Set, error) {
+	sources := make(map[string]string)
+	if err := readSources(defaults, "templates", sources); err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := readSources(os.DirFS(dir), ".", sources); err != nil {
+			return nil, err
+		}
+	}
+	return compile(sources)
+}
+
+// readSources reads every template below root in fsys into sources, keyed
+// by its path relative to root.
+func readSources(fsys fs.FS, root string, sources map[string]string) error {
+	return fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != templateExt {
+			return nil
+		}
+
+		src, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+
+		sources[strings.TrimPrefix(strings.TrimPrefix(p, root), "/")] = string(src)
+		return nil
+	})
+}
+
+// isPartial reports whether the template at key is a partial.
+func isPartial(key string) bool {
+	return strings.HasPrefix(path.Base(key), "_")
+}
+
+// compile parses the partials into a shared base and every other template
+// into its own copy of that base.
+func compile(sources map[string]string) (*Set, error) {
+	base := template.New("").Funcs(funcs).Option("missingkey=error")
+	for key, src := range sources {
+		if !isPartial(key) {
+			continue
+		}
+		if _, err := base.New(key).Parse(src); err != nil {
+			return nil, fmt.Errorf("prompt template %s: %w", key, err)
+		}
+	}
+
+	set := &Set{templates: make(map[string]*template.Template)}
+	for key, src := range sources {
+		if isPartial(key) {
+			continue
+		}
+		tmpl, err := template.Must(base.Clone()).New(key).Parse(src)
+		if err != nil {
+			return nil, fmt.Errorf("prompt template %s: %w", key, err)
+		}
+		set.templates[key] = tmpl
+	}
+	return set, nil
+}
+
+// Render executes the most specific template called name for the data's
+// provider and database. Surrounding whitespace is trimmed from the result.
+func (s *Set) Render(name string, data Data) (string, error) {
+	tmpl := s.lookup(name, data.Provider, data.databaseKeys())
+	if tmpl == nil {
+		return "", fmt.Errorf("prompt template %s not found", name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// lookup returns the most specific template called name, or nil.
+func (s *Set) lookup(name, provider string, databases []string) *template.Template {
+	var candidates []string
+	if provider != "" {
+		for _, database := range databases {
+			candidates = append(candidates, provider+"/"+name+"."+database+templateExt)
+		}
+		candidates = append(candidates, provider+"/"+name+templateExt)
+	}
+	for _, database := range databases {
+		candidates = append(candidates, name+"."+database+templateExt)
+	}
+	candidates = append(candidates, name+templateExt)
+
+	for _, key := range candidates {
+		if tmpl, ok := s.templates[key]; ok {
+			return tmpl
+		}
+	}
+	return nil
+}
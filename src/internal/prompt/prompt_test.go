@@ -0,0 +1,388 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/dialect"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/examples"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/hints"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/schema"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/semantic"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/session"
+)
+
+func writeTemplate(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefault_Prompt(t *testing.T) {
+	text, err := Default().Render(Prompt, Data{
+		Database: "DuckDB",
+		DDL:      "CREATE TABLE users (id INT)",
+		Question: "Select all users",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"You are a DuckDB expert.",
+		"DDL: CREATE TABLE users (id INT)\nQuestion: Select all users",
+		"Respond with ONLY the SQL query.",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected prompt to contain %q, got %q", want, text)
+		}
+	}
+	if strings.HasSuffix(text, "\n") {
+		t.Errorf("expected trailing whitespace to be trimmed, got %q", text)
+	}
+}
+
+func TestRender_ValuesAreNotTemplates(t *testing.T) {
+	data := Data{
+		Database: "DuckDB",
+		DDL:      "CREATE TABLE t (ts TIMESTAMP); -- strftime(ts, '%s') {{.Question}}",
+		Question: "%s",
+	}
+
+	text, err := Default().Render(Prompt, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(text, "DDL: "+data.DDL+"\nQuestion: %s") {
+		t.Errorf("expected DDL and question verbatim, got %q", text)
+	}
+}
+
+func TestLoad_Overrides(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "prompt.tmpl", "global {{.Question}}")
+	writeTemplate(t, dir, "prompt.postgresql.tmpl", "postgres {{.Question}}")
+	writeTemplate(t, dir, "codex/prompt.tmpl", "codex {{.Question}}")
+	writeTemplate(t, dir, "codex/prompt.postgresql.tmpl", "codex postgres {{.Question}}")
+	writeTemplate(t, dir, "notes.txt", "{{ not a template")
+
+	set, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		provider string
+		database string
+		expected string
+	}{
+		{"codex", "PostgreSQL", "codex postgres q"},
+		{"codex", "DuckDB", "codex q"},
+		{"gemini", "PostgreSQL", "postgres q"},
+		{"gemini", "DuckDB", "global q"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.provider+"/"+tc.database, func(t *testing.T) {
+			text, err := set.Render(Prompt, Data{Provider: tc.provider, Database: tc.database, Question: "q"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if text != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, text)
+			}
+		})
+	}
+
+	// Templates that are not overridden keep their defaults
+	text, err := set.Render(Question, Data{Question: "q"})
+	if err != nil || text != "Question: q" {
+		t.Errorf("expected default question template, got %q (%v)", text, err)
+	}
+}
+
+func TestRender_Dialect(t *testing.T) {
+	text, err := Default().Render(Prompt, Data{
+		Database: "PostgreSQL 12",
+		Dialect:  dialect.Lookup("PostgreSQL 12"),
+		DDL:      "CREATE TABLE users (id INT)",
+		Question: "Select all users",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"Target PostgreSQL 12 (version 12).",
+		"Supported functions and syntax: date_trunc,",
+		"- Don't use QUALIFY, GROUP BY ALL or SELECT * EXCLUDE\n",
+		"- Don't quote identifiers with backticks\n\nDDL:",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected prompt to contain %q, got %q", want, text)
+		}
+	}
+}
+
+func TestRender_Examples(t *testing.T) {
+	data := Data{
+		Database: "DuckDB",
+		DDL:      "CREATE TABLE users (id INT, deleted_at TIMESTAMP)",
+		Question: "Count users",
+		Examples: []examples.Example{{Question: "List users", SQL: "SELECT * FROM users WHERE deleted_at IS NULL"}},
+	}
+
+	text, err := Default().Render(Prompt, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "Question: List users\nSQL: SELECT * FROM users WHERE deleted_at IS NULL\n\nQuestion: Count users"
+	if !strings.Contains(text, want) {
+		t.Errorf("expected prompt to contain %q, got %q", want, text)
+	}
+
+	text, err = Default().Render(Question, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(text, "Examples") || !strings.HasSuffix(text, want) {
+		t.Errorf("expected examples before the question, got %q", text)
+	}
+}
+
+func TestRender_DataHints(t *testing.T) {
+	text, err := Default().Render(Schema, Data{
+		DDL:          "CREATE TABLE orders (id INT, state TEXT)",
+		SampleRows:   []hints.SampleRows{{Table: "orders", Columns: []string{"id", "state"}, Rows: [][]any{{1.0, "CA"}}}},
+		ColumnValues: []hints.ColumnValues{{Table: "orders", Column: "state", Values: []any{"CA", "NY"}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "DDL: CREATE TABLE orders (id INT, state TEXT)\n\n" +
+		"Sample rows:\norders (id, state):\n  (1, 'CA')\n\n" +
+		"Distinct column values:\n- orders.state: 'CA', 'NY'"
+	if text != expected {
+		t.Errorf("expected %q, got %q", expected, text)
+	}
+}
+
+func TestRender_Semantic(t *testing.T) {
+	text, err := Default().Render(Question, Data{
+		Question: "Net revenue per customer",
+		Semantic: semantic.Layer{
+			Terms:   []semantic.Term{{Term: "customer", Synonyms: []string{"client"}, RefersTo: "dim_account"}},
+			Metrics: []semantic.Metric{{Name: "net revenue", Definition: "SUM(total) - SUM(refunds)"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "Business terms:\n- customer (also: client) refers to dim_account\n\n" +
+		"Metric definitions, use them exactly:\n- net revenue = SUM(total) - SUM(refunds)\n\n" +
+		"Question: Net revenue per customer"
+	if text != expected {
+		t.Errorf("expected %q, got %q", expected, text)
+	}
+}
+
+func TestRender_Joins(t *testing.T) {
+	text, err := Default().Render(Question, Data{
+		Question: "Revenue per user",
+		Joins: []schema.Join{
+			{From: "orders", FromColumn: "user_id", To: "users", ToColumn: "id", Inferred: true},
+			{From: "payments", FromColumn: "order_id", To: "orders", ToColumn: "id"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "Join paths between the tables the question mentions:\n" +
+		"- orders.user_id = users.id (inferred from the column names)\n" +
+		"- payments.order_id = orders.id\n\n" +
+		"Question: Revenue per user"
+	if text != expected {
+		t.Errorf("expected %q, got %q", expected, text)
+	}
+}
+
+func TestRender_Time(t *testing.T) {
+	now := time.Date(2026, 3, 2, 9, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		name     string
+		dates    string
+		expected string
+	}{
+		{
+			name:     "literal",
+			expected: "Current date and time: Monday, 2026-03-02 09:30:00 +01:00 (Europe/Berlin).\nWrite relative dates such as \"yesterday\" or \"last month\" as date literals computed from this date, not with functions such as CURRENT_DATE or NOW().\n\nQuestion: Orders of last month",
+		},
+		{
+			name:     "functions",
+			dates:    DatesFunctions,
+			expected: "Current date and time: Monday, 2026-03-02 09:30:00 +01:00 (Europe/Berlin).\nExpress relative dates such as \"yesterday\" or \"last month\" with the database's date functions, converted to this timezone.\n\nQuestion: Orders of last month",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			text, err := Default().Render(Question, Data{Question: "Orders of last month", Now: now, Timezone: "Europe/Berlin", Dates: tc.dates})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if text != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, text)
+			}
+		})
+	}
+}
+
+func TestRender_History(t *testing.T) {
+	text, err := Default().Render(Question, Data{
+		Question: "Now break that down by month",
+		History:  []session.Turn{{Question: "Revenue per region", SQL: "SELECT region, SUM(total) FROM orders GROUP BY region"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "Earlier questions of this conversation and the SQL generated for them:\n\n" +
+		"Question: Revenue per region\nSQL: SELECT region, SUM(total) FROM orders GROUP BY region\n\n" +
+		"The question below follows up on them: refine the last query unless it asks something new.\n\n" +
+		"Question: Now break that down by month"
+	if text != expected {
+		t.Errorf("expected %q, got %q", expected, text)
+	}
+
+	text, err = Default().Render(Question, Data{
+		Question: "Net revenue",
+		History:  []session.Turn{{Question: "Revenue per region", Clarification: "Do you mean gross or net revenue?"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(text, "Question: Revenue per region\nYou asked back: Do you mean gross or net revenue?\n") {
+		t.Errorf("expected the clarifying question in the history, got %q", text)
+	}
+}
+
+func TestRender_Corrections(t *testing.T) {
+	text, err := Default().Render(Question, Data{
+		Question:    "Revenue per region",
+		Corrections: []schema.Correction{{SQL: "SELECT region, SUM(amount) FROM orders GROUP BY region", Errors: []string{"column amount does not exist in orders (columns: id, region, total)"}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "Your previous answers to this question failed validation against the DDL:\n\n" +
+		"SQL: SELECT region, SUM(amount) FROM orders GROUP BY region\nProblems:\n" +
+		"- column amount does not exist in orders (columns: id, region, total)\n\n" +
+		"Correct the query: use only the tables and columns the DDL defines.\n\n" +
+		"Question: Revenue per region"
+	if text != expected {
+		t.Errorf("expected %q, got %q", expected, text)
+	}
+}
+
+func TestRender_Review(t *testing.T) {
+	text, err := Default().Render(Question, Data{Question: "Revenue this year", Draft: "SELECT SUM(total) FROM orders"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.HasPrefix(text, "Another model drafted this SQL for the question below:\n\nSELECT SUM(total) FROM orders\n\nReview the draft") ||
+		!strings.HasSuffix(text, "explain what you changed and why.\n\nQuestion: Revenue this year") {
+		t.Errorf("expected the draft to review before the question, got %q", text)
+	}
+
+	// The comments explaining a correction are the only explanations allowed
+	for _, name := range []string{System, Prompt} {
+		text, err := Default().Render(name, Data{Database: "DuckDB", Question: "Revenue this year", Draft: "SELECT SUM(total) FROM orders"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(text, "no explanations other than the comments a corrected draft starts with") {
+			t.Errorf("expected %s to allow the review's comments, got %q", name, text)
+		}
+	}
+}
+
+func TestRender_Clarify(t *testing.T) {
+	for _, name := range []string{System, Prompt} {
+		t.Run(name, func(t *testing.T) {
+			data := Data{Database: "DuckDB", DDL: "CREATE TABLE orders (id INT)", Question: "Revenue per region"}
+			text, err := Default().Render(name, data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Contains(text, "clarification") {
+				t.Errorf("expected no clarification instructions by default, got %q", text)
+			}
+
+			data.Clarify = true
+			text, err = Default().Render(name, data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(text, `{"type": "clarification", "question": "...", "options": ["...", "..."]}`) {
+				t.Errorf("expected clarification instructions, got %q", text)
+			}
+		})
+	}
+}
+
+func TestLoad_DialectEngineOverride(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "system.postgresql.tmpl", "postgres {{template \"_dialect.tmpl\" .}}")
+	writeTemplate(t, dir, "system.postgresql-12.tmpl", "postgres 12")
+
+	set, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	text, _ := set.Render(System, Data{Dialect: dialect.Lookup("PostgreSQL 12")})
+	if text != "postgres 12" {
+		t.Errorf("expected the versioned template, got %q", text)
+	}
+
+	text, _ = set.Render(System, Data{Dialect: dialect.Lookup("PostgreSQL 16")})
+	if !strings.HasPrefix(text, "postgres \nTarget PostgreSQL 16") {
+		t.Errorf("expected the engine template with the dialect partial, got %q", text)
+	}
+}
+
+func TestLoad_InvalidTemplate(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "system.tmpl", "You are a {{.Database expert")
+
+	if _, err := Load(dir); err == nil {
+		t.Error("expected an error for an invalid template")
+	}
+}
+
+func TestLoad_MissingDirectory(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestRender_UnknownTemplate(t *testing.T) {
+	if _, err := Default().Render("missing", Data{}); err == nil {
+		t.Error("expected an error for an unknown template")
+	}
+}
@@ -0,0 +1,226 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
+)
+
+const (
+	anthropicAPIVersion = "2023-06-01"
+	anthropicMaxTokens  = 4096
+	anthropicToolName   = "return_sql"
+)
+
+// AnthropicClient implements SQLGenerator using the Anthropic Messages API.
+type AnthropicClient struct {
+	database   string
+	prompts    *prompt.Set
+	apiKey     string
+	baseURL    string
+	model      string
+	httpClient *http.Client
+}
+
+// NewAnthropicClient creates a new Anthropic Messages API client.
+func NewAnthropicClient(database string, prompts *prompt.Set, apiKey, baseURL, model string) *AnthropicClient {
+	return &AnthropicClient{
+		database:   database,
+		prompts:    prompts,
+		apiKey:     apiKey,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		model:      model,
+		httpClient: http.DefaultClient,
+	}
+}
+
+// CheckAuth lists the available models, which requires a valid API key but
+// costs no tokens.
+func (c *AnthropicClient) CheckAuth(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/v1/models", nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("x-api-key", c.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicAPIVersion)
+
+	if _, err := doAPIRequest(c.httpClient, httpReq); err != nil {
+		return errors.Join(ErrNotAuthenticated, err)
+	}
+	return nil
+}
+
+// anthropicTextBlock is a text content block, optionally marked as a prompt
+// caching breakpoint.
+type anthropicTextBlock struct {
+	Type         string                 `json:"type"`
+	Text         string                 `json:"text"`
+	CacheControl *anthropicCacheControl `json:"cache_control,omitempty"`
+}
+
+type anthropicCacheControl struct {
+	Type string `json:"type"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type anthropicMessage struct {
+	Role    string               `json:"role"`
+	Content []anthropicTextBlock `json:"content"`
+}
+
+// anthropicRequest is the body of a POST /v1/messages request.
+type anthropicRequest struct {
+	Model      string               `json:"model"`
+	MaxTokens  int                  `json:"max_tokens"`
+	System     []anthropicTextBlock `json:"system"`
+	Tools      []anthropicTool      `json:"tools"`
+	ToolChoice anthropicToolChoice  `json:"tool_choice"`
+	Messages   []anthropicMessage   `json:"messages"`
+}
+
+// GenerateSQL calls the Anthropic Messages API to generate SQL from DDL and a question.
+func (c *AnthropicClient) GenerateSQL(ctx context.Context, req Request) (Result, error) {
+	payload, err := c.buildRequest(req)
+	if err != nil {
+		return Result{}, err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Result{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", c.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicAPIVersion)
+
+	data, err := doAPIRequest(c.httpClient, httpReq)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result, err := parseAnthropicResponse(data)
+	if err != nil {
+		return Result{}, err
+	}
+	result.Model, result.Raw = payload.Model, string(data)
+
+	return result, nil
+}
+
+// buildRequest builds the Messages API payload. The DDL block carries a cache
+// breakpoint, so the tool definition, system prompt and schema are cached
+// across questions about the same schema.
+func (c *AnthropicClient) buildRequest(req Request) (anthropicRequest, error) {
+	parts, err := renderPrompts(c.prompts, promptData("anthropic", c.database, req), prompt.System, prompt.Schema, prompt.Question)
+	if err != nil {
+		return anthropicRequest{}, err
+	}
+
+	model := c.model
+	if req.Model != "" {
+		model = req.Model
+	}
+	description := "Return the generated SQL query."
+	if req.Clarify {
+		description = "Return the generated SQL query, or a clarifying question if the question is ambiguous."
+	}
+
+	return anthropicRequest{
+		Model:     model,
+		MaxTokens: anthropicMaxTokens,
+		System: []anthropicTextBlock{
+			{Type: "text", Text: parts[0]},
+		},
+		Tools: []anthropicTool{{
+			Name:        anthropicToolName,
+			Description: description,
+			InputSchema: json.RawMessage(answerSchema(req.Clarify, false)),
+		}},
+		ToolChoice: anthropicToolChoice{Type: "tool", Name: anthropicToolName},
+		Messages: []anthropicMessage{{
+			Role: "user",
+			Content: []anthropicTextBlock{
+				{Type: "text", Text: parts[1], CacheControl: &anthropicCacheControl{Type: "ephemeral"}},
+				{Type: "text", Text: parts[2]},
+			},
+		}},
+	}, nil
+}
+
+// parseAnthropicResponse extracts the SQL from a Messages API response.
+func parseAnthropicResponse(data []byte) (Result, error) {
+	// Anthropic returns: {"content": [{"type": "tool_use", "input": {"sql": "..."}}, ...], ...}
+	var response struct {
+		Content []struct {
+			Type  string           `json:"type"`
+			Text  string           `json:"text"`
+			Input structuredAnswer `json:"input"`
+		} `json:"content"`
+	}
+
+	if err := json.Unmarshal(data, &response); err != nil {
+		return Result{}, errors.Join(ErrParsing, err)
+	}
+
+	var text strings.Builder
+	for _, block := range response.Content {
+		switch block.Type {
+		case "tool_use":
+			if result, err := block.Input.result(); !errors.Is(err, ErrParsing) {
+				return result, err
+			}
+		case "text":
+			text.WriteString(block.Text)
+		}
+	}
+
+	// Fallback: the model answered in plain text instead of calling the tool
+	if sql := CleanSQL(text.String()); sql != "" {
+		return Result{SQL: sql}, nil
+	}
+
+	return Result{}, ErrParsing
+}
+
+// doAPIRequest sends an HTTP request to a model API and returns the response
+// body, turning transport failures and non-2xx statuses into ErrAPIRequest.
+func doAPIRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Join(ErrAPIRequest, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Join(ErrAPIRequest, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, errors.Join(ErrAPIRequest, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(data))))
+	}
+
+	return data, nil
+}
@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
+)
+
+func TestParseAnthropicResponse_ToolUse(t *testing.T) {
+	input := `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"return_sql","input":{"sql":"SELECT * FROM users"}}],"stop_reason":"tool_use"}`
+
+	result, err := parseAnthropicResponse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "SELECT * FROM users"
+	if result.SQL != expected {
+		t.Errorf("expected %q, got %q", expected, result.SQL)
+	}
+}
+
+func TestParseAnthropicResponse_TextFallback(t *testing.T) {
+	input := `{"content":[{"type":"text","text":"` + "```sql\\nSELECT 1\\n```" + `"}]}`
+
+	result, err := parseAnthropicResponse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.SQL != "SELECT 1" {
+		t.Errorf("expected %q, got %q", "SELECT 1", result.SQL)
+	}
+}
+
+func TestParseAnthropicResponse_Empty(t *testing.T) {
+	_, err := parseAnthropicResponse([]byte(`{"content":[]}`))
+	if !errors.Is(err, ErrParsing) {
+		t.Errorf("expected ErrParsing, got %v", err)
+	}
+}
+
+func TestParseAnthropicResponse_InvalidJSON(t *testing.T) {
+	_, err := parseAnthropicResponse([]byte(`not json`))
+	if !errors.Is(err, ErrParsing) {
+		t.Errorf("expected ErrParsing, got %v", err)
+	}
+}
+
+func TestAnthropicClient_GenerateSQL(t *testing.T) {
+	var got anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if key := r.Header.Get("x-api-key"); key != "test-key" {
+			t.Errorf("expected x-api-key test-key, got %q", key)
+		}
+		if version := r.Header.Get("anthropic-version"); version != anthropicAPIVersion {
+			t.Errorf("expected anthropic-version %q, got %q", anthropicAPIVersion, version)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		w.Write([]byte(`{"content":[{"type":"tool_use","name":"return_sql","input":{"sql":"SELECT COUNT(*) FROM users"}}]}`))
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient("DuckDB", prompt.Default(), "test-key", server.URL+"/", "claude-test")
+	result, err := client.GenerateSQL(context.Background(), Request{DDL: "CREATE TABLE users (id INT)", Question: "How many users?"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.SQL != "SELECT COUNT(*) FROM users" {
+		t.Errorf("unexpected SQL: %q", result.SQL)
+	}
+	if got.Model != "claude-test" {
+		t.Errorf("expected model claude-test, got %q", got.Model)
+	}
+	if got.ToolChoice.Name != anthropicToolName {
+		t.Errorf("expected forced tool %q, got %q", anthropicToolName, got.ToolChoice.Name)
+	}
+	if schema := answerSchema(false, false); string(got.Tools[0].InputSchema) != schema {
+		t.Errorf("expected tool schema %s, got %s", schema, got.Tools[0].InputSchema)
+	}
+	if result.Model != "claude-test" || !strings.Contains(result.Raw, "return_sql") {
+		t.Errorf("expected the model and raw output, got %+v", result)
+	}
+
+	content := got.Messages[0].Content
+	if len(content) != 2 {
+		t.Fatalf("expected 2 content blocks, got %d", len(content))
+	}
+	if content[0].Text != "DDL: CREATE TABLE users (id INT)" || content[0].CacheControl == nil {
+		t.Errorf("expected cached DDL block, got %+v", content[0])
+	}
+	if content[1].Text != "Question: How many users?" || content[1].CacheControl != nil {
+		t.Errorf("expected uncached question block, got %+v", content[1])
+	}
+}
+
+func TestAnthropicClient_GenerateSQL_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error"}}`))
+	}))
+	defer server.Close()
+
+	client := NewAnthropicClient("DuckDB", prompt.Default(), "test-key", server.URL, "claude-test")
+	_, err := client.GenerateSQL(context.Background(), Request{DDL: "CREATE TABLE users (id INT)", Question: "How many users?"})
+	if !errors.Is(err, ErrAPIRequest) {
+		t.Errorf("expected ErrAPIRequest, got %v", err)
+	}
+}
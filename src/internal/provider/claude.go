@@ -1,75 +1,208 @@
 package provider
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"os/exec"
 	"strings"
-)
 
-const (
-	claudeSystemPromptTemplate = "You are a %s expert. Generate ONLY raw SQL queries. No markdown, no explanations. Format the SQL nicely with 2-space indentation."
-	claudeJSONSchema           = `{"type":"object","properties":{"sql":{"type":"string"}},"required":["sql"]}`
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
 )
 
 // ClaudeClient implements SQLGenerator using the Claude CLI.
 type ClaudeClient struct {
 	database string
+	prompts  *prompt.Set
 }
 
 // NewClaudeClient creates a new Claude CLI client.
-func NewClaudeClient(database string) *ClaudeClient {
-	return &ClaudeClient{database: database}
+func NewClaudeClient(database string, prompts *prompt.Set) *ClaudeClient {
+	return &ClaudeClient{database: database, prompts: prompts}
+}
+
+// Binary returns the name of the Claude CLI executable.
+func (c *ClaudeClient) Binary() string {
+	return "claude"
 }
 
 // GenerateSQL calls the Claude CLI to generate SQL from DDL and a question.
-func (c *ClaudeClient) GenerateSQL(ddl, question string) (string, error) {
-	userPrompt := "DDL: " + ddl + "\nQuestion: " + question
-	systemPrompt := fmt.Sprintf(claudeSystemPromptTemplate, c.database)
+func (c *ClaudeClient) GenerateSQL(ctx context.Context, req Request) (Result, error) {
+	args, err := c.buildArgs(req, "json")
+	if err != nil {
+		return Result{}, err
+	}
+
+	stdout, err := runCommand(ctx, "claude", args...)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result, err := parseClaudeResponse(stdout)
+	if err != nil {
+		return Result{}, err
+	}
+	reportSession(req, claudeSessionID(stdout))
+	result.Model, result.Raw = req.Model, string(stdout)
 
-	cmd := exec.Command("claude",
-		"-p", userPrompt,
-		"--append-system-prompt", systemPrompt,
-		"--output-format", "json",
-		"--json-schema", claudeJSONSchema,
-	)
+	return result, nil
+}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// GenerateSQLStream calls the Claude CLI with stream-json output and emits
+// the model's output fragments while it is generating.
+func (c *ClaudeClient) GenerateSQLStream(ctx context.Context, req Request, emit func(StreamEvent)) (Result, error) {
+	args, err := c.buildArgs(req, "stream-json")
+	if err != nil {
+		return Result{}, err
+	}
+	args = append(args, "--verbose", "--include-partial-messages")
 
-	if err := cmd.Run(); err != nil {
-		return "", errors.Join(ErrCLIExecution, errors.New(stderr.String()))
+	stdout, err := streamCommand(ctx, func(line []byte) {
+		if event, ok := parseClaudeStreamEvent(line); ok {
+			emit(event)
+		}
+	}, "claude", args...)
+	if err != nil {
+		return Result{}, err
 	}
 
-	sql, err := parseClaudeResponse(stdout.Bytes())
+	result, err := parseClaudeStreamResponse(stdout)
 	if err != nil {
-		return "", err
+		return Result{}, err
 	}
+	reportSession(req, claudeSessionID(stdout))
+	result.Model, result.Raw = req.Model, string(stdout)
 
-	return sql, nil
+	return result, nil
+}
+
+// buildArgs builds the Claude CLI arguments for the given output format. A
+// resumed session already holds the schema, so only the question is sent.
+func (c *ClaudeClient) buildArgs(req Request, outputFormat string) ([]string, error) {
+	parts, err := renderPrompts(c.prompts, promptData("claude", c.database, req), prompt.System, prompt.Schema, prompt.Question)
+	if err != nil {
+		return nil, err
+	}
+
+	text := parts[1] + "\n" + parts[2]
+	if req.SessionID != "" {
+		text = parts[2]
+	}
+	args := []string{
+		"-p", text,
+		"--append-system-prompt", parts[0],
+		"--output-format", outputFormat,
+		"--json-schema", answerSchema(req.Clarify, false),
+	}
+	if req.SessionID != "" {
+		args = append(args, "--resume", req.SessionID)
+	}
+	if req.Model != "" {
+		args = append(args, "--model="+req.Model)
+	}
+
+	return args, nil
 }
 
 // parseClaudeResponse extracts the SQL from Claude's JSON response.
-func parseClaudeResponse(data []byte) (string, error) {
+func parseClaudeResponse(data []byte) (Result, error) {
 	// Claude returns: {"structured_output": {"sql": "..."}, ...}
 	var response struct {
-		StructuredOutput struct {
-			SQL string `json:"sql"`
-		} `json:"structured_output"`
+		StructuredOutput structuredAnswer `json:"structured_output"`
 	}
 
-	if err := json.Unmarshal(data, &response); err == nil && response.StructuredOutput.SQL != "" {
-		return response.StructuredOutput.SQL, nil
+	if err := json.Unmarshal(data, &response); err == nil {
+		if result, err := response.StructuredOutput.result(); !errors.Is(err, ErrParsing) {
+			return result, err
+		}
 	}
 
 	// Fallback: try to extract raw content if structured parsing fails
 	trimmed := strings.TrimSpace(string(data))
 	if trimmed != "" {
-		return trimmed, nil
+		return Result{SQL: trimmed}, nil
+	}
+
+	return Result{}, ErrParsing
+}
+
+// claudeSessionID returns the session ID of Claude's json or stream-json
+// output, or "" if there is none.
+func claudeSessionID(data []byte) string {
+	var id string
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var event struct {
+			SessionID string `json:"session_id"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			return id
+		}
+		if event.SessionID != "" {
+			id = event.SessionID
+		}
+	}
+}
+
+// claudeStreamLine represents a single stream-json event from Claude.
+type claudeStreamLine struct {
+	Type  string `json:"type"`
+	Event *struct {
+		Type  string `json:"type"`
+		Delta *struct {
+			Type        string `json:"type"`
+			Text        string `json:"text"`
+			PartialJSON string `json:"partial_json"`
+		} `json:"delta"`
+	} `json:"event,omitempty"`
+	Result           string            `json:"result,omitempty"`
+	StructuredOutput *structuredAnswer `json:"structured_output,omitempty"`
+}
+
+// parseClaudeStreamEvent turns a stream-json content delta into a partial
+// event. Structured output arrives as partial JSON of the {"sql": ...} tool
+// input, which is forwarded as-is.
+func parseClaudeStreamEvent(line []byte) (StreamEvent, bool) {
+	var event claudeStreamLine
+	if err := json.Unmarshal(line, &event); err != nil {
+		return StreamEvent{}, false
+	}
+
+	if event.Type != "stream_event" || event.Event == nil || event.Event.Type != "content_block_delta" || event.Event.Delta == nil {
+		return StreamEvent{}, false
+	}
+
+	switch event.Event.Delta.Type {
+	case "text_delta":
+		return StreamEvent{Type: EventPartial, Text: event.Event.Delta.Text}, event.Event.Delta.Text != ""
+	case "input_json_delta":
+		return StreamEvent{Type: EventPartial, Text: event.Event.Delta.PartialJSON}, event.Event.Delta.PartialJSON != ""
+	}
+
+	return StreamEvent{}, false
+}
+
+// parseClaudeStreamResponse extracts the SQL from the final result event of
+// Claude's stream-json output.
+func parseClaudeStreamResponse(data []byte) (Result, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var event claudeStreamLine
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Type != "result" {
+			continue
+		}
+
+		if event.StructuredOutput != nil {
+			if result, err := event.StructuredOutput.result(); !errors.Is(err, ErrParsing) {
+				return result, err
+			}
+		}
+		if sql := CleanSQL(event.Result); sql != "" {
+			return Result{SQL: sql}, nil
+		}
 	}
 
-	return "", ErrParsing
+	return Result{}, ErrParsing
 }
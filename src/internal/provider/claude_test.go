@@ -1,33 +1,38 @@
 package provider
 
 import (
+	"errors"
+	"strings"
 	"testing"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/session"
 )
 
 func TestParseClaudeResponse_StructuredJSON(t *testing.T) {
 	input := `{"structured_output":{"sql":"SELECT * FROM users"},"type":"result"}`
 
-	sql, err := parseClaudeResponse([]byte(input))
+	result, err := parseClaudeResponse([]byte(input))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
 	expected := "SELECT * FROM users"
-	if sql != expected {
-		t.Errorf("expected %q, got %q", expected, sql)
+	if result.SQL != expected {
+		t.Errorf("expected %q, got %q", expected, result.SQL)
 	}
 }
 
 func TestParseClaudeResponse_RawSQL(t *testing.T) {
 	input := `SELECT * FROM users WHERE id = 1`
 
-	sql, err := parseClaudeResponse([]byte(input))
+	result, err := parseClaudeResponse([]byte(input))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if sql != input {
-		t.Errorf("expected %q, got %q", input, sql)
+	if result.SQL != input {
+		t.Errorf("expected %q, got %q", input, result.SQL)
 	}
 }
 
@@ -52,28 +57,28 @@ func TestParseClaudeResponse_WhitespaceOnly(t *testing.T) {
 func TestParseClaudeResponse_NestedJSON(t *testing.T) {
 	input := `{"structured_output":{"sql":"SELECT json_extract(data, '$.name') FROM users"}}`
 
-	sql, err := parseClaudeResponse([]byte(input))
+	result, err := parseClaudeResponse([]byte(input))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
 	expected := "SELECT json_extract(data, '$.name') FROM users"
-	if sql != expected {
-		t.Errorf("expected %q, got %q", expected, sql)
+	if result.SQL != expected {
+		t.Errorf("expected %q, got %q", expected, result.SQL)
 	}
 }
 
 func TestParseClaudeResponse_EmptySQL(t *testing.T) {
 	input := `{"structured_output":{"sql":""}}`
 
-	sql, err := parseClaudeResponse([]byte(input))
+	result, err := parseClaudeResponse([]byte(input))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
 	// Falls back to trimmed raw content
-	if sql != `{"structured_output":{"sql":""}}` {
-		t.Errorf("unexpected fallback: %q", sql)
+	if result.SQL != `{"structured_output":{"sql":""}}` {
+		t.Errorf("unexpected fallback: %q", result.SQL)
 	}
 }
 
@@ -81,13 +86,206 @@ func TestParseClaudeResponse_FullResponse(t *testing.T) {
 	// Test with a more complete response like the actual CLI returns
 	input := `{"type":"result","subtype":"success","structured_output":{"sql":"SELECT * FROM users WHERE name LIKE 'A%';"},"session_id":"abc123"}`
 
-	sql, err := parseClaudeResponse([]byte(input))
+	result, err := parseClaudeResponse([]byte(input))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
 	expected := "SELECT * FROM users WHERE name LIKE 'A%';"
-	if sql != expected {
-		t.Errorf("expected %q, got %q", expected, sql)
+	if result.SQL != expected {
+		t.Errorf("expected %q, got %q", expected, result.SQL)
+	}
+}
+
+func TestParseClaudeResponse_Structured(t *testing.T) {
+	input := `{"type":"result","structured_output":{"sql":"SELECT SUM(total) FROM orders","explanation":"Sums the order totals.","assumptions":["Totals are net"],"confidence":0.7,"tables":["orders"]}}`
+
+	result, err := parseClaudeResponse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Explanation != "Sums the order totals." || result.Confidence != 0.7 ||
+		strings.Join(result.Assumptions, ",") != "Totals are net" || strings.Join(result.Tables, ",") != "orders" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestParseClaudeResponse_Clarification(t *testing.T) {
+	input := `{"type":"result","structured_output":{"type":"clarification","question":"Gross or net revenue?","options":["gross","net"]}}`
+
+	_, err := parseClaudeResponse([]byte(input))
+	var clarification *Clarification
+	if !errors.As(err, &clarification) {
+		t.Fatalf("expected a clarification, got %v", err)
+	}
+	if clarification.Question != "Gross or net revenue?" || len(clarification.Options) != 2 {
+		t.Errorf("unexpected clarification %+v", clarification)
+	}
+}
+
+func TestParseClaudeStreamEvent_TextDelta(t *testing.T) {
+	input := `{"type":"stream_event","event":{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"SELECT"}}}`
+
+	event, ok := parseClaudeStreamEvent([]byte(input))
+	if !ok {
+		t.Fatal("expected a partial event")
+	}
+
+	if event.Type != EventPartial || event.Text != "SELECT" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+}
+
+func TestParseClaudeStreamEvent_InputJSONDelta(t *testing.T) {
+	input := `{"type":"stream_event","event":{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"sql\": \"SEL"}}}`
+
+	event, ok := parseClaudeStreamEvent([]byte(input))
+	if !ok {
+		t.Fatal("expected a partial event")
+	}
+
+	if event.Type != EventPartial || event.Text != `{"sql": "SEL` {
+		t.Errorf("unexpected event: %+v", event)
+	}
+}
+
+func TestParseClaudeStreamEvent_Ignored(t *testing.T) {
+	inputs := []string{
+		`{"type":"system","subtype":"init","session_id":"abc"}`,
+		`{"type":"stream_event","event":{"type":"message_start"}}`,
+		`{"type":"result","structured_output":{"sql":"SELECT 1"}}`,
+		`not json`,
+	}
+
+	for _, input := range inputs {
+		if event, ok := parseClaudeStreamEvent([]byte(input)); ok {
+			t.Errorf("expected %s to be ignored, got %+v", input, event)
+		}
+	}
+}
+
+func TestParseClaudeStreamResponse_StructuredOutput(t *testing.T) {
+	input := `{"type":"system","subtype":"init"}
+{"type":"stream_event","event":{"type":"content_block_delta","delta":{"type":"text_delta","text":"SELECT"}}}
+{"type":"result","subtype":"success","result":"","structured_output":{"sql":"SELECT * FROM users"}}`
+
+	result, err := parseClaudeStreamResponse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.SQL != "SELECT * FROM users" {
+		t.Errorf("expected %q, got %q", "SELECT * FROM users", result.SQL)
+	}
+}
+
+func TestParseClaudeStreamResponse_ResultText(t *testing.T) {
+	input := `{"type":"result","subtype":"success","result":"` + "```sql\\nSELECT 1\\n```" + `"}`
+
+	result, err := parseClaudeStreamResponse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.SQL != "SELECT 1" {
+		t.Errorf("expected %q, got %q", "SELECT 1", result.SQL)
+	}
+}
+
+func TestParseClaudeStreamResponse_Clarification(t *testing.T) {
+	input := `{"type":"result","subtype":"success","result":"","structured_output":{"type":"clarification","question":"Which year?"}}`
+
+	_, err := parseClaudeStreamResponse([]byte(input))
+	var clarification *Clarification
+	if !errors.As(err, &clarification) || clarification.Question != "Which year?" {
+		t.Errorf("expected a clarification, got %v", err)
+	}
+}
+
+func TestParseClaudeStreamResponse_NoResult(t *testing.T) {
+	input := `{"type":"system","subtype":"init"}`
+
+	if _, err := parseClaudeStreamResponse([]byte(input)); err != ErrParsing {
+		t.Errorf("expected ErrParsing, got %v", err)
+	}
+}
+
+func TestClaudeClient_BuildArgs_Model(t *testing.T) {
+	client := NewClaudeClient("DuckDB", prompt.Default())
+
+	args, err := client.buildArgs(Request{DDL: "CREATE TABLE t (id INT)", Question: "q"}, "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(args, " "); strings.Contains(got, "--model") {
+		t.Errorf("expected no --model without a model, got %q", got)
+	}
+
+	args, err = client.buildArgs(Request{DDL: "CREATE TABLE t (id INT)", Question: "q", Model: "opus"}, "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(args, " "); !strings.HasSuffix(got, "--model=opus") {
+		t.Errorf("expected --model=opus, got %q", got)
+	}
+}
+
+func TestClaudeClient_BuildArgs_Clarify(t *testing.T) {
+	client := NewClaudeClient("DuckDB", prompt.Default())
+
+	for _, clarify := range []bool{false, true} {
+		schema := answerSchema(clarify, false)
+		args, err := client.buildArgs(Request{DDL: "CREATE TABLE t (id INT)", Question: "q", Clarify: clarify}, "json")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := strings.Join(args, " "); !strings.Contains(got, "--json-schema "+schema) {
+			t.Errorf("expected schema %s, got %q", schema, got)
+		}
+	}
+}
+
+func TestClaudeClient_BuildArgs_Session(t *testing.T) {
+	client := NewClaudeClient("DuckDB", prompt.Default())
+	history := []session.Turn{{Question: "Orders per user", SQL: "SELECT user_id, COUNT(*) FROM t GROUP BY 1"}}
+
+	args, err := client.buildArgs(Request{DDL: "CREATE TABLE t (id INT)", Question: "Now per month", History: history}, "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(args, " "); !strings.Contains(got, "SQL: SELECT user_id, COUNT(*) FROM t GROUP BY 1") || strings.Contains(got, "--resume") {
+		t.Errorf("expected the earlier turns replayed, got %q", got)
+	}
+
+	args, err = client.buildArgs(Request{DDL: "CREATE TABLE t (id INT)", Question: "Now per month", History: history, SessionID: "abc"}, "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if args[1] != "Question: Now per month" {
+		t.Errorf("expected only the question in a resumed session, got %q", args[1])
+	}
+	if got := strings.Join(args, " "); !strings.HasSuffix(got, "--resume abc") {
+		t.Errorf("expected --resume abc, got %q", got)
+	}
+}
+
+func TestClaudeSessionID(t *testing.T) {
+	tests := map[string]string{
+		"json":        `{"type":"result","session_id":"abc","structured_output":{"sql":"SELECT 1"}}`,
+		"stream-json": "{\"type\":\"system\",\"session_id\":\"abc\"}\n{\"type\":\"result\",\"session_id\":\"abc\"}\n",
+		"raw text":    "SELECT 1",
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			expected := "abc"
+			if name == "raw text" {
+				expected = ""
+			}
+			if got := claudeSessionID([]byte(input)); got != expected {
+				t.Errorf("expected session %q, got %q", expected, got)
+			}
+		})
 	}
 }
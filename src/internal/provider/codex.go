@@ -3,52 +3,125 @@ package provider
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"os/exec"
+	"os"
 	"strings"
-)
-
-const codexPromptTemplate = `You are a %s expert. Generate ONLY a raw SQL query with no markdown, no explanations, no code blocks. Format the SQL nicely with 2-space indentation.
-
-DDL: %s
-Question: %s
 
-Respond with ONLY the SQL query.`
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
+)
 
 // CodexClient implements SQLGenerator using the Codex CLI.
 type CodexClient struct {
 	database string
+	prompts  *prompt.Set
 }
 
 // NewCodexClient creates a new Codex CLI client.
-func NewCodexClient(database string) *CodexClient {
-	return &CodexClient{database: database}
+func NewCodexClient(database string, prompts *prompt.Set) *CodexClient {
+	return &CodexClient{database: database, prompts: prompts}
+}
+
+// Binary returns the name of the Codex CLI executable.
+func (c *CodexClient) Binary() string {
+	return "codex"
+}
+
+// CheckAuth runs "codex login status", which fails when no credentials are stored.
+func (c *CodexClient) CheckAuth(ctx context.Context) error {
+	if _, err := runCommand(ctx, "codex", "login", "status"); err != nil {
+		return errors.Join(ErrNotAuthenticated, err)
+	}
+	return nil
+}
+
+// SupportsEffort reports that Codex accepts a reasoning effort.
+func (c *CodexClient) SupportsEffort() bool {
+	return true
 }
 
 // GenerateSQL calls the Codex CLI to generate SQL from DDL and a question.
-func (c *CodexClient) GenerateSQL(ddl, question string) (string, error) {
-	prompt := FormatPrompt(codexPromptTemplate, c.database, ddl, question)
+func (c *CodexClient) GenerateSQL(ctx context.Context, req Request) (Result, error) {
+	args, cleanup, err := c.buildArgs(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer cleanup()
+
+	stdout, err := runCommand(ctx, "codex", args...)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result, err := parseCodexResponse(stdout)
+	if err != nil {
+		return Result{}, err
+	}
+	result.Model, result.Raw = req.Model, string(stdout)
+
+	return result, nil
+}
+
+// GenerateSQLStream calls the Codex CLI and emits a progress event for every
+// item.* event it reports while working.
+func (c *CodexClient) GenerateSQLStream(ctx context.Context, req Request, emit func(StreamEvent)) (Result, error) {
+	args, cleanup, err := c.buildArgs(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer cleanup()
 
-	cmd := exec.Command("codex", "exec",
-		prompt,
-		"--json",
-	)
+	stdout, err := streamCommand(ctx, func(line []byte) {
+		if event, ok := parseCodexStreamEvent(line); ok {
+			emit(event)
+		}
+	}, "codex", args...)
+	if err != nil {
+		return Result{}, err
+	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	result, err := parseCodexResponse(stdout)
+	if err != nil {
+		return Result{}, err
+	}
+	result.Model, result.Raw = req.Model, string(stdout)
+
+	return result, nil
+}
 
-	if err := cmd.Run(); err != nil {
-		return "", errors.Join(ErrCLIExecution, errors.New(stderr.String()))
+// buildArgs builds the "codex exec" arguments. The answer schema is passed
+// as --output-schema, which Codex only reads from a file; cleanup removes
+// it once the command has finished.
+func (c *CodexClient) buildArgs(req Request) ([]string, func(), error) {
+	text, err := c.prompts.Render(prompt.Prompt, promptData("codex", c.database, req))
+	if err != nil {
+		return nil, nil, err
 	}
 
-	sql, err := parseCodexResponse(stdout.Bytes())
+	schema, err := os.CreateTemp("", "text-to-sql-schema-*.json")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.Remove(schema.Name()) }
+	_, err = schema.WriteString(answerSchema(req.Clarify, true))
+	if closeErr := schema.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
-		return "", err
+		cleanup()
+		return nil, nil, err
+	}
+
+	args := []string{"exec", text, "--json", "--output-schema", schema.Name()}
+	if req.Model != "" {
+		args = append(args, "--model="+req.Model)
+	}
+	if req.Effort != "" {
+		args = append(args, "-c", "model_reasoning_effort="+req.Effort)
 	}
 
-	return sql, nil
+	return args, cleanup, nil
 }
 
 // codexEvent represents a single NDJSON event from Codex.
@@ -65,8 +138,9 @@ type codexEvent struct {
 	Response string `json:"response,omitempty"`
 }
 
-// parseCodexResponse extracts the SQL from Codex's NDJSON response.
-func parseCodexResponse(data []byte) (string, error) {
+// parseCodexResponse extracts the SQL from Codex's NDJSON response. The
+// last message is the structured answer if the output schema was applied.
+func parseCodexResponse(data []byte) (Result, error) {
 	// Codex outputs NDJSON - one JSON object per line
 	// We need to find the last assistant message or response
 
@@ -101,14 +175,29 @@ func parseCodexResponse(data []byte) (string, error) {
 	}
 
 	if lastContent != "" {
-		return CleanSQL(lastContent), nil
+		return parseAnswer(lastContent)
 	}
 
 	// Fallback: try the whole output as raw text
 	trimmed := strings.TrimSpace(string(data))
 	if trimmed != "" {
-		return CleanSQL(trimmed), nil
+		return Result{SQL: CleanSQL(trimmed)}, nil
+	}
+
+	return Result{}, ErrParsing
+}
+
+// parseCodexStreamEvent turns an item.* NDJSON event into a progress event
+// whose stage is the item type (reasoning, command_execution, agent_message, ...).
+func parseCodexStreamEvent(line []byte) (StreamEvent, bool) {
+	var event codexEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		return StreamEvent{}, false
+	}
+
+	if !strings.HasPrefix(event.Type, "item.") || event.Item == nil {
+		return StreamEvent{}, false
 	}
 
-	return "", ErrParsing
+	return StreamEvent{Type: EventProgress, Stage: event.Item.Type, Text: event.Item.Text}, true
 }
@@ -1,7 +1,12 @@
 package provider
 
 import (
+	"os"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
 )
 
 func TestParseCodexResponse_ItemCompleted(t *testing.T) {
@@ -11,14 +16,14 @@ func TestParseCodexResponse_ItemCompleted(t *testing.T) {
 {"type":"item.completed","item":{"id":"item_0","type":"agent_message","text":"SELECT SUM(total) AS total_sales FROM orders;"}}
 {"type":"turn.completed","usage":{"input_tokens":100,"output_tokens":20}}`
 
-	sql, err := parseCodexResponse([]byte(input))
+	result, err := parseCodexResponse([]byte(input))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
 	expected := "SELECT SUM(total) AS total_sales FROM orders;"
-	if sql != expected {
-		t.Errorf("expected %q, got %q", expected, sql)
+	if result.SQL != expected {
+		t.Errorf("expected %q, got %q", expected, result.SQL)
 	}
 }
 
@@ -28,14 +33,14 @@ func TestParseCodexResponse_AssistantMessage(t *testing.T) {
 {"type":"message","message":{"role":"user","content":"Generate SQL"}}
 {"type":"message","message":{"role":"assistant","content":"SELECT * FROM users"}}`
 
-	sql, err := parseCodexResponse([]byte(input))
+	result, err := parseCodexResponse([]byte(input))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
 	expected := "SELECT * FROM users"
-	if sql != expected {
-		t.Errorf("expected %q, got %q", expected, sql)
+	if result.SQL != expected {
+		t.Errorf("expected %q, got %q", expected, result.SQL)
 	}
 }
 
@@ -44,14 +49,14 @@ func TestParseCodexResponse_ResponseField(t *testing.T) {
 	input := `{"type":"init"}
 {"type":"result","response":"SELECT COUNT(*) FROM orders"}`
 
-	sql, err := parseCodexResponse([]byte(input))
+	result, err := parseCodexResponse([]byte(input))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
 	expected := "SELECT COUNT(*) FROM orders"
-	if sql != expected {
-		t.Errorf("expected %q, got %q", expected, sql)
+	if result.SQL != expected {
+		t.Errorf("expected %q, got %q", expected, result.SQL)
 	}
 }
 
@@ -60,28 +65,28 @@ func TestParseCodexResponse_MultipleAssistantMessages(t *testing.T) {
 	input := `{"type":"message","message":{"role":"assistant","content":"Thinking..."}}
 {"type":"message","message":{"role":"assistant","content":"SELECT * FROM users WHERE active = true"}}`
 
-	sql, err := parseCodexResponse([]byte(input))
+	result, err := parseCodexResponse([]byte(input))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
 	expected := "SELECT * FROM users WHERE active = true"
-	if sql != expected {
-		t.Errorf("expected %q, got %q", expected, sql)
+	if result.SQL != expected {
+		t.Errorf("expected %q, got %q", expected, result.SQL)
 	}
 }
 
 func TestParseCodexResponse_WithMarkdownCodeBlock(t *testing.T) {
 	input := `{"type":"message","message":{"role":"assistant","content":"` + "```sql\\nSELECT * FROM users\\n```" + `"}}`
 
-	sql, err := parseCodexResponse([]byte(input))
+	result, err := parseCodexResponse([]byte(input))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
 	expected := "SELECT * FROM users"
-	if sql != expected {
-		t.Errorf("expected %q, got %q", expected, sql)
+	if result.SQL != expected {
+		t.Errorf("expected %q, got %q", expected, result.SQL)
 	}
 }
 
@@ -89,14 +94,14 @@ func TestParseCodexResponse_RawSQL(t *testing.T) {
 	// Fallback to raw content
 	input := `SELECT * FROM users`
 
-	sql, err := parseCodexResponse([]byte(input))
+	result, err := parseCodexResponse([]byte(input))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
 	expected := "SELECT * FROM users"
-	if sql != expected {
-		t.Errorf("expected %q, got %q", expected, sql)
+	if result.SQL != expected {
+		t.Errorf("expected %q, got %q", expected, result.SQL)
 	}
 }
 
@@ -116,14 +121,14 @@ func TestParseCodexResponse_EmptyLines(t *testing.T) {
 {"type":"message","message":{"role":"assistant","content":"SELECT 1"}}
 `
 
-	sql, err := parseCodexResponse([]byte(input))
+	result, err := parseCodexResponse([]byte(input))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
 	expected := "SELECT 1"
-	if sql != expected {
-		t.Errorf("expected %q, got %q", expected, sql)
+	if result.SQL != expected {
+		t.Errorf("expected %q, got %q", expected, result.SQL)
 	}
 }
 
@@ -132,13 +137,88 @@ func TestParseCodexResponse_InvalidJSON(t *testing.T) {
 	input := `not valid json
 {"type":"message","message":{"role":"assistant","content":"SELECT * FROM users"}}`
 
-	sql, err := parseCodexResponse([]byte(input))
+	result, err := parseCodexResponse([]byte(input))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
 	expected := "SELECT * FROM users"
-	if sql != expected {
-		t.Errorf("expected %q, got %q", expected, sql)
+	if result.SQL != expected {
+		t.Errorf("expected %q, got %q", expected, result.SQL)
+	}
+}
+
+func TestParseCodexStreamEvent_Item(t *testing.T) {
+	input := `{"type":"item.completed","item":{"id":"item_0","type":"reasoning","text":"Looking at the orders table"}}`
+
+	event, ok := parseCodexStreamEvent([]byte(input))
+	if !ok {
+		t.Fatal("expected a progress event")
+	}
+
+	if event.Type != EventProgress || event.Stage != "reasoning" || event.Text != "Looking at the orders table" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+}
+
+func TestParseCodexStreamEvent_Ignored(t *testing.T) {
+	inputs := []string{
+		`{"type":"thread.started","thread_id":"thread_abc123"}`,
+		`{"type":"turn.completed","usage":{"input_tokens":100}}`,
+		`{"type":"item.started"}`,
+		`not json`,
+	}
+
+	for _, input := range inputs {
+		if event, ok := parseCodexStreamEvent([]byte(input)); ok {
+			t.Errorf("expected %s to be ignored, got %+v", input, event)
+		}
+	}
+}
+
+func TestCodexClient_BuildArgs_ModelAndEffort(t *testing.T) {
+	client := NewCodexClient("DuckDB", prompt.Default())
+
+	args, cleanup, err := client.buildArgs(Request{DDL: "CREATE TABLE t (id INT)", Question: "q", Model: "gpt-5-codex", Effort: EffortHigh})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer cleanup()
+
+	// args[4] is the path of the output schema
+	got := strings.Join(append(args[2:4], args[5:]...), " ")
+	expected := "--json --output-schema --model=gpt-5-codex -c model_reasoning_effort=high"
+	if got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestCodexClient_BuildArgs_OutputSchema(t *testing.T) {
+	client := NewCodexClient("DuckDB", prompt.Default())
+
+	args, cleanup, err := client.buildArgs(Request{DDL: "CREATE TABLE t (id INT)", Question: "q", Clarify: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	path := args[slices.Index(args, "--output-schema")+1]
+	if schema, err := os.ReadFile(path); err != nil || string(schema) != answerSchema(true, true) {
+		t.Errorf("expected the strict clarification schema, got %s (%v)", schema, err)
+	}
+
+	cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the schema file to be removed, got %v", err)
+	}
+}
+
+func TestParseCodexResponse_Structured(t *testing.T) {
+	input := `{"type":"item.completed","item":{"type":"agent_message","text":"{\"sql\":\"SELECT name FROM users\",\"explanation\":\"Lists user names.\",\"assumptions\":[],\"confidence\":0.8,\"tables\":[\"users\"]}"}}`
+
+	result, err := parseCodexResponse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.SQL != "SELECT name FROM users" || result.Explanation != "Lists user names." || result.Confidence != 0.8 || len(result.Tables) != 1 {
+		t.Errorf("unexpected result %+v", result)
 	}
 }
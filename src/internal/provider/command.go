@@ -0,0 +1,314 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
+)
+
+// Output extraction types for config-defined command providers.
+const (
+	OutputRaw    = "raw"
+	OutputJSON   = "json"
+	OutputNDJSON = "ndjson"
+	OutputRegex  = "regex"
+)
+
+// OutputRule describes how to extract the SQL from a command's stdout.
+type OutputRule struct {
+	// Type is one of raw, json, ndjson or regex.
+	Type string `json:"type"`
+	// Path is a dot-separated JSON path (e.g. "choices.0.message.content")
+	// used by the json and ndjson types.
+	Path string `json:"path,omitempty"`
+	// MatchField and MatchValue select which NDJSON events are considered;
+	// the last matching event wins. MatchField is a JSON path as well.
+	MatchField string `json:"match_field,omitempty"`
+	MatchValue string `json:"match_value,omitempty"`
+	// Pattern is the regex used by the regex type. The first capture group
+	// is returned if present, otherwise the whole match.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// CommandSpec declares a provider backed by an arbitrary CLI.
+type CommandSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Command is the binary name or path.
+	Command string `json:"command"`
+	// Args are text/template strings rendered with {{.Prompt}},
+	// {{.Database}}, {{.DDL}}, {{.Question}}, {{.Model}} and {{.Effort}}.
+	// Each entry stays a single argv element, so no shell quoting is
+	// involved. Entries that render to an empty string are dropped, so
+	// optional flags can be written as {{if .Model}}--model={{.Model}}{{end}}.
+	Args []string `json:"args"`
+	// Stdin writes the prompt to the command's standard input.
+	Stdin  bool       `json:"stdin,omitempty"`
+	Output OutputRule `json:"output"`
+	// AuthArgs, if set, are run against Command as a cheap auth probe; a
+	// non-zero exit status means the CLI is not logged in.
+	AuthArgs []string `json:"auth_args,omitempty"`
+}
+
+// commandSpecFile is the on-disk format of TEXT_TO_SQL_PROXY_COMMANDS_FILE.
+type commandSpecFile struct {
+	Providers []CommandSpec `json:"providers"`
+}
+
+// LoadCommandSpecs reads command provider declarations from a JSON file.
+func LoadCommandSpecs(path string) ([]CommandSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file commandSpecFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	return file.Providers, nil
+}
+
+// commandArgs holds the values available to argv templates.
+type commandArgs struct {
+	Prompt   string
+	Database string
+	DDL      string
+	Question string
+	Model    string
+	Effort   string
+}
+
+// CommandClient implements SQLGenerator for a config-defined CLI.
+type CommandClient struct {
+	database string
+	prompts  *prompt.Set
+	spec     CommandSpec
+	args     []*template.Template
+	pattern  *regexp.Regexp
+}
+
+// NewCommandClient validates spec and creates a client for it.
+func NewCommandClient(database string, prompts *prompt.Set, spec CommandSpec) (*CommandClient, error) {
+	if spec.Name == "" {
+		return nil, errors.New("command provider: name is required")
+	}
+	if spec.Command == "" {
+		return nil, fmt.Errorf("command provider %s: command is required", spec.Name)
+	}
+
+	c := &CommandClient{database: database, prompts: prompts, spec: spec}
+
+	for i, arg := range spec.Args {
+		tmpl, err := template.New(fmt.Sprintf("%s.args[%d]", spec.Name, i)).Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("command provider %s: %w", spec.Name, err)
+		}
+		c.args = append(c.args, tmpl)
+	}
+
+	switch spec.Output.Type {
+	case "", OutputRaw:
+	case OutputJSON:
+		if spec.Output.Path == "" {
+			return nil, fmt.Errorf("command provider %s: output path is required for json", spec.Name)
+		}
+	case OutputNDJSON:
+		if spec.Output.Path == "" {
+			return nil, fmt.Errorf("command provider %s: output path is required for ndjson", spec.Name)
+		}
+	case OutputRegex:
+		pattern, err := regexp.Compile(spec.Output.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("command provider %s: %w", spec.Name, err)
+		}
+		c.pattern = pattern
+	default:
+		return nil, fmt.Errorf("command provider %s: unknown output type %q", spec.Name, spec.Output.Type)
+	}
+
+	return c, nil
+}
+
+// Description returns the human-readable description from the spec.
+func (c *CommandClient) Description() string {
+	if c.spec.Description != "" {
+		return c.spec.Description
+	}
+	return c.spec.Name
+}
+
+// Binary returns the configured command.
+func (c *CommandClient) Binary() string {
+	return c.spec.Command
+}
+
+// CheckAuth runs the configured auth probe, if any.
+func (c *CommandClient) CheckAuth(ctx context.Context) error {
+	if len(c.spec.AuthArgs) == 0 {
+		return errNoAuthProbe
+	}
+	if _, err := runCommand(ctx, c.spec.Command, c.spec.AuthArgs...); err != nil {
+		return errors.Join(ErrNotAuthenticated, err)
+	}
+	return nil
+}
+
+// SupportsEffort reports whether any argument template uses {{.Effort}}.
+func (c *CommandClient) SupportsEffort() bool {
+	for _, arg := range c.spec.Args {
+		if strings.Contains(arg, ".Effort") {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateSQL runs the configured command to generate SQL from DDL and a question.
+func (c *CommandClient) GenerateSQL(ctx context.Context, req Request) (Result, error) {
+	text, err := c.prompts.Render(prompt.Prompt, promptData(c.spec.Name, c.database, req))
+	if err != nil {
+		return Result{}, err
+	}
+
+	values := commandArgs{
+		Prompt:   text,
+		Database: targetDatabase(c.database, req),
+		DDL:      req.DDL,
+		Question: req.Question,
+		Model:    req.Model,
+		Effort:   req.Effort,
+	}
+
+	args := make([]string, 0, len(c.args))
+	for i, tmpl := range c.args {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, values); err != nil {
+			return Result{}, err
+		}
+		// Templates that render to nothing, e.g. an unset model, drop the argument
+		if buf.Len() == 0 && c.spec.Args[i] != "" {
+			continue
+		}
+		args = append(args, buf.String())
+	}
+
+	var stdin io.Reader
+	if c.spec.Stdin {
+		stdin = strings.NewReader(values.Prompt)
+	}
+
+	stdout, err := runCommandWithInput(ctx, stdin, c.spec.Command, args...)
+	if err != nil {
+		return Result{}, err
+	}
+
+	sql, err := c.extract(stdout)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{SQL: sql, Model: req.Model, Raw: string(stdout)}, nil
+}
+
+// extract applies the output rule to the command's stdout.
+func (c *CommandClient) extract(data []byte) (string, error) {
+	var content string
+
+	switch c.spec.Output.Type {
+	case OutputJSON:
+		var doc any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return "", errors.Join(ErrParsing, err)
+		}
+		content, _ = lookupJSONPath(doc, c.spec.Output.Path)
+	case OutputNDJSON:
+		content = lastMatchingEvent(data, c.spec.Output)
+	case OutputRegex:
+		if matches := c.pattern.FindSubmatch(data); matches != nil {
+			content = string(matches[0])
+			if len(matches) > 1 {
+				content = string(matches[1])
+			}
+		}
+	default:
+		content = string(data)
+	}
+
+	if sql := CleanSQL(content); sql != "" {
+		return sql, nil
+	}
+
+	return "", ErrParsing
+}
+
+// lastMatchingEvent returns the value at rule.Path of the last NDJSON event
+// whose rule.MatchField equals rule.MatchValue.
+func lastMatchingEvent(data []byte, rule OutputRule) string {
+	var lastContent string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var event any
+		if err := json.Unmarshal(line, &event); err != nil {
+			continue
+		}
+
+		if rule.MatchField != "" {
+			if value, ok := lookupJSONPath(event, rule.MatchField); !ok || value != rule.MatchValue {
+				continue
+			}
+		}
+
+		if value, ok := lookupJSONPath(event, rule.Path); ok && value != "" {
+			lastContent = value
+		}
+	}
+
+	return lastContent
+}
+
+// lookupJSONPath walks a dot-separated path through decoded JSON. Numeric
+// segments index into arrays. Only string leaves are returned.
+func lookupJSONPath(doc any, path string) (string, bool) {
+	current := doc
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]any:
+			next, ok := node[segment]
+			if !ok {
+				return "", false
+			}
+			current = next
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", false
+			}
+			current = node[index]
+		default:
+			return "", false
+		}
+	}
+
+	value, ok := current.(string)
+	return value, ok
+}
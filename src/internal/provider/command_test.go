@@ -0,0 +1,245 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
+)
+
+func TestLoadCommandSpecs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.json")
+	content := `{"providers":[{"name":"llm","description":"llm CLI","command":"llm","args":["-m","gpt-4o-mini","{{.Prompt}}"],"output":{"type":"raw"}}]}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	specs, err := LoadCommandSpecs(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(specs) != 1 {
+		t.Fatalf("expected 1 spec, got %d", len(specs))
+	}
+	if specs[0].Name != "llm" || specs[0].Command != "llm" || len(specs[0].Args) != 3 {
+		t.Errorf("unexpected spec: %+v", specs[0])
+	}
+}
+
+func TestLoadCommandSpecs_InvalidJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.json")
+	if err := os.WriteFile(path, []byte(`{`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadCommandSpecs(path); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestNewCommandClient_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		spec CommandSpec
+	}{
+		{"missing name", CommandSpec{Command: "llm"}},
+		{"missing command", CommandSpec{Name: "llm"}},
+		{"bad template", CommandSpec{Name: "llm", Command: "llm", Args: []string{"{{.Prompt"}}},
+		{"json without path", CommandSpec{Name: "llm", Command: "llm", Output: OutputRule{Type: OutputJSON}}},
+		{"ndjson without path", CommandSpec{Name: "llm", Command: "llm", Output: OutputRule{Type: OutputNDJSON}}},
+		{"bad regex", CommandSpec{Name: "llm", Command: "llm", Output: OutputRule{Type: OutputRegex, Pattern: "("}}},
+		{"unknown output", CommandSpec{Name: "llm", Command: "llm", Output: OutputRule{Type: "xml"}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewCommandClient("DuckDB", prompt.Default(), tc.spec); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+}
+
+func TestCommandClient_Extract(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     OutputRule
+		input    string
+		expected string
+	}{
+		{
+			name:     "raw",
+			rule:     OutputRule{Type: OutputRaw},
+			input:    "```sql\nSELECT 1\n```\n",
+			expected: "SELECT 1",
+		},
+		{
+			name:     "json like gemini",
+			rule:     OutputRule{Type: OutputJSON, Path: "response"},
+			input:    `{"response":"SELECT * FROM users","stats":{}}`,
+			expected: "SELECT * FROM users",
+		},
+		{
+			name:     "json array index",
+			rule:     OutputRule{Type: OutputJSON, Path: "choices.0.message.content"},
+			input:    `{"choices":[{"message":{"content":"SELECT 2"}}]}`,
+			expected: "SELECT 2",
+		},
+		{
+			name: "ndjson like codex",
+			rule: OutputRule{Type: OutputNDJSON, MatchField: "item.type", MatchValue: "agent_message", Path: "item.text"},
+			input: `{"type":"thread.started"}
+{"type":"item.completed","item":{"type":"reasoning","text":"thinking"}}
+{"type":"item.completed","item":{"type":"agent_message","text":"SELECT SUM(total) FROM orders"}}`,
+			expected: "SELECT SUM(total) FROM orders",
+		},
+		{
+			name: "ndjson like opencode",
+			rule: OutputRule{Type: OutputNDJSON, MatchField: "type", MatchValue: "text", Path: "content"},
+			input: `{"type":"text","content":"Thinking..."}
+not json
+{"type":"text","content":"SELECT COUNT(*) FROM orders"}
+{"type":"step_finish"}`,
+			expected: "SELECT COUNT(*) FROM orders",
+		},
+		{
+			name:     "regex capture group",
+			rule:     OutputRule{Type: OutputRegex, Pattern: `(?s)SQL: (.*?)\nEND`},
+			input:    "Here you go\nSQL: SELECT 3\nEND\n",
+			expected: "SELECT 3",
+		},
+		{
+			name:     "regex whole match",
+			rule:     OutputRule{Type: OutputRegex, Pattern: `SELECT \d+`},
+			input:    "answer: SELECT 4;",
+			expected: "SELECT 4",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewCommandClient("DuckDB", prompt.Default(), CommandSpec{Name: "test", Command: "test", Output: tc.rule})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			sql, err := client.extract([]byte(tc.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if sql != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, sql)
+			}
+		})
+	}
+}
+
+func TestCommandClient_ExtractNoMatch(t *testing.T) {
+	client, err := NewCommandClient("DuckDB", prompt.Default(), CommandSpec{
+		Name:    "test",
+		Command: "test",
+		Output:  OutputRule{Type: OutputJSON, Path: "missing"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := client.extract([]byte(`{"response":"SELECT 1"}`)); !errors.Is(err, ErrParsing) {
+		t.Errorf("expected ErrParsing, got %v", err)
+	}
+}
+
+func TestCommandClient_GenerateSQL(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	client, err := NewCommandClient("DuckDB", prompt.Default(), CommandSpec{
+		Name:    "echo",
+		Command: "sh",
+		Args:    []string{"-c", `printf '{"sql":"%s"}' "$1"`, "sh", "SELECT '{{.Database}}' AS db -- {{.Question}}"},
+		Output:  OutputRule{Type: OutputJSON, Path: "sql"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result, err := client.GenerateSQL(context.Background(), Request{DDL: "CREATE TABLE t (id INT)", Question: "which db"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "SELECT 'DuckDB' AS db -- which db"
+	if result.SQL != expected {
+		t.Errorf("expected %q, got %q", expected, result.SQL)
+	}
+}
+
+func TestCommandClient_GenerateSQL_Stdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	client, err := NewCommandClient("DuckDB", prompt.Default(), CommandSpec{
+		Name:    "cat",
+		Command: "sh",
+		Args:    []string{"-c", "grep '^Question:'"},
+		Stdin:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out, err := client.GenerateSQL(context.Background(), Request{DDL: "CREATE TABLE t (id INT)", Question: "count rows"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if out.SQL != "Question: count rows" {
+		t.Errorf("expected prompt on stdin, got %q", out.SQL)
+	}
+}
+
+func TestCommandClient_Description(t *testing.T) {
+	client, _ := NewCommandClient("DuckDB", prompt.Default(), CommandSpec{Name: "llm", Command: "llm"})
+	if client.Description() != "llm" {
+		t.Errorf("expected name as fallback description, got %q", client.Description())
+	}
+
+	client, _ = NewCommandClient("DuckDB", prompt.Default(), CommandSpec{Name: "llm", Description: "llm CLI", Command: "llm"})
+	if client.Description() != "llm CLI" {
+		t.Errorf("expected configured description, got %q", client.Description())
+	}
+}
+
+func TestCommandClient_GenerateSQL_OptionalArgs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+
+	client, err := NewCommandClient("DuckDB", prompt.Default(), CommandSpec{
+		Name:    "args",
+		Command: "sh",
+		Args:    []string{"-c", `echo "$#:$*"`, "sh", "{{if .Model}}--model={{.Model}}{{end}}", "{{if .Effort}}--effort={{.Effort}}{{end}}"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !client.SupportsEffort() {
+		t.Error("expected effort support when a template uses .Effort")
+	}
+
+	out, err := client.GenerateSQL(context.Background(), Request{DDL: "CREATE TABLE t (id INT)", Question: "q", Model: "small"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if out.SQL != "1:--model=small" {
+		t.Errorf("expected only the model argument, got %q", out.SQL)
+	}
+}
@@ -1,54 +1,56 @@
 package provider
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
-	"os/exec"
 	"strings"
-)
-
-const continuePromptTemplate = `You are a %s expert. Generate ONLY a raw SQL query with no markdown, no explanations, no code blocks. Format the SQL nicely with 2-space indentation.
-
-DDL: %s
-Question: %s
 
-Respond with ONLY the SQL query.`
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
+)
 
 // ContinueClient implements SQLGenerator using the Continue CLI (cn).
 type ContinueClient struct {
 	database string
+	prompts  *prompt.Set
 }
 
 // NewContinueClient creates a new Continue CLI client.
-func NewContinueClient(database string) *ContinueClient {
-	return &ContinueClient{database: database}
+func NewContinueClient(database string, prompts *prompt.Set) *ContinueClient {
+	return &ContinueClient{database: database, prompts: prompts}
+}
+
+// Binary returns the name of the Continue CLI executable.
+func (c *ContinueClient) Binary() string {
+	return "cn"
 }
 
 // GenerateSQL calls the Continue CLI to generate SQL from DDL and a question.
-func (c *ContinueClient) GenerateSQL(ddl, question string) (string, error) {
-	prompt := FormatPrompt(continuePromptTemplate, c.database, ddl, question)
+func (c *ContinueClient) GenerateSQL(ctx context.Context, req Request) (Result, error) {
+	text, err := c.prompts.Render(prompt.Prompt, promptData("continue", c.database, req))
+	if err != nil {
+		return Result{}, err
+	}
 
-	cmd := exec.Command("cn",
-		"-p", prompt,
+	args := []string{
+		"-p", text,
 		"--format", "json",
 		"--silent",
-	)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	}
+	if req.Model != "" {
+		args = append(args, "--model="+req.Model)
+	}
 
-	if err := cmd.Run(); err != nil {
-		return "", errors.Join(ErrCLIExecution, errors.New(stderr.String()))
+	stdout, err := runCommand(ctx, "cn", args...)
+	if err != nil {
+		return Result{}, err
 	}
 
-	sql, err := parseContinueResponse(stdout.Bytes())
+	sql, err := parseContinueResponse(stdout)
 	if err != nil {
-		return "", err
+		return Result{}, err
 	}
 
-	return sql, nil
+	return Result{SQL: sql, Model: req.Model, Raw: string(stdout)}, nil
 }
 
 // continueResponse represents the JSON response from Continue CLI.
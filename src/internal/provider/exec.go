@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"time"
+)
+
+// commandWaitDelay bounds how long we wait for a killed CLI to release its
+// output pipes before giving up on it.
+const commandWaitDelay = 2 * time.Second
+
+// maxStreamLine is the longest stdout line streamCommand accepts.
+const maxStreamLine = 10 * 1024 * 1024
+
+// runCommand executes a CLI and returns its stdout. When ctx is cancelled the
+// whole process group is killed, so helpers spawned by the CLI die with it.
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return runCommandWithInput(ctx, nil, name, args...)
+}
+
+// runCommandWithInput is runCommand with stdin connected to the given reader.
+func runCommandWithInput(ctx context.Context, stdin io.Reader, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	configureProcessGroup(cmd)
+	cmd.WaitDelay = commandWaitDelay
+	cmd.Stdin = stdin
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, errors.Join(ErrCLIExecution, ctxErr)
+		}
+		return nil, errors.Join(ErrCLIExecution, errors.New(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// streamCommand executes a CLI and calls onLine for every line it writes to
+// stdout, as soon as the line is available. It returns all of stdout once the
+// command exits, so callers can reuse their buffered response parsers. A
+// line longer than maxStreamLine kills the CLI and fails the command.
+func streamCommand(ctx context.Context, onLine func(line []byte), name string, args ...string) ([]byte, error) {
+	// kill stops the CLI when its output cannot be read, which would
+	// otherwise leave it blocked on a full pipe
+	cmdCtx, kill := context.WithCancel(ctx)
+	defer kill()
+
+	cmd := exec.CommandContext(cmdCtx, name, args...)
+	configureProcessGroup(cmd)
+	cmd.WaitDelay = commandWaitDelay
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	pipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Join(ErrCLIExecution, err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Join(ErrCLIExecution, err)
+	}
+
+	var stdout bytes.Buffer
+	scanner := bufio.NewScanner(pipe)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		stdout.Write(line)
+		stdout.WriteByte('\n')
+		onLine(line)
+	}
+	if err := scanner.Err(); err != nil {
+		kill()
+		cmd.Wait()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, errors.Join(ErrCLIExecution, ctxErr)
+		}
+		return nil, errors.Join(ErrCLIExecution, err)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, errors.Join(ErrCLIExecution, ctxErr)
+		}
+		return nil, errors.Join(ErrCLIExecution, errors.New(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
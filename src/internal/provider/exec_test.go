@@ -0,0 +1,107 @@
+//go:build !windows
+
+package provider
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRunCommand_Success(t *testing.T) {
+	out, err := runCommand(context.Background(), "sh", "-c", "echo SELECT 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(out) != "SELECT 1\n" {
+		t.Errorf("expected %q, got %q", "SELECT 1\n", string(out))
+	}
+}
+
+func TestRunCommand_FailureIncludesStderr(t *testing.T) {
+	_, err := runCommand(context.Background(), "sh", "-c", "echo boom >&2; exit 1")
+	if !errors.Is(err, ErrCLIExecution) {
+		t.Fatalf("expected ErrCLIExecution, got %v", err)
+	}
+
+	if got := err.Error(); got != "CLI execution failed\nboom\n" {
+		t.Errorf("unexpected error message: %q", got)
+	}
+}
+
+func TestRunCommand_CancelKillsProcessGroup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// The background sleep inherits stdout; if only the shell were killed,
+	// Run would block until WaitDelay expires.
+	start := time.Now()
+	_, err := runCommand(ctx, "sh", "-c", "sleep 30 & sleep 30")
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, ErrCLIExecution) {
+		t.Errorf("expected ErrCLIExecution, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed >= commandWaitDelay {
+		t.Errorf("expected process group to be killed promptly, took %s", elapsed)
+	}
+}
+
+func TestStreamCommand_DeliversLinesIncrementally(t *testing.T) {
+	var lines []string
+	out, err := streamCommand(context.Background(), func(line []byte) {
+		lines = append(lines, string(line))
+	}, "sh", "-c", "echo one; echo two")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(lines) != 2 || lines[0] != "one" || lines[1] != "two" {
+		t.Errorf("unexpected lines: %q", lines)
+	}
+	if string(out) != "one\ntwo\n" {
+		t.Errorf("unexpected stdout: %q", string(out))
+	}
+}
+
+func TestStreamCommand_Failure(t *testing.T) {
+	_, err := streamCommand(context.Background(), func([]byte) {}, "sh", "-c", "echo boom >&2; exit 1")
+	if !errors.Is(err, ErrCLIExecution) {
+		t.Errorf("expected ErrCLIExecution, got %v", err)
+	}
+}
+
+func TestStreamCommand_LineTooLong(t *testing.T) {
+	start := time.Now()
+	_, err := streamCommand(context.Background(), func([]byte) {}, "sh", "-c", fmt.Sprintf("head -c %d /dev/zero | tr '\\0' a; sleep 30", maxStreamLine+1))
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, ErrCLIExecution) || !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("expected ErrCLIExecution and bufio.ErrTooLong, got %v", err)
+	}
+	if elapsed >= commandWaitDelay {
+		t.Errorf("expected the CLI to be killed promptly, took %s", elapsed)
+	}
+}
+
+func TestStreamCommand_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	start := time.Now()
+	_, err := streamCommand(ctx, func([]byte) { cancel() }, "sh", "-c", "echo started; sleep 30 & sleep 30")
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if elapsed >= commandWaitDelay {
+		t.Errorf("expected process group to be killed promptly, took %s", elapsed)
+	}
+}
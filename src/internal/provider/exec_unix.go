@@ -0,0 +1,17 @@
+//go:build !windows
+
+package provider
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// configureProcessGroup starts the CLI in its own process group and makes
+// cancellation kill the entire group instead of only the direct child.
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package provider
+
+import (
+	"os/exec"
+)
+
+// configureProcessGroup is a no-op on Windows, where exec.CommandContext
+// already kills the direct child on cancellation.
+func configureProcessGroup(cmd *exec.Cmd) {}
@@ -1,53 +1,55 @@
 package provider
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
-	"os/exec"
 	"strings"
-)
-
-const geminiPromptTemplate = `You are a %s expert. Generate ONLY a raw SQL query with no markdown, no explanations, no code blocks. Format the SQL nicely with 2-space indentation.
-
-DDL: %s
-Question: %s
 
-Respond with ONLY the SQL query.`
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
+)
 
 // GeminiClient implements SQLGenerator using the Gemini CLI.
 type GeminiClient struct {
 	database string
+	prompts  *prompt.Set
 }
 
 // NewGeminiClient creates a new Gemini CLI client.
-func NewGeminiClient(database string) *GeminiClient {
-	return &GeminiClient{database: database}
+func NewGeminiClient(database string, prompts *prompt.Set) *GeminiClient {
+	return &GeminiClient{database: database, prompts: prompts}
+}
+
+// Binary returns the name of the Gemini CLI executable.
+func (g *GeminiClient) Binary() string {
+	return "gemini"
 }
 
 // GenerateSQL calls the Gemini CLI to generate SQL from DDL and a question.
-func (g *GeminiClient) GenerateSQL(ddl, question string) (string, error) {
-	prompt := FormatPrompt(geminiPromptTemplate, g.database, ddl, question)
+func (g *GeminiClient) GenerateSQL(ctx context.Context, req Request) (Result, error) {
+	text, err := g.prompts.Render(prompt.Prompt, promptData("gemini", g.database, req))
+	if err != nil {
+		return Result{}, err
+	}
 
-	cmd := exec.Command("gemini",
-		"-p", prompt,
+	args := []string{
+		"-p", text,
 		"--output-format", "json",
-	)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	}
+	if req.Model != "" {
+		args = append(args, "--model="+req.Model)
+	}
 
-	if err := cmd.Run(); err != nil {
-		return "", errors.Join(ErrCLIExecution, errors.New(stderr.String()))
+	stdout, err := runCommand(ctx, "gemini", args...)
+	if err != nil {
+		return Result{}, err
 	}
 
-	sql, err := parseGeminiResponse(stdout.Bytes())
+	sql, err := parseGeminiResponse(stdout)
 	if err != nil {
-		return "", err
+		return Result{}, err
 	}
 
-	return sql, nil
+	return Result{SQL: sql, Model: req.Model, Raw: string(stdout)}, nil
 }
 
 // parseGeminiResponse extracts the SQL from Gemini's JSON response.
@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
+)
+
+// Response format modes supported by OpenAICompatibleClient.
+const (
+	ResponseFormatJSONSchema = "json_schema"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatText       = "text"
+)
+
+// OpenAICompatibleClient implements SQLGenerator against any server exposing
+// the OpenAI /v1/chat/completions endpoint (OpenAI, Ollama, llama.cpp,
+// vLLM, LM Studio, ...).
+type OpenAICompatibleClient struct {
+	database       string
+	prompts        *prompt.Set
+	baseURL        string
+	apiKey         string
+	model          string
+	responseFormat string
+	httpClient     *http.Client
+}
+
+// NewOpenAICompatibleClient creates a new chat completions client. baseURL
+// must include the API version prefix, e.g. http://localhost:11434/v1.
+func NewOpenAICompatibleClient(database string, prompts *prompt.Set, baseURL, apiKey, model, responseFormat string) *OpenAICompatibleClient {
+	return &OpenAICompatibleClient{
+		database:       database,
+		prompts:        prompts,
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		apiKey:         apiKey,
+		model:          model,
+		responseFormat: responseFormat,
+		httpClient:     http.DefaultClient,
+	}
+}
+
+// CheckAuth lists the available models, which verifies both that the server
+// is reachable and that the API key (if any) is accepted.
+func (c *OpenAICompatibleClient) CheckAuth(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	if c.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	if _, err := doAPIRequest(c.httpClient, httpReq); err != nil {
+		return errors.Join(ErrNotAuthenticated, err)
+	}
+	return nil
+}
+
+type openaiMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openaiJSONSchemaFormat struct {
+	Name   string          `json:"name"`
+	Strict bool            `json:"strict"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+type openaiResponseFormat struct {
+	Type       string                  `json:"type"`
+	JSONSchema *openaiJSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+// openaiRequest is the body of a POST /chat/completions request.
+type openaiRequest struct {
+	Model          string                `json:"model"`
+	Messages       []openaiMessage       `json:"messages"`
+	ResponseFormat *openaiResponseFormat `json:"response_format,omitempty"`
+	// ReasoningEffort is only understood by reasoning models.
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+}
+
+// SupportsEffort reports that reasoning_effort is sent to the server.
+func (c *OpenAICompatibleClient) SupportsEffort() bool {
+	return true
+}
+
+// GenerateSQL calls the chat completions endpoint to generate SQL from DDL and a question.
+func (c *OpenAICompatibleClient) GenerateSQL(ctx context.Context, req Request) (Result, error) {
+	payload, err := c.buildRequest(req)
+	if err != nil {
+		return Result{}, err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Result{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	data, err := doAPIRequest(c.httpClient, httpReq)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result, err := parseOpenAIResponse(data)
+	if err != nil {
+		return Result{}, err
+	}
+	result.Model, result.Raw = payload.Model, string(data)
+
+	return result, nil
+}
+
+// buildRequest builds the chat completions payload for the configured
+// response format.
+func (c *OpenAICompatibleClient) buildRequest(r Request) (openaiRequest, error) {
+	parts, err := renderPrompts(c.prompts, promptData("openai", c.database, r), prompt.System, prompt.Schema, prompt.Question)
+	if err != nil {
+		return openaiRequest{}, err
+	}
+
+	req := openaiRequest{
+		Model: c.model,
+		Messages: []openaiMessage{
+			{Role: "system", Content: parts[0]},
+			{Role: "user", Content: parts[1] + "\n" + parts[2]},
+		},
+		ReasoningEffort: r.Effort,
+	}
+	if r.Model != "" {
+		req.Model = r.Model
+	}
+
+	switch c.responseFormat {
+	case ResponseFormatJSONSchema:
+		req.ResponseFormat = &openaiResponseFormat{
+			Type: ResponseFormatJSONSchema,
+			JSONSchema: &openaiJSONSchemaFormat{
+				Name:   "sql_response",
+				Strict: true,
+				Schema: json.RawMessage(answerSchema(r.Clarify, true)),
+			},
+		}
+	case ResponseFormatJSONObject:
+		// JSON mode requires the word "JSON" to appear in the messages
+		req.Messages[0].Content += ` Respond with a JSON object of the form {"sql": "...", "explanation": "...", "assumptions": ["..."], "confidence": 0.9, "tables": ["..."]}.`
+		if r.Clarify {
+			req.Messages[0].Content += ` To ask a clarifying question instead, respond with {"type": "clarification", "question": "...", "options": ["..."]}.`
+		}
+		req.ResponseFormat = &openaiResponseFormat{Type: ResponseFormatJSONObject}
+	}
+
+	return req, nil
+}
+
+// parseOpenAIResponse extracts the SQL from a chat completions response.
+func parseOpenAIResponse(data []byte) (Result, error) {
+	// OpenAI returns: {"choices": [{"message": {"content": "..."}}], ...}
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+
+	if err := json.Unmarshal(data, &response); err != nil {
+		return Result{}, errors.Join(ErrParsing, err)
+	}
+	if len(response.Choices) == 0 {
+		return Result{}, ErrParsing
+	}
+
+	// Structured output: the content itself is {"sql": "..."}, or a
+	// clarifying question for requests that allow one. Without
+	// response_format the content is plain text.
+	return parseAnswer(response.Choices[0].Message.Content)
+}
@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
+)
+
+func TestParseOpenAIResponse_StructuredContent(t *testing.T) {
+	input := `{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"{\"sql\":\"SELECT * FROM users\"}"},"finish_reason":"stop"}]}`
+
+	result, err := parseOpenAIResponse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "SELECT * FROM users"
+	if result.SQL != expected {
+		t.Errorf("expected %q, got %q", expected, result.SQL)
+	}
+}
+
+func TestParseOpenAIResponse_PlainContent(t *testing.T) {
+	input := `{"choices":[{"message":{"role":"assistant","content":"` + "```sql\\nSELECT 1\\n```" + `"}}]}`
+
+	result, err := parseOpenAIResponse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.SQL != "SELECT 1" {
+		t.Errorf("expected %q, got %q", "SELECT 1", result.SQL)
+	}
+}
+
+func TestParseOpenAIResponse_Clarification(t *testing.T) {
+	input := `{"choices":[{"message":{"content":"{\"type\":\"clarification\",\"sql\":\"\",\"question\":\"Gross or net revenue?\",\"options\":[\"gross\",\"net\"]}"}}]}`
+
+	_, err := parseOpenAIResponse([]byte(input))
+	var clarification *Clarification
+	if !errors.As(err, &clarification) || clarification.Question != "Gross or net revenue?" {
+		t.Errorf("expected a clarification, got %v", err)
+	}
+}
+
+func TestParseOpenAIResponse_NoChoices(t *testing.T) {
+	_, err := parseOpenAIResponse([]byte(`{"choices":[]}`))
+	if !errors.Is(err, ErrParsing) {
+		t.Errorf("expected ErrParsing, got %v", err)
+	}
+}
+
+func TestParseOpenAIResponse_EmptyContent(t *testing.T) {
+	_, err := parseOpenAIResponse([]byte(`{"choices":[{"message":{"content":"  "}}]}`))
+	if !errors.Is(err, ErrParsing) {
+		t.Errorf("expected ErrParsing, got %v", err)
+	}
+}
+
+func TestOpenAICompatibleClient_ResponseFormats(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{ResponseFormatJSONSchema, "json_schema"},
+		{ResponseFormatJSONObject, "json_object"},
+		{ResponseFormatText, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			client := NewOpenAICompatibleClient("DuckDB", prompt.Default(), "http://localhost/v1", "", "llama3", tc.format)
+			req, err := client.buildRequest(Request{DDL: "CREATE TABLE users (id INT)", Question: "Select all users"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := ""
+			if req.ResponseFormat != nil {
+				got = req.ResponseFormat.Type
+			}
+			if got != tc.expected {
+				t.Errorf("expected response_format %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestOpenAICompatibleClient_Clarify(t *testing.T) {
+	client := NewOpenAICompatibleClient("DuckDB", prompt.Default(), "http://localhost/v1", "", "llama3", ResponseFormatJSONSchema)
+
+	req, err := client.buildRequest(Request{DDL: "CREATE TABLE users (id INT)", Question: "Select all users", Clarify: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(req.ResponseFormat.JSONSchema.Schema) != answerSchema(true, true) {
+		t.Errorf("expected the clarification schema, got %s", req.ResponseFormat.JSONSchema.Schema)
+	}
+	if !strings.Contains(req.Messages[0].Content, `"type": "clarification"`) {
+		t.Errorf("expected clarification instructions, got %q", req.Messages[0].Content)
+	}
+}
+
+func TestOpenAICompatibleClient_GenerateSQL(t *testing.T) {
+	var got openaiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("expected bearer auth, got %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"content":"{\"sql\":\"SELECT COUNT(*) FROM users\"}"}}]}`))
+	}))
+	defer server.Close()
+
+	client := NewOpenAICompatibleClient("DuckDB", prompt.Default(), server.URL+"/v1/", "secret", "qwen2.5-coder", ResponseFormatJSONSchema)
+	result, err := client.GenerateSQL(context.Background(), Request{DDL: "CREATE TABLE users (id INT)", Question: "How many users?"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.SQL != "SELECT COUNT(*) FROM users" {
+		t.Errorf("unexpected SQL: %q", result.SQL)
+	}
+	if got.Model != "qwen2.5-coder" || result.Model != "qwen2.5-coder" {
+		t.Errorf("expected model qwen2.5-coder, got %q", got.Model)
+	}
+	if len(got.Messages) != 2 || got.Messages[1].Content != "DDL: CREATE TABLE users (id INT)\nQuestion: How many users?" {
+		t.Errorf("unexpected messages: %+v", got.Messages)
+	}
+	if string(got.ResponseFormat.JSONSchema.Schema) != answerSchema(false, true) {
+		t.Errorf("unexpected schema: %s", got.ResponseFormat.JSONSchema.Schema)
+	}
+}
+
+func TestOpenAICompatibleClient_GenerateSQL_NoAPIKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("expected no Authorization header, got %q", auth)
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewOpenAICompatibleClient("DuckDB", prompt.Default(), server.URL, "", "llama3", ResponseFormatText)
+	_, err := client.GenerateSQL(context.Background(), Request{DDL: "CREATE TABLE users (id INT)", Question: "How many users?"})
+	if !errors.Is(err, ErrAPIRequest) {
+		t.Errorf("expected ErrAPIRequest, got %v", err)
+	}
+}
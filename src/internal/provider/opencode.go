@@ -3,52 +3,98 @@ package provider
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
-	"os/exec"
 	"strings"
-)
-
-const opencodePromptTemplate = `You are a %s expert. Generate ONLY a raw SQL query with no markdown, no explanations, no code blocks. Format the SQL nicely with 2-space indentation.
-
-DDL: %s
-Question: %s
 
-Respond with ONLY the SQL query.`
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
+)
 
 // OpenCodeClient implements SQLGenerator using the OpenCode CLI.
 type OpenCodeClient struct {
 	database string
+	prompts  *prompt.Set
 }
 
 // NewOpenCodeClient creates a new OpenCode CLI client.
-func NewOpenCodeClient(database string) *OpenCodeClient {
-	return &OpenCodeClient{database: database}
+func NewOpenCodeClient(database string, prompts *prompt.Set) *OpenCodeClient {
+	return &OpenCodeClient{database: database, prompts: prompts}
+}
+
+// Binary returns the name of the OpenCode CLI executable.
+func (c *OpenCodeClient) Binary() string {
+	return "opencode"
 }
 
 // GenerateSQL calls the OpenCode CLI to generate SQL from DDL and a question.
-func (c *OpenCodeClient) GenerateSQL(ddl, question string) (string, error) {
-	prompt := FormatPrompt(opencodePromptTemplate, c.database, ddl, question)
+func (c *OpenCodeClient) GenerateSQL(ctx context.Context, req Request) (Result, error) {
+	args, err := c.buildArgs(req)
+	if err != nil {
+		return Result{}, err
+	}
 
-	cmd := exec.Command("opencode", "run",
-		prompt,
-		"--format", "json",
-	)
+	stdout, err := runCommand(ctx, "opencode", args...)
+	if err != nil {
+		return Result{}, err
+	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	sql, err := parseOpenCodeResponse(stdout)
+	if err != nil {
+		return Result{}, err
+	}
+	reportSession(req, opencodeSessionID(stdout))
+
+	return Result{SQL: sql, Model: req.Model, Raw: string(stdout)}, nil
+}
+
+// GenerateSQLStream calls the OpenCode CLI and emits its text events as
+// partial output while it is generating.
+func (c *OpenCodeClient) GenerateSQLStream(ctx context.Context, req Request, emit func(StreamEvent)) (Result, error) {
+	args, err := c.buildArgs(req)
+	if err != nil {
+		return Result{}, err
+	}
 
-	if err := cmd.Run(); err != nil {
-		return "", errors.Join(ErrCLIExecution, errors.New(stderr.String()))
+	stdout, err := streamCommand(ctx, func(line []byte) {
+		if event, ok := parseOpenCodeStreamEvent(line); ok {
+			emit(event)
+		}
+	}, "opencode", args...)
+	if err != nil {
+		return Result{}, err
 	}
 
-	sql, err := parseOpenCodeResponse(stdout.Bytes())
+	sql, err := parseOpenCodeResponse(stdout)
 	if err != nil {
-		return "", err
+		return Result{}, err
 	}
+	reportSession(req, opencodeSessionID(stdout))
 
-	return sql, nil
+	return Result{SQL: sql, Model: req.Model, Raw: string(stdout)}, nil
+}
+
+// buildArgs builds the "opencode run" arguments. OpenCode models are named
+// provider/model, e.g. anthropic/claude-sonnet-4-5. A resumed session
+// already holds the schema, so only the question is sent.
+func (c *OpenCodeClient) buildArgs(req Request) ([]string, error) {
+	name := prompt.Prompt
+	if req.SessionID != "" {
+		name = prompt.Question
+	}
+	text, err := c.prompts.Render(name, promptData("opencode", c.database, req))
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"run", text, "--format", "json"}
+	if req.SessionID != "" {
+		args = append(args, "--session", req.SessionID)
+	}
+	if req.Model != "" {
+		args = append(args, "--model="+req.Model)
+	}
+
+	return args, nil
 }
 
 // opencodeEvent represents a single NDJSON event from OpenCode.
@@ -96,3 +142,30 @@ func parseOpenCodeResponse(data []byte) (string, error) {
 
 	return "", ErrParsing
 }
+
+// opencodeSessionID returns the session ID of OpenCode's NDJSON output, or
+// "" if there is none.
+func opencodeSessionID(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var event opencodeEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err == nil && event.SessionID != "" {
+			return event.SessionID
+		}
+	}
+	return ""
+}
+
+// parseOpenCodeStreamEvent turns an OpenCode text event into a partial event.
+func parseOpenCodeStreamEvent(line []byte) (StreamEvent, bool) {
+	var event opencodeEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		return StreamEvent{}, false
+	}
+
+	if event.Type != "text" || event.Content == "" {
+		return StreamEvent{}, false
+	}
+
+	return StreamEvent{Type: EventPartial, Text: event.Content}, true
+}
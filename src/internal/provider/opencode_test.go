@@ -1,7 +1,11 @@
 package provider
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/session"
 )
 
 func TestParseOpenCodeResponse_TextEvent(t *testing.T) {
@@ -156,3 +160,65 @@ func TestParseOpenCodeResponse_ComplexSQL(t *testing.T) {
 		t.Errorf("expected %q, got %q", expected, sql)
 	}
 }
+
+func TestParseOpenCodeStreamEvent_Text(t *testing.T) {
+	input := `{"type":"text","timestamp":1234567891,"sessionID":"abc123","content":"SELECT 1"}`
+
+	event, ok := parseOpenCodeStreamEvent([]byte(input))
+	if !ok {
+		t.Fatal("expected a partial event")
+	}
+
+	if event.Type != EventPartial || event.Text != "SELECT 1" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+}
+
+func TestParseOpenCodeStreamEvent_Ignored(t *testing.T) {
+	inputs := []string{
+		`{"type":"step_start","sessionID":"abc123"}`,
+		`{"type":"text","content":""}`,
+		`not json`,
+	}
+
+	for _, input := range inputs {
+		if event, ok := parseOpenCodeStreamEvent([]byte(input)); ok {
+			t.Errorf("expected %s to be ignored, got %+v", input, event)
+		}
+	}
+}
+
+func TestOpenCodeClient_BuildArgs_Session(t *testing.T) {
+	client := NewOpenCodeClient("DuckDB", prompt.Default())
+	history := []session.Turn{{Question: "Orders per user", SQL: "SELECT user_id, COUNT(*) FROM t GROUP BY 1"}}
+
+	args, err := client.buildArgs(Request{DDL: "CREATE TABLE t (id INT)", Question: "Now per month", History: history})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(args[1], "DDL: CREATE TABLE t (id INT)") || !strings.Contains(args[1], "Question: Orders per user") {
+		t.Errorf("expected the schema and the earlier turns, got %q", args[1])
+	}
+
+	args, err = client.buildArgs(Request{DDL: "CREATE TABLE t (id INT)", Question: "Now per month", History: history, SessionID: "ses_1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if args[1] != "Question: Now per month" {
+		t.Errorf("expected only the question in a resumed session, got %q", args[1])
+	}
+	if got := strings.Join(args, " "); !strings.HasSuffix(got, "--session ses_1") {
+		t.Errorf("expected --session ses_1, got %q", got)
+	}
+}
+
+func TestOpenCodeSessionID(t *testing.T) {
+	input := "{\"type\":\"step_start\",\"sessionID\":\"ses_1\"}\n{\"type\":\"text\",\"sessionID\":\"ses_1\",\"content\":\"SELECT 1\"}\n"
+
+	if got := opencodeSessionID([]byte(input)); got != "ses_1" {
+		t.Errorf("expected session ses_1, got %q", got)
+	}
+	if got := opencodeSessionID([]byte("SELECT 1")); got != "" {
+		t.Errorf("expected no session, got %q", got)
+	}
+}
@@ -1,19 +1,289 @@
 package provider
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/dialect"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/examples"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/hints"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/schema"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/semantic"
+	"github.com/tobilg/text-to-sql-proxy/src/internal/session"
 )
 
 var (
 	ErrCLIExecution = errors.New("CLI execution failed")
 	ErrParsing      = errors.New("failed to parse response")
+	ErrAPIRequest   = errors.New("API request failed")
+	// ErrNotAuthenticated is returned by auth probes that found no usable
+	// credentials.
+	ErrNotAuthenticated = errors.New("not authenticated")
+)
+
+// Reasoning effort levels accepted in Request.Effort.
+const (
+	EffortLow    = "low"
+	EffortMedium = "medium"
+	EffortHigh   = "high"
 )
 
+// Request is a single SQL generation request.
+type Request struct {
+	DDL      string
+	Question string
+	// Database overrides the provider's target database if set.
+	Database string
+	// Examples are few-shot examples to include in the prompt.
+	Examples []examples.Example
+	// SampleRows and ColumnValues are data from the schema's tables to
+	// include next to the DDL.
+	SampleRows   []hints.SampleRows
+	ColumnValues []hints.ColumnValues
+	// Semantic holds the semantic layer entries relevant to the question.
+	Semantic semantic.Layer
+	// Joins are the join paths between the tables the question mentions.
+	Joins []schema.Join
+	// Now is the current time the question's relative dates refer to, in
+	// the timezone named by Timezone. Dates is prompt.DatesLiteral or
+	// prompt.DatesFunctions.
+	Now      time.Time
+	Timezone string
+	Dates    string
+	// History holds the earlier turns of the conversation the question
+	// follows up on. They are replayed in the prompt unless SessionID is
+	// set: the provider's own session of the conversation, which already
+	// holds them. Providers that keep sessions report the session that
+	// answered to OnSession, if set.
+	History   []session.Turn
+	SessionID string
+	OnSession func(id string)
+	// Clarify lets the model ask a clarifying question instead of guessing
+	// SQL for an ambiguous question; see Clarification.
+	Clarify bool
+	// Corrections are earlier answers to the question that failed
+	// validation against the DDL, for the model to correct.
+	Corrections []schema.Correction
+	// Draft is SQL another provider generated for the question, for this
+	// one to review; see strategy.Critic.
+	Draft string
+	// Model overrides the provider's default model if set.
+	Model string
+	// Effort is the reasoning effort for providers implementing
+	// EffortSupporter; other providers ignore it.
+	Effort string
+}
+
+// Result is the SQL a provider generated, with what it reported about it.
+type Result struct {
+	SQL string
+	// Explanation is a short description of the query, Assumptions what
+	// the model assumed where the question or schema was not explicit,
+	// Confidence its confidence from 0 to 1 that the query answers the
+	// question and Tables the tables the query reads. They are only set by
+	// providers with structured output.
+	Explanation string
+	Assumptions []string
+	Confidence  float64
+	Tables      []string
+	// Provider is the provider's name and Duration how long it took, both
+	// set by Generate. Model is the requested or configured model, empty
+	// if the CLI used its own default.
+	Provider string
+	Model    string
+	Duration time.Duration
+	// Raw is the provider's unparsed output, for debugging.
+	Raw string
+	// Corrections are the drafts rejected by schema validation before this
+	// result, set by the caller that re-prompted the provider.
+	Corrections []schema.Correction
+}
+
 // SQLGenerator defines the interface for SQL generation providers.
+// Implementations must abort any in-flight work when ctx is cancelled.
 type SQLGenerator interface {
-	GenerateSQL(ddl, question string) (string, error)
+	GenerateSQL(ctx context.Context, req Request) (Result, error)
+}
+
+// Stream event types emitted by StreamingSQLGenerator implementations.
+const (
+	// EventPartial carries a fragment of the model's output text.
+	EventPartial = "partial"
+	// EventProgress reports a step the CLI is performing, e.g. reasoning.
+	EventProgress = "progress"
+)
+
+// StreamEvent is an incremental update emitted while SQL is being generated.
+type StreamEvent struct {
+	Type  string `json:"-"`
+	Stage string `json:"stage,omitempty"`
+	Text  string `json:"text,omitempty"`
+}
+
+// StreamingSQLGenerator is implemented by providers that can report output
+// while the CLI is still running. Providers that only implement SQLGenerator
+// are served through the buffered path.
+type StreamingSQLGenerator interface {
+	SQLGenerator
+	GenerateSQLStream(ctx context.Context, req Request, emit func(StreamEvent)) (Result, error)
+}
+
+// Generate runs a request against the provider registered as name and sets
+// the result's Provider and Duration. If emit is set, providers that
+// support streaming report their output to it while they are generating.
+func Generate(ctx context.Context, name string, g SQLGenerator, req Request, emit func(StreamEvent)) (Result, error) {
+	start := time.Now()
+	var result Result
+	var err error
+	if sg, ok := g.(StreamingSQLGenerator); ok && emit != nil {
+		result, err = sg.GenerateSQLStream(ctx, req, emit)
+	} else {
+		result, err = g.GenerateSQL(ctx, req)
+	}
+	result.Provider, result.Duration = name, time.Since(start)
+	return result, err
+}
+
+// EffortSupporter is implemented by providers that can pass a reasoning
+// effort to the model.
+type EffortSupporter interface {
+	SupportsEffort() bool
+}
+
+// Describer is implemented by providers that carry their own human-readable
+// description, such as config-defined command providers.
+type Describer interface {
+	Description() string
+}
+
+// Clarification is the error GenerateSQL returns when the model asked a
+// clarifying question back instead of generating SQL, which requests only
+// allow if they set Clarify. Provider is set by the caller to the provider
+// that asked.
+type Clarification struct {
+	Question string   `json:"question"`
+	Options  []string `json:"options,omitempty"`
+	Provider string   `json:"-"`
+}
+
+func (c *Clarification) Error() string {
+	return "asked for clarification: " + c.Question
+}
+
+// ParseClarification returns the clarification in a model's answer, or nil
+// if the answer is not one. Models that are allowed to ask answer with
+// {"type": "clarification", "question": "...", "options": [...]}, possibly
+// in a markdown code block.
+func ParseClarification(answer string) *Clarification {
+	var response structuredAnswer
+	start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}")
+	if start < 0 || end < start || json.Unmarshal([]byte(answer[start:end+1]), &response) != nil {
+		return nil
+	}
+	return response.clarification()
+}
+
+// structuredAnswer is an answer in the shape of answerSchema.
+type structuredAnswer struct {
+	Type        string   `json:"type"`
+	SQL         string   `json:"sql"`
+	Explanation string   `json:"explanation"`
+	Assumptions []string `json:"assumptions"`
+	Confidence  float64  `json:"confidence"`
+	Tables      []string `json:"tables"`
+	Question    string   `json:"question"`
+	Options     []string `json:"options"`
+}
+
+// clarification returns the clarifying question of the answer, or nil if
+// it carries none.
+func (a *structuredAnswer) clarification() *Clarification {
+	if a.Type != "clarification" || strings.TrimSpace(a.Question) == "" {
+		return nil
+	}
+	return &Clarification{Question: strings.TrimSpace(a.Question), Options: a.Options}
+}
+
+// result returns the answer's SQL and what the model reported about it,
+// a *Clarification if the model asked back, or ErrParsing if the answer
+// carries neither.
+func (a *structuredAnswer) result() (Result, error) {
+	if c := a.clarification(); c != nil {
+		return Result{}, c
+	}
+	if strings.TrimSpace(a.SQL) == "" {
+		return Result{}, ErrParsing
+	}
+	return Result{
+		SQL:         CleanSQL(a.SQL),
+		Explanation: strings.TrimSpace(a.Explanation),
+		Assumptions: a.Assumptions,
+		Confidence:  min(max(a.Confidence, 0), 1),
+		Tables:      a.Tables,
+	}, nil
+}
+
+// parseAnswer returns the result of a model's text answer: the structured
+// answer if the text is one, else the text as SQL.
+func parseAnswer(text string) (Result, error) {
+	var answer structuredAnswer
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &answer); err == nil {
+		if result, err := answer.result(); !errors.Is(err, ErrParsing) {
+			return result, err
+		}
+	}
+	if sql := CleanSQL(text); sql != "" {
+		return Result{SQL: sql}, nil
+	}
+	return Result{}, ErrParsing
+}
+
+// answerSchema returns the JSON schema of structured answers: the SQL with
+// an explanation, assumptions, a confidence and the tables it reads, or, if
+// clarify is set, a clarifying question instead. Strict schemas, as OpenAI
+// structured outputs require them, forbid additional properties and require
+// every property, leaving those that do not apply empty.
+func answerSchema(clarify, strict bool) string {
+	text := func(description string) map[string]any {
+		return map[string]any{"type": "string", "description": description}
+	}
+	list := func(description string) map[string]any {
+		return map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": description}
+	}
+	properties := map[string]any{
+		"sql":         text("The SQL query"),
+		"explanation": text("One or two sentences on what the query does"),
+		"assumptions": list("Assumptions made where the question or the schema is not explicit"),
+		"confidence":  map[string]any{"type": "number", "description": "Confidence from 0 to 1 that the query answers the question"},
+		"tables":      list("The tables the query reads"),
+	}
+	required := []string{"sql"}
+	if clarify {
+		properties["type"] = map[string]any{"type": "string", "enum": []string{"sql", "clarification"}}
+		properties["question"] = text("The clarifying question, if the question is ambiguous")
+		properties["options"] = list("Likely answers to the clarifying question")
+		required = []string{"type"}
+	}
+
+	schema := map[string]any{"type": "object", "properties": properties, "required": required}
+	if strict {
+		required = make([]string, 0, len(properties))
+		for name := range properties {
+			required = append(required, name)
+		}
+		slices.Sort(required)
+		schema["required"] = required
+		schema["additionalProperties"] = false
+	}
+
+	data, _ := json.Marshal(schema)
+	return string(data)
 }
 
 // CleanSQL removes any markdown code blocks or extra formatting from SQL.
@@ -24,18 +294,67 @@ func CleanSQL(sql string) string {
 		sql = matches[1]
 	}
 
-	// Remove backticks (MySQL-style quoting) - DuckDB doesn't use them
-	sql = strings.ReplaceAll(sql, "`", "")
-
 	return strings.TrimSpace(sql)
 }
 
-// FormatPrompt is a helper to format prompts with database, DDL and question.
-func FormatPrompt(template, database, ddl, question string) string {
-	return strings.Replace(
-		strings.Replace(
-			strings.Replace(template, "%s", database, 1),
-			"%s", ddl, 1),
-		"%s", question, 1,
-	)
+// targetDatabase returns the database a request targets: its own, or the
+// provider's default.
+func targetDatabase(database string, req Request) string {
+	if req.Database != "" {
+		return req.Database
+	}
+	return database
+}
+
+// promptData returns the prompt template data for a request to the named
+// provider. Databases with a dialect profile are named by its title, e.g.
+// "PostgreSQL 12".
+func promptData(providerName, database string, req Request) prompt.Data {
+	database = targetDatabase(database, req)
+	data := prompt.Data{
+		Provider:     providerName,
+		Database:     database,
+		Dialect:      dialect.Lookup(database),
+		DDL:          req.DDL,
+		SampleRows:   req.SampleRows,
+		ColumnValues: req.ColumnValues,
+		Question:     req.Question,
+		Examples:     req.Examples,
+		Semantic:     req.Semantic,
+		Joins:        req.Joins,
+		Now:          req.Now,
+		Timezone:     req.Timezone,
+		Dates:        req.Dates,
+		Clarify:      req.Clarify,
+		Corrections:  req.Corrections,
+		Draft:        req.Draft,
+	}
+	if req.SessionID == "" {
+		data.History = req.History
+	}
+	if data.Dialect != nil {
+		data.Database = data.Dialect.Title
+	}
+	return data
+}
+
+// reportSession passes the session that answered a request to its
+// OnSession callback.
+func reportSession(req Request, id string) {
+	if req.OnSession != nil && id != "" {
+		req.OnSession(id)
+	}
+}
+
+// renderPrompts renders the named prompt templates in order.
+func renderPrompts(prompts *prompt.Set, data prompt.Data, names ...string) ([]string, error) {
+	parts := make([]string, len(names))
+	for i, name := range names {
+		text, err := prompts.Render(name, data)
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = text
+	}
+	return parts, nil
 }
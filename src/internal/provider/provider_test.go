@@ -1,9 +1,137 @@
 package provider
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
 )
 
+func TestParseClarification(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *Clarification
+	}{
+		{
+			name:     "clarification",
+			input:    `{"type": "clarification", "question": "Gross or net revenue?", "options": ["gross", "net"]}`,
+			expected: &Clarification{Question: "Gross or net revenue?", Options: []string{"gross", "net"}},
+		},
+		{
+			name:     "code block",
+			input:    "```json\n{\"type\": \"clarification\", \"question\": \"Which year?\"}\n```",
+			expected: &Clarification{Question: "Which year?"},
+		},
+		{name: "sql", input: "SELECT 1"},
+		{name: "structured sql", input: `{"type": "sql", "sql": "SELECT 1", "question": "", "options": []}`},
+		{name: "no question", input: `{"type": "clarification", "question": " "}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ParseClarification(tc.input)
+			if tc.expected == nil {
+				if got != nil {
+					t.Errorf("expected no clarification, got %+v", got)
+				}
+				return
+			}
+			if got == nil || got.Question != tc.expected.Question || strings.Join(got.Options, ",") != strings.Join(tc.expected.Options, ",") {
+				t.Errorf("expected %+v, got %+v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseAnswer(t *testing.T) {
+	result, err := parseAnswer(`{"sql": "SELECT name FROM users", "explanation": " Lists the user names. ", "assumptions": ["Deleted users count"], "confidence": 1.5, "tables": ["users"]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.SQL != "SELECT name FROM users" || result.Explanation != "Lists the user names." || result.Confidence != 1 ||
+		len(result.Assumptions) != 1 || strings.Join(result.Tables, ",") != "users" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	result, err = parseAnswer("```sql\nSELECT 1\n```")
+	if err != nil || result.SQL != "SELECT 1" || result.Explanation != "" {
+		t.Errorf("expected plain SQL, got %+v (%v)", result, err)
+	}
+
+	var c *Clarification
+	if _, err := parseAnswer(`{"type": "clarification", "sql": "", "question": "Gross or net?", "options": []}`); !errors.As(err, &c) {
+		t.Errorf("expected a clarification, got %v", err)
+	}
+
+	if _, err := parseAnswer(" "); !errors.Is(err, ErrParsing) {
+		t.Errorf("expected ErrParsing, got %v", err)
+	}
+}
+
+func TestAnswerSchema(t *testing.T) {
+	var schema struct {
+		Properties           map[string]any `json:"properties"`
+		Required             []string       `json:"required"`
+		AdditionalProperties *bool          `json:"additionalProperties"`
+	}
+
+	if err := json.Unmarshal([]byte(answerSchema(false, false)), &schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	if len(schema.Properties) != 5 || strings.Join(schema.Required, ",") != "sql" || schema.AdditionalProperties != nil {
+		t.Errorf("unexpected schema %+v", schema)
+	}
+
+	schema.Properties = nil
+	if err := json.Unmarshal([]byte(answerSchema(true, true)), &schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	if len(schema.Properties) != 8 || len(schema.Required) != 8 || !slices.Contains(schema.Required, "options") ||
+		schema.AdditionalProperties == nil || *schema.AdditionalProperties {
+		t.Errorf("expected every property required and no others, got %+v", schema)
+	}
+}
+
+// streamingGenerator records which of its methods Generate called.
+type streamingGenerator struct {
+	streamed bool
+}
+
+func (g *streamingGenerator) GenerateSQL(ctx context.Context, req Request) (Result, error) {
+	return Result{SQL: "SELECT 1"}, nil
+}
+
+func (g *streamingGenerator) GenerateSQLStream(ctx context.Context, req Request, emit func(StreamEvent)) (Result, error) {
+	g.streamed = true
+	emit(StreamEvent{Type: EventPartial, Text: "SELECT"})
+	return Result{SQL: "SELECT 1"}, nil
+}
+
+func TestGenerate(t *testing.T) {
+	g := &streamingGenerator{}
+
+	result, err := Generate(context.Background(), "claude", g, Request{}, nil)
+	if err != nil || result.SQL != "SELECT 1" || result.Provider != "claude" {
+		t.Errorf("unexpected result %+v (%v)", result, err)
+	}
+	if g.streamed {
+		t.Error("expected no streaming without emit")
+	}
+
+	var events []StreamEvent
+	if _, err := Generate(context.Background(), "claude", g, Request{}, func(e StreamEvent) { events = append(events, e) }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !g.streamed || len(events) != 1 {
+		t.Errorf("expected the provider to stream, got %d events", len(events))
+	}
+}
+
 func TestCleanSQL_RemovesMarkdownCodeBlock(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -31,9 +159,9 @@ func TestCleanSQL_RemovesMarkdownCodeBlock(t *testing.T) {
 			expected: "SELECT * FROM users",
 		},
 		{
-			name:     "with backticks",
+			name:     "keeps backtick quoting",
 			input:    "SELECT COUNT(*) FROM `aws_iam`.actions",
-			expected: "SELECT COUNT(*) FROM aws_iam.actions",
+			expected: "SELECT COUNT(*) FROM `aws_iam`.actions",
 		},
 	}
 
@@ -47,30 +175,33 @@ func TestCleanSQL_RemovesMarkdownCodeBlock(t *testing.T) {
 	}
 }
 
-func TestFormatPrompt(t *testing.T) {
-	template := "You are a %s expert.\nDDL: %s\nQuestion: %s"
-	database := "DuckDB"
-	ddl := "CREATE TABLE users (id INT)"
-	question := "Select all users"
+func TestRenderPrompts_LiteralPlaceholders(t *testing.T) {
+	req := Request{
+		DDL:      "CREATE TABLE events (ts TIMESTAMP); -- strftime(ts, '%s') {{.Question}}",
+		Question: "Count events",
+	}
 
-	result := FormatPrompt(template, database, ddl, question)
-	expected := "You are a DuckDB expert.\nDDL: CREATE TABLE users (id INT)\nQuestion: Select all users"
+	parts, err := renderPrompts(prompt.Default(), promptData("codex", "DuckDB", req), prompt.Schema, prompt.Question)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
-	if result != expected {
-		t.Errorf("expected %q, got %q", expected, result)
+	if parts[0] != "DDL: "+req.DDL {
+		t.Errorf("expected DDL to be inserted verbatim, got %q", parts[0])
+	}
+	if parts[1] != "Question: Count events" {
+		t.Errorf("expected question, got %q", parts[1])
 	}
 }
 
-func TestFormatPrompt_CustomDatabase(t *testing.T) {
-	template := "You are a %s expert.\nDDL: %s\nQuestion: %s"
-	database := "PostgreSQL"
-	ddl := "CREATE TABLE users (id INT)"
-	question := "Select all users"
-
-	result := FormatPrompt(template, database, ddl, question)
-	expected := "You are a PostgreSQL expert.\nDDL: CREATE TABLE users (id INT)\nQuestion: Select all users"
+func TestPromptData_DatabaseOverride(t *testing.T) {
+	data := promptData("codex", "DuckDB", Request{Database: "postgres"})
+	if data.Database != "PostgreSQL 16" || data.Dialect == nil || data.Dialect.Name != "postgresql-16" {
+		t.Errorf("expected the requested database, got %q (%v)", data.Database, data.Dialect)
+	}
 
-	if result != expected {
-		t.Errorf("expected %q, got %q", expected, result)
+	data = promptData("codex", "DuckDB", Request{})
+	if data.Database != "DuckDB" {
+		t.Errorf("expected the provider's database, got %q", data.Database)
 	}
 }
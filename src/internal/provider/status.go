@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// probeTimeout bounds each --version and auth probe invocation.
+const probeTimeout = 10 * time.Second
+
+// BinaryProvider is implemented by providers that shell out to a local CLI.
+type BinaryProvider interface {
+	Binary() string
+}
+
+// AuthChecker is implemented by providers that have a cheap way to verify
+// their credentials without generating anything.
+type AuthChecker interface {
+	CheckAuth(ctx context.Context) error
+}
+
+// Status describes whether a provider can currently be used.
+type Status struct {
+	Installed bool   `json:"installed"`
+	Version   string `json:"version,omitempty"`
+	Path      string `json:"path,omitempty"`
+	// Authenticated is nil when no auth probe ran for the provider.
+	Authenticated *bool     `json:"authenticated,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	CheckedAt     time.Time `json:"checked_at"`
+}
+
+// Probe inspects a provider: it resolves the CLI binary on PATH, captures
+// its --version output and, if checkAuth is set, runs the provider's auth
+// probe. Providers without a binary (HTTP APIs) are always installed.
+func Probe(ctx context.Context, p SQLGenerator, checkAuth bool) Status {
+	status := Status{Installed: true, CheckedAt: time.Now()}
+
+	if bp, ok := p.(BinaryProvider); ok {
+		path, err := exec.LookPath(bp.Binary())
+		if err != nil {
+			status.Installed = false
+			status.LastError = err.Error()
+			return status
+		}
+		status.Path = path
+
+		versionCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		out, err := runCommand(versionCtx, path, "--version")
+		cancel()
+		if err != nil {
+			status.LastError = err.Error()
+		} else {
+			status.Version = firstLine(string(out))
+		}
+	}
+
+	if ac, ok := p.(AuthChecker); ok && checkAuth {
+		authCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		err := ac.CheckAuth(authCtx)
+		cancel()
+
+		if !errors.Is(err, errNoAuthProbe) {
+			authenticated := err == nil
+			status.Authenticated = &authenticated
+			if err != nil {
+				status.LastError = err.Error()
+			}
+		}
+	}
+
+	return status
+}
+
+// firstLine returns the first non-empty line of s.
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// Monitor keeps the latest Status of every provider.
+type Monitor struct {
+	providers map[string]SQLGenerator
+	checkAuth bool
+
+	mu       sync.RWMutex
+	statuses map[string]Status
+}
+
+// NewMonitor creates a Monitor for the given providers. Call Refresh or Run
+// to populate it.
+func NewMonitor(providers map[string]SQLGenerator, checkAuth bool) *Monitor {
+	return &Monitor{
+		providers: providers,
+		checkAuth: checkAuth,
+		statuses:  make(map[string]Status, len(providers)),
+	}
+}
+
+// Refresh probes all providers concurrently and stores the results.
+func (m *Monitor) Refresh(ctx context.Context) {
+	var wg sync.WaitGroup
+	for name, p := range m.providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := Probe(ctx, p, m.checkAuth)
+
+			m.mu.Lock()
+			m.statuses[name] = status
+			m.mu.Unlock()
+		}()
+	}
+	wg.Wait()
+}
+
+// Run refreshes the statuses every interval until ctx is cancelled.
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Refresh(ctx)
+		}
+	}
+}
+
+// Status returns the last known status of a provider.
+func (m *Monitor) Status(name string) (Status, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status, ok := m.statuses[name]
+	return status, ok
+}
+
+// errNoAuthProbe is returned by AuthChecker implementations that turn out
+// not to have a probe configured; the provider's auth state stays unknown.
+var errNoAuthProbe = errors.New("no auth probe configured")
@@ -0,0 +1,145 @@
+//go:build !windows
+
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/prompt"
+)
+
+// writeFakeCLI creates an executable shell script that prints a version and
+// fails the "auth" subcommand unless loggedIn is set.
+func writeFakeCLI(t *testing.T, loggedIn bool) string {
+	t.Helper()
+
+	authExit := "1"
+	if loggedIn {
+		authExit = "0"
+	}
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = \"--version\" ]; then echo 'fakecli 1.2.3'; echo 'extra'; exit 0; fi\n" +
+		"if [ \"$1\" = \"auth\" ]; then exit " + authExit + "; fi\n"
+
+	path := filepath.Join(t.TempDir(), "fakecli")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProbe_InstalledBinary(t *testing.T) {
+	path := writeFakeCLI(t, true)
+	client, _ := NewCommandClient("DuckDB", prompt.Default(), CommandSpec{Name: "fake", Command: path, AuthArgs: []string{"auth"}})
+
+	status := Probe(context.Background(), client, true)
+
+	if !status.Installed {
+		t.Fatalf("expected installed, got %+v", status)
+	}
+	if status.Path != path {
+		t.Errorf("expected path %q, got %q", path, status.Path)
+	}
+	if status.Version != "fakecli 1.2.3" {
+		t.Errorf("expected version 'fakecli 1.2.3', got %q", status.Version)
+	}
+	if status.Authenticated == nil || !*status.Authenticated {
+		t.Errorf("expected authenticated, got %v", status.Authenticated)
+	}
+	if status.LastError != "" {
+		t.Errorf("unexpected error: %q", status.LastError)
+	}
+}
+
+func TestProbe_NotAuthenticated(t *testing.T) {
+	path := writeFakeCLI(t, false)
+	client, _ := NewCommandClient("DuckDB", prompt.Default(), CommandSpec{Name: "fake", Command: path, AuthArgs: []string{"auth"}})
+
+	status := Probe(context.Background(), client, true)
+
+	if status.Authenticated == nil || *status.Authenticated {
+		t.Errorf("expected not authenticated, got %v", status.Authenticated)
+	}
+	if !strings.Contains(status.LastError, "not authenticated") {
+		t.Errorf("expected auth error, got %q", status.LastError)
+	}
+}
+
+func TestProbe_AuthSkipped(t *testing.T) {
+	path := writeFakeCLI(t, false)
+
+	withProbe, _ := NewCommandClient("DuckDB", prompt.Default(), CommandSpec{Name: "fake", Command: path, AuthArgs: []string{"auth"}})
+	if status := Probe(context.Background(), withProbe, false); status.Authenticated != nil {
+		t.Errorf("expected no auth probe when disabled, got %v", *status.Authenticated)
+	}
+
+	withoutProbe, _ := NewCommandClient("DuckDB", prompt.Default(), CommandSpec{Name: "fake", Command: path})
+	if status := Probe(context.Background(), withoutProbe, true); status.Authenticated != nil {
+		t.Errorf("expected unknown auth state without auth_args, got %v", *status.Authenticated)
+	}
+}
+
+func TestProbe_MissingBinary(t *testing.T) {
+	client, _ := NewCommandClient("DuckDB", prompt.Default(), CommandSpec{Name: "missing", Command: "text-to-sql-proxy-does-not-exist"})
+
+	status := Probe(context.Background(), client, true)
+
+	if status.Installed {
+		t.Error("expected missing binary to be reported as not installed")
+	}
+	if status.LastError == "" {
+		t.Error("expected last_error to explain the lookup failure")
+	}
+}
+
+func TestProbe_HTTPProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	good := NewOpenAICompatibleClient("DuckDB", prompt.Default(), server.URL+"/v1", "good", "llama3", ResponseFormatText)
+	status := Probe(context.Background(), good, true)
+	if !status.Installed || status.Authenticated == nil || !*status.Authenticated {
+		t.Errorf("expected installed and authenticated, got %+v", status)
+	}
+
+	bad := NewOpenAICompatibleClient("DuckDB", prompt.Default(), server.URL+"/v1", "bad", "llama3", ResponseFormatText)
+	status = Probe(context.Background(), bad, true)
+	if status.Authenticated == nil || *status.Authenticated {
+		t.Errorf("expected not authenticated, got %+v", status)
+	}
+}
+
+func TestMonitor_Refresh(t *testing.T) {
+	installed, _ := NewCommandClient("DuckDB", prompt.Default(), CommandSpec{Name: "fake", Command: writeFakeCLI(t, true)})
+	missing, _ := NewCommandClient("DuckDB", prompt.Default(), CommandSpec{Name: "missing", Command: "text-to-sql-proxy-does-not-exist"})
+
+	monitor := NewMonitor(map[string]SQLGenerator{"fake": installed, "missing": missing}, false)
+
+	if _, ok := monitor.Status("fake"); ok {
+		t.Error("expected no status before the first refresh")
+	}
+
+	monitor.Refresh(context.Background())
+
+	if status, ok := monitor.Status("fake"); !ok || !status.Installed {
+		t.Errorf("expected fake to be installed, got %+v", status)
+	}
+	if status, ok := monitor.Status("missing"); !ok || status.Installed {
+		t.Errorf("expected missing to be not installed, got %+v", status)
+	}
+}
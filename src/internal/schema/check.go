@@ -0,0 +1,656 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Correction is a query that failed Check and the problems found in it.
+type Correction struct {
+	SQL    string   `json:"sql"`
+	Errors []string `json:"errors"`
+}
+
+// viewPattern matches a CREATE VIEW statement.
+var viewPattern = regexp.MustCompile(`(?is)^` + leadingComments + `CREATE\s+(?:OR\s+REPLACE\s+)?(?:TEMP(?:ORARY)?\s+)?(?:MATERIALIZED\s+)?VIEW\s+(?:IF\s+NOT\s+EXISTS\s+)?` + namePattern)
+
+// maxListedColumns is the number of columns up to which a problem lists
+// the columns a table does have.
+const maxListedColumns = 20
+
+// keywords are the words of SQL queries that are never column references:
+// clauses, operators, literals, types and date parts.
+var keywords = wordSet(`
+	select from where group by order having limit offset as on join inner left right full outer cross natural
+	using and or not in is null like ilike rlike regexp glob similar escape between case when then else end
+	distinct all union intersect except minus with recursive asc desc nulls first last true false unknown
+	exists any some interval date time timestamp timestamptz datetime zone at local over partition rows range
+	groups unbounded preceding following current row filter within qualify window lateral only fetch next top
+	percent ties cast try_cast to collate array values default div mod xor semi anti asof positional pivot
+	unpivot for separator exclude replace sample tablesample grouping sets rollup cube ignore respect both
+	leading trailing year years month months week weeks day days hour hours minute minutes second seconds
+	quarter dow doy isodow isoyear epoch millisecond milliseconds microsecond microseconds decade century
+	millennium int integer int2 int4 int8 bigint smallint tinyint hugeint varchar char character text string
+	decimal numeric float float4 float8 double precision real boolean bool json jsonb uuid blob bytea
+	signed unsigned varying without current_date current_time current_timestamp localtime localtimestamp
+	current_user session_user user rowid rownum
+`)
+
+// aggregates are the aggregate functions that require a GROUP BY for the
+// other selected columns.
+var aggregates = wordSet(`
+	count sum avg min max string_agg array_agg group_concat listagg median stddev stddev_samp stddev_pop
+	variance var_samp var_pop bool_and bool_or every any_value arg_max arg_min max_by min_by
+	approx_count_distinct percentile_cont percentile_disc mode list count_if countif corr covar_pop
+	covar_samp json_agg jsonb_agg json_group_array json_object_agg product bit_and bit_or
+`)
+
+// clauseKeywords end the select list of a query.
+var clauseKeywords = wordSet(`from where group having order limit offset qualify window union intersect except fetch`)
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// Token kinds of the query lexer.
+const (
+	tokenWord   = iota // unquoted identifier or keyword
+	tokenQuoted        // quoted identifier
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+// token is a token of a query. depth is the number of parentheses it is
+// nested in; parentheses themselves have the depth outside of them.
+type token struct {
+	kind  int
+	text  string
+	depth int
+}
+
+// is reports whether the token is the given keyword or symbol, ignoring
+// case.
+func (t token) is(text string) bool {
+	return (t.kind == tokenWord || t.kind == tokenSymbol) && strings.EqualFold(t.text, text)
+}
+
+// identifier reports whether the token names something.
+func (t token) identifier() bool {
+	return t.kind == tokenQuoted || (t.kind == tokenWord && !keywords[strings.ToLower(t.text)])
+}
+
+// name returns the lower-cased identifier.
+func (t token) name() string {
+	return strings.ToLower(t.text)
+}
+
+// lex splits a query into tokens, dropping comments. It returns a problem
+// for unbalanced parentheses and unterminated literals.
+func lex(sql string) ([]token, []string) {
+	var tokens []token
+	var problems []string
+	depth := 0
+	runes := []rune(sql)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			end := strings.Index(string(runes[i+2:]), "*/")
+			if end < 0 {
+				return tokens, append(problems, "unterminated /* comment")
+			}
+			i += 2 + len([]rune(string(runes[i+2:])[:end])) + 2
+		case c == '\'' || c == '"' || c == '`':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(runes); j++ {
+				if runes[j] == c {
+					if j+1 < len(runes) && runes[j+1] == c {
+						b.WriteRune(c)
+						j++
+						continue
+					}
+					break
+				}
+				b.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return tokens, append(problems, fmt.Sprintf("unterminated %c quote", c))
+			}
+			kind := tokenQuoted
+			if c == '\'' {
+				kind = tokenString
+			}
+			tokens = append(tokens, token{kind: kind, text: b.String(), depth: depth})
+			i = j + 1
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || runes[j] == '_' || unicode.IsLetter(runes[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[i:j]), depth: depth})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '$') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: string(runes[i:j]), depth: depth})
+			i = j
+		default:
+			text := string(c)
+			for _, op := range []string{"::", "->>", "->", "=>", ":="} {
+				if strings.HasPrefix(string(runes[i:min(i+3, len(runes))]), op) {
+					text = op
+					break
+				}
+			}
+			switch text {
+			case "(":
+				tokens = append(tokens, token{kind: tokenSymbol, text: text, depth: depth})
+				depth++
+			case ")":
+				if depth == 0 {
+					problems = append(problems, "unbalanced parentheses: a ) has no matching (")
+					depth++
+				}
+				depth--
+				tokens = append(tokens, token{kind: tokenSymbol, text: text, depth: depth})
+			default:
+				tokens = append(tokens, token{kind: tokenSymbol, text: text, depth: depth})
+			}
+			i += len([]rune(text))
+		}
+	}
+	if depth > 0 {
+		problems = append(problems, fmt.Sprintf("unbalanced parentheses: %d ( not closed", depth))
+	}
+	return tokens, problems
+}
+
+// source is a table, view, CTE or subquery a query reads from, named by its
+// alias or unqualified name. table is nil for sources with unknown columns.
+type source struct {
+	name  string
+	table *Table
+}
+
+// scope is a SELECT and the sources of its FROM clause. Column references
+// resolve in the innermost scope that has the column, then outwards.
+type scope struct {
+	parent  *scope
+	depth   int
+	sources []source
+	// aliases are the names the query defines for its columns and using
+	// the columns joined with USING, which are not ambiguous. opaque is set
+	// when a source's columns are unknown, natural when it has a NATURAL
+	// JOIN and grouped when it has a GROUP BY.
+	aliases map[string]bool
+	using   map[string]bool
+	opaque  bool
+	natural bool
+	grouped bool
+}
+
+// checker holds the state of a Check.
+type checker struct {
+	tables   map[string]*Table
+	views    map[string]bool
+	tokens   []token
+	scopes   []*scope
+	consumed []bool
+	// ctes and lambdas are names defined by WITH and by lambda functions
+	// such as x -> x + 1.
+	ctes     map[string]bool
+	lambdas  map[string]bool
+	problems []string
+	seen     map[string]bool
+}
+
+// Check validates a query against the schema and returns the problems it
+// finds: unbalanced parentheses, unknown tables, unknown or ambiguous
+// columns and columns selected next to aggregates without a GROUP BY. It is
+// a heuristic, not a parser: references it cannot resolve, e.g. to columns
+// of subqueries or table functions, are accepted. Without tables in the
+// schema only the parentheses are checked.
+func (s *Schema) Check(sql string) []string {
+	tokens, problems := lex(sql)
+	if len(problems) > 0 || len(s.Tables) == 0 {
+		return problems
+	}
+
+	c := &checker{
+		tables:   make(map[string]*Table),
+		views:    make(map[string]bool),
+		tokens:   tokens,
+		scopes:   make([]*scope, len(tokens)),
+		consumed: make([]bool, len(tokens)),
+		ctes:     make(map[string]bool),
+		lambdas:  make(map[string]bool),
+		seen:     make(map[string]bool),
+	}
+	for _, t := range s.Tables {
+		c.tables[Unqualified(t.Name)] = t
+	}
+	for i, stmt := range s.statements {
+		if m := viewPattern.FindStringSubmatch(stmt); m != nil && s.owners[i] == nil {
+			c.views[Unqualified(m[1])] = true
+		}
+	}
+
+	c.assignScopes()
+	c.collectNames()
+	c.readSources()
+	c.checkColumns()
+	return c.problems
+}
+
+// report adds a problem once.
+func (c *checker) report(format string, args ...any) {
+	problem := fmt.Sprintf(format, args...)
+	if !c.seen[problem] {
+		c.seen[problem] = true
+		c.problems = append(c.problems, problem)
+	}
+}
+
+// at returns the token at i, or an empty token beyond the query's ends.
+func (c *checker) at(i int) token {
+	if i < 0 || i >= len(c.tokens) {
+		return token{kind: -1}
+	}
+	return c.tokens[i]
+}
+
+// closing returns the index of the parenthesis closing the one at i.
+func (c *checker) closing(i int) int {
+	for j := i + 1; j < len(c.tokens); j++ {
+		if c.tokens[j].is(")") && c.tokens[j].depth == c.tokens[i].depth {
+			return j
+		}
+	}
+	return len(c.tokens) - 1
+}
+
+// assignScopes assigns every token to the innermost SELECT it belongs to.
+// A SELECT ends at the parenthesis closing it or at the next SELECT on its
+// level, as in UNION.
+func (c *checker) assignScopes() {
+	var open []*scope
+	for i, t := range c.tokens {
+		if t.is(")") {
+			for len(open) > 0 && open[len(open)-1].depth > t.depth {
+				open = open[:len(open)-1]
+			}
+		}
+		if t.is("select") {
+			for len(open) > 0 && open[len(open)-1].depth >= t.depth {
+				open = open[:len(open)-1]
+			}
+			s := &scope{depth: t.depth, aliases: make(map[string]bool), using: make(map[string]bool)}
+			if len(open) > 0 {
+				s.parent = open[len(open)-1]
+			}
+			open = append(open, s)
+		}
+		if len(open) > 0 {
+			c.scopes[i] = open[len(open)-1]
+		}
+	}
+}
+
+// collectNames records the CTEs, lambda parameters and column aliases the
+// query defines, and which scopes group.
+func (c *checker) collectNames() {
+	for i, t := range c.tokens {
+		prev, next := c.at(i-1), c.at(i+1)
+		switch {
+		case t.is("group") && next.is("by") && c.scopes[i] != nil && t.depth == c.scopes[i].depth:
+			c.scopes[i].grouped = true
+		case !t.identifier():
+		case (prev.is("with") || prev.is("recursive") || prev.is(",")) && (next.is("as") || next.is("(")):
+			end := i
+			if next.is("(") {
+				end = c.closing(i + 1)
+			}
+			if c.at(end+1).is("as") && (c.at(end+2).is("(") || c.at(end+2).is("materialized") || c.at(end+2).is("not")) {
+				c.ctes[t.name()] = true
+				for j := i; j <= end; j++ {
+					c.consumed[j] = true
+				}
+			}
+		case next.is("->"):
+			c.lambdas[t.name()] = true
+			c.consumed[i] = true
+		case prev.is("as") || c.aliased(i):
+			if s := c.scopes[i]; s != nil {
+				s.aliases[t.name()] = true
+			}
+		}
+	}
+}
+
+// aliased reports whether the identifier at i directly follows an
+// expression, as the alias in SELECT total amount.
+func (c *checker) aliased(i int) bool {
+	prev := c.at(i - 1)
+	switch {
+	case c.at(i+1).is("(") || c.at(i+1).is("."):
+		return false
+	case prev.kind == tokenNumber || prev.kind == tokenString || prev.is(")"):
+		return true
+	case prev.is("end") || prev.is("null") || prev.is("true") || prev.is("false"):
+		return true
+	}
+	return prev.identifier()
+}
+
+// readSources records the tables, views, CTEs and subqueries each FROM and
+// JOIN reads and reports unknown tables.
+func (c *checker) readSources() {
+	for i, t := range c.tokens {
+		s := c.scopes[i]
+		if s == nil || t.depth != s.depth {
+			continue
+		}
+		if t.is("natural") {
+			s.natural = true
+		}
+		if t.is("using") && c.at(i+1).is("(") {
+			for j := i + 1; j <= c.closing(i+1); j++ {
+				c.consumed[j] = true
+				if c.tokens[j].identifier() {
+					s.using[c.tokens[j].name()] = true
+				}
+			}
+			continue
+		}
+		if !(t.is("join") || t.is("from") && !c.at(i-1).is("distinct")) {
+			continue
+		}
+		for j := i + 1; j < len(c.tokens); {
+			j = c.readSource(s, j)
+			if !c.at(j).is(",") || !t.is("from") {
+				break
+			}
+			j++
+		}
+	}
+}
+
+// readSource reads the source starting at i into s and returns the index
+// after it.
+func (c *checker) readSource(s *scope, i int) int {
+	for c.at(i).is("lateral") || c.at(i).is("only") {
+		i++
+	}
+
+	src := source{}
+	switch t := c.at(i); {
+	case t.is("("):
+		i = c.closing(i) + 1
+	case t.kind == tokenString:
+		// A file such as 'orders.csv'
+		i++
+	case t.kind == tokenWord || t.kind == tokenQuoted:
+		start := i
+		name := t.text
+		for c.at(i+1).is(".") && (c.at(i+2).kind == tokenWord || c.at(i+2).kind == tokenQuoted) {
+			i += 2
+			name += "." + c.at(i).text
+		}
+		for j := start; j <= i; j++ {
+			c.consumed[j] = true
+		}
+		i++
+		src.name = Unqualified(name)
+		if c.at(i).is("(") {
+			// A table function such as generate_series(1, 10)
+			i = c.closing(i) + 1
+			break
+		}
+		switch table := c.tables[src.name]; {
+		case c.ctes[src.name] || c.views[src.name]:
+		case table != nil:
+			if len(table.Columns) > 0 {
+				src.table = table
+			}
+		default:
+			c.report("table %s does not exist", name)
+		}
+	default:
+		return i
+	}
+
+	if c.at(i).is("as") {
+		i++
+	}
+	if t := c.at(i); t.identifier() {
+		src.name = t.name()
+		c.consumed[i] = true
+		i++
+		if c.at(i).is("(") {
+			// Renamed columns
+			end := c.closing(i)
+			for j := i; j <= end; j++ {
+				c.consumed[j] = true
+			}
+			src.table = nil
+			i = end + 1
+		}
+	}
+
+	if src.table == nil {
+		s.opaque = true
+	}
+	s.sources = append(s.sources, src)
+	return i
+}
+
+// checkColumns resolves the column references of every scope and checks
+// the select lists of aggregating queries.
+func (c *checker) checkColumns() {
+	// bare lists the columns each scope selects outside of aggregates, and
+	// aggregating the scopes that select an aggregate
+	bare := make(map[*scope][]string)
+	aggregating := make(map[*scope]bool)
+	// exempt marks the tokens inside aggregate and window function calls,
+	// and selecting the tokens of select lists
+	exempt := make([]bool, len(c.tokens))
+	selecting := make([]bool, len(c.tokens))
+	for i, t := range c.tokens {
+		s := c.scopes[i]
+		if s == nil || !t.is("select") {
+			continue
+		}
+		for j := i + 1; j < len(c.tokens) && c.scopes[j] != nil; j++ {
+			u := c.tokens[j]
+			if c.scopes[j] == s && u.depth == s.depth && u.kind == tokenWord && clauseKeywords[u.name()] || u.depth < s.depth {
+				break
+			}
+			selecting[j] = c.scopes[j] == s
+			if c.scopes[j] != s || u.kind != tokenWord || !c.at(j+1).is("(") {
+				continue
+			}
+			if u.is("over") || u.is("filter") || aggregates[u.name()] {
+				end := c.closing(j + 1)
+				for k := j; k <= end; k++ {
+					exempt[k] = true
+				}
+				if aggregates[u.name()] && !c.at(end+1).is("over") && !(c.at(end+1).is("filter") && c.at(c.closing(end+2)+1).is("over")) {
+					aggregating[s] = true
+				}
+			}
+		}
+	}
+
+	for i, t := range c.tokens {
+		s := c.scopes[i]
+		if s == nil || c.consumed[i] || !t.identifier() {
+			continue
+		}
+		prev, next := c.at(i-1), c.at(i+1)
+		if next.is("(") || next.kind == tokenString || prev.is("::") || prev.is(":") || prev.is("@") ||
+			prev.is("as") || prev.is("over") || prev.is("window") || prev.is(".") || next.is("=>") || next.is(":=") || c.lambdas[t.name()] {
+			continue
+		}
+
+		var found *scope
+		if next.is(".") {
+			found = c.qualified(s, i)
+		} else if !c.aliased(i) {
+			found = c.unqualified(s, t)
+		} else {
+			continue
+		}
+		if found == s && selecting[i] && !exempt[i] {
+			bare[s] = append(bare[s], c.reference(i))
+		}
+	}
+
+	for _, s := range c.orderedScopes() {
+		if aggregating[s] && !s.grouped && len(bare[s]) > 0 {
+			c.report("%s is selected next to an aggregate but there is no GROUP BY", strings.Join(bare[s], ", "))
+		}
+	}
+}
+
+// reference returns the column reference at i as written, e.g. o.total.
+func (c *checker) reference(i int) string {
+	name := c.tokens[i].text
+	for c.at(i+1).is(".") && c.at(i+2).kind != -1 {
+		i += 2
+		name += "." + c.tokens[i].text
+	}
+	return name
+}
+
+// orderedScopes returns the scopes in query order.
+func (c *checker) orderedScopes() []*scope {
+	var scopes []*scope
+	seen := make(map[*scope]bool)
+	for _, s := range c.scopes {
+		if s != nil && !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// unqualified resolves a column without qualifier from s outwards and
+// returns the scope it belongs to, or nil if it is an alias or cannot be
+// told.
+func (c *checker) unqualified(s *scope, t token) *scope {
+	name := t.name()
+	if c.ctes[name] {
+		return nil
+	}
+	for sc := s; sc != nil; sc = sc.parent {
+		if sc.aliases[name] {
+			return nil
+		}
+		var matches []string
+		for _, src := range sc.sources {
+			if src.table != nil && src.table.column(name) != nil {
+				matches = append(matches, src.name)
+			}
+		}
+		if sc.opaque {
+			return nil
+		}
+		if len(matches) > 1 && !sc.using[name] && !sc.natural {
+			c.report("column %s is ambiguous: it exists in %s", t.text, strings.Join(matches, " and "))
+		}
+		if len(matches) > 0 {
+			return sc
+		}
+	}
+
+	var tables []*Table
+	var names []string
+	for sc := s; sc != nil; sc = sc.parent {
+		for _, src := range sc.sources {
+			tables = append(tables, src.table)
+			names = append(names, src.table.Name)
+		}
+	}
+	switch len(tables) {
+	case 0:
+	case 1:
+		c.report("column %s does not exist in %s%s", t.text, tables[0].Name, columnList(tables[0]))
+	default:
+		c.report("column %s does not exist in %s", t.text, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// qualified resolves the column reference starting at i, such as o.total
+// or sales.orders.total, and returns the scope its table belongs to, or
+// nil if it cannot be told.
+func (c *checker) qualified(s *scope, i int) *scope {
+	end := i
+	for c.at(end+1).is(".") && c.at(end+2).kind != -1 {
+		end += 2
+		c.consumed[end] = true
+	}
+	column := c.tokens[end]
+	qualifier := c.tokens[end-2]
+	name := Unqualified(qualifier.text)
+
+	for sc := s; sc != nil; sc = sc.parent {
+		for _, src := range sc.sources {
+			if src.name != name {
+				continue
+			}
+			if src.table != nil && !column.is("*") && src.table.column(column.text) == nil {
+				c.report("column %s does not exist in %s%s", c.reference(i), src.table.Name, columnList(src.table))
+			}
+			return sc
+		}
+	}
+
+	// A struct field, e.g. address.city, or a table the query does not read
+	for sc := s; sc != nil; sc = sc.parent {
+		if sc.opaque || sc.aliases[name] {
+			return nil
+		}
+		for _, src := range sc.sources {
+			if src.table.column(qualifier.text) != nil {
+				return sc
+			}
+		}
+	}
+	if c.tables[name] != nil {
+		c.report("table %s is referenced in %s but not in FROM or JOIN", qualifier.text, c.reference(i))
+	} else {
+		c.report("%s in %s is not a table or alias of the query", qualifier.text, c.reference(i))
+	}
+	return nil
+}
+
+// columnList returns the table's columns as a hint for a problem, or ""
+// for wide tables.
+func columnList(t *Table) string {
+	if len(t.Columns) > maxListedColumns {
+		return ""
+	}
+	names := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		names[i] = column.Name
+	}
+	return " (columns: " + strings.Join(names, ", ") + ")"
+}
@@ -0,0 +1,77 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+const checkDDL = `CREATE TABLE customers (id INT PRIMARY KEY, name TEXT, country TEXT);
+CREATE TABLE orders (id INT, customer_id INT REFERENCES customers(id), total DECIMAL(10, 2), created_at TIMESTAMP, status TEXT);
+CREATE VIEW big_orders AS SELECT * FROM orders WHERE total > 100;`
+
+func TestCheck_Valid(t *testing.T) {
+	s := Parse(checkDDL)
+
+	for _, sql := range []string{
+		"SELECT * FROM orders",
+		"SELECT id, total FROM orders WHERE status = 'paid' ORDER BY created_at DESC LIMIT 10",
+		"SELECT o.id, c.name FROM orders o JOIN customers AS c ON c.id = o.customer_id",
+		"SELECT c.country, SUM(o.total) AS revenue FROM customers c LEFT JOIN orders o ON o.customer_id = c.id GROUP BY c.country ORDER BY revenue DESC",
+		"SELECT COUNT(*) FROM orders WHERE created_at >= CURRENT_DATE - INTERVAL '7 days'",
+		"SELECT date_trunc('month', created_at) AS month, count(*) n FROM orders GROUP BY 1 ORDER BY month",
+		"SELECT EXTRACT(YEAR FROM created_at) AS year, CAST(total AS INTEGER) FROM orders",
+		"SELECT total::numeric(10, 2) FROM orders WHERE status IS DISTINCT FROM 'void'",
+		"WITH recent AS (SELECT * FROM orders WHERE created_at > DATE '2026-01-01') SELECT r.id, r.anything FROM recent r",
+		"SELECT name FROM customers c WHERE EXISTS (SELECT 1 FROM orders o WHERE o.customer_id = c.id AND total > 10)",
+		"SELECT sub.n FROM (SELECT count(*) AS n FROM orders) sub",
+		"SELECT id, total, SUM(total) OVER (PARTITION BY customer_id ORDER BY created_at) FROM orders",
+		"SELECT id FROM orders UNION ALL SELECT id FROM customers",
+		"SELECT customer_id, id FROM orders JOIN customers USING (id)",
+		"SELECT x FROM generate_series(1, 10) AS g(x)",
+		"SELECT * FROM big_orders WHERE whatever > 1",
+		"SELECT list_transform([1, 2], x -> x + 1)",
+		"SELECT \"total\" FROM \"orders\" -- a comment with an ( in it",
+		"SELECT o.* FROM public.orders o",
+	} {
+		if problems := s.Check(sql); len(problems) > 0 {
+			t.Errorf("expected no problems for %s, got %q", sql, problems)
+		}
+	}
+}
+
+func TestCheck_Problems(t *testing.T) {
+	s := Parse(checkDDL)
+
+	for _, test := range []struct {
+		sql     string
+		problem string
+	}{
+		{"SELECT id FROM order_items", "table order_items does not exist"},
+		{"SELECT amount FROM orders", "column amount does not exist in orders (columns: id, customer_id, total, created_at, status)"},
+		{"SELECT o.amount FROM orders o", "column o.amount does not exist in orders (columns: id, customer_id, total, created_at, status)"},
+		{"SELECT email FROM orders o JOIN customers c ON c.id = o.customer_id", "column email does not exist in orders, customers"},
+		{"SELECT id FROM orders o JOIN customers c ON c.id = o.customer_id", "column id is ambiguous: it exists in o and c"},
+		{"SELECT x.id FROM orders o", "x in x.id is not a table or alias of the query"},
+		{"SELECT customers.name FROM orders", "table customers is referenced in customers.name but not in FROM or JOIN"},
+		{"SELECT status, count(*) FROM orders", "status is selected next to an aggregate but there is no GROUP BY"},
+		{"SELECT count(*) FROM orders WHERE (total > 1", "unbalanced parentheses: 1 ( not closed"},
+		{"SELECT count(*) FROM orders WHERE total > 1)", "unbalanced parentheses: a ) has no matching ("},
+		{"SELECT name FROM customers WHERE id IN (SELECT customer_id FROM orders WHERE amount > 1)", "column amount does not exist in orders, customers"},
+	} {
+		problems := s.Check(test.sql)
+		if len(problems) != 1 || problems[0] != test.problem {
+			t.Errorf("expected %q for %s, got %q", test.problem, test.sql, problems)
+		}
+	}
+}
+
+func TestCheck_WithoutTables(t *testing.T) {
+	s := Parse("")
+
+	if problems := s.Check("SELECT anything FROM anywhere"); len(problems) > 0 {
+		t.Errorf("expected no problems without tables, got %q", problems)
+	}
+	if problems := s.Check("SELECT (1"); len(problems) != 1 || !strings.HasPrefix(problems[0], "unbalanced parentheses") {
+		t.Errorf("expected unbalanced parentheses, got %q", problems)
+	}
+}
@@ -0,0 +1,225 @@
+package schema
+
+import (
+	"slices"
+	"strings"
+)
+
+// Join is a join condition between two tables, From.FromColumn =
+// To.ToColumn. Tables are named as written in the DDL.
+type Join struct {
+	From       string `json:"from"`
+	FromColumn string `json:"from_column"`
+	To         string `json:"to"`
+	ToColumn   string `json:"to_column"`
+	// Inferred is set for joins guessed from column names and types rather
+	// than declared as foreign keys.
+	Inferred bool `json:"inferred,omitempty"`
+}
+
+// Joins returns the declared foreign keys between the schema's tables and
+// the joins inferred from column names: a column such as user_id or userId
+// joins the table named user, users or ending in _user(s) on its id,
+// userId or user_id column, if their types are compatible.
+func (s *Schema) Joins() []Join {
+	byName := make(map[string]*Table)
+	for _, t := range s.Tables {
+		byName[Unqualified(t.Name)] = t
+	}
+
+	var joins []Join
+	for _, t := range s.Tables {
+		declared := make(map[string]bool)
+		for _, key := range t.foreignKeys {
+			target := byName[key.To]
+			if target == nil {
+				continue
+			}
+			toColumn := key.ToColumn
+			if toColumn == "" {
+				toColumn = "id"
+			}
+			declared[strings.ToLower(key.FromColumn)] = true
+			joins = append(joins, Join{From: t.Name, FromColumn: key.FromColumn, To: target.Name, ToColumn: toColumn})
+		}
+
+		for _, c := range t.Columns {
+			if declared[strings.ToLower(c.Name)] {
+				continue
+			}
+			if join, ok := s.inferJoin(t, c); ok {
+				joins = append(joins, join)
+			}
+		}
+	}
+	return joins
+}
+
+// inferJoin guesses the table a column of t refers to from its name.
+func (s *Schema) inferJoin(t *Table, c Column) (Join, bool) {
+	prefix := keyPrefix(c.Name)
+	if prefix == "" {
+		return Join{}, false
+	}
+
+	var best *Table
+	var bestColumn *Column
+	bestRank := 0
+	for _, target := range s.Tables {
+		if target == t {
+			continue
+		}
+		rank := nameMatch(Unqualified(target.Name), prefix)
+		if rank == 0 || (best != nil && rank >= bestRank) {
+			continue
+		}
+		var column *Column
+		for _, name := range []string{"id", c.Name, prefix + "_id"} {
+			if column = target.column(name); column != nil {
+				break
+			}
+		}
+		if column == nil || !compatibleTypes(c.Type, column.Type) {
+			continue
+		}
+		best, bestColumn, bestRank = target, column, rank
+	}
+	if best == nil {
+		return Join{}, false
+	}
+	return Join{From: t.Name, FromColumn: c.Name, To: best.Name, ToColumn: bestColumn.Name, Inferred: true}, true
+}
+
+// keyPrefix returns the lower-cased entity a key column names, e.g. user
+// for user_id or userId, or "" for other columns.
+func keyPrefix(column string) string {
+	switch {
+	case len(column) > 3 && strings.HasSuffix(strings.ToLower(column), "_id"):
+		return strings.ToLower(column[:len(column)-3])
+	case len(column) > 2 && strings.HasSuffix(column, "Id"):
+		return strings.ToLower(column[:len(column)-2])
+	}
+	return ""
+}
+
+// nameMatch ranks how well a table name matches an entity: 1 for the
+// entity itself, 2 for its plural and 3 for a prefixed name such as
+// dim_user; 0 if it does not match.
+func nameMatch(table, entity string) int {
+	plurals := []string{entity + "s", entity + "es"}
+	if strings.HasSuffix(entity, "y") {
+		plurals = append(plurals, entity[:len(entity)-1]+"ies")
+	}
+
+	switch {
+	case table == entity:
+		return 1
+	case slices.Contains(plurals, table):
+		return 2
+	case strings.HasSuffix(table, "_"+entity):
+		return 3
+	}
+	for _, plural := range plurals {
+		if strings.HasSuffix(table, "_"+plural) {
+			return 3
+		}
+	}
+	return 0
+}
+
+// typeFamilies groups column types that can be compared in a join.
+var typeFamilies = map[string]string{
+	"int": "number", "integer": "number", "bigint": "number", "smallint": "number",
+	"tinyint": "number", "mediumint": "number", "int2": "number", "int4": "number",
+	"int8": "number", "int64": "number", "hugeint": "number", "ubigint": "number",
+	"uinteger": "number", "serial": "number", "bigserial": "number", "number": "number",
+	"numeric": "number", "decimal": "number",
+	"text": "string", "varchar": "string", "char": "string", "character": "string",
+	"string": "string", "nvarchar": "string", "nchar": "string",
+	"uuid": "uuid",
+}
+
+// compatibleTypes reports whether two column types can be joined. Unknown
+// or missing types are compatible with everything.
+func compatibleTypes(a, b string) bool {
+	fa, okA := typeFamilies[a]
+	fb, okB := typeFamilies[b]
+	return !okA || !okB || fa == fb
+}
+
+// JoinPaths returns the joins on the shortest join paths between the tables
+// the question mentions, in path order. A table is mentioned when the
+// question contains the last word of its name, e.g. "items" for
+// order_items.
+func (s *Schema) JoinPaths(question string) []Join {
+	words := make(map[string]bool)
+	for _, term := range terms(question) {
+		words[term] = true
+	}
+	var mentioned []string
+	for _, t := range s.Tables {
+		if nameTerms := terms(Unqualified(t.Name)); len(nameTerms) > 0 && words[nameTerms[len(nameTerms)-1]] {
+			mentioned = append(mentioned, t.Name)
+		}
+	}
+	if len(mentioned) < 2 {
+		return nil
+	}
+
+	joins := s.Joins()
+	edges := make(map[string][]int)
+	for i, j := range joins {
+		edges[j.From] = append(edges[j.From], i)
+		edges[j.To] = append(edges[j.To], i)
+	}
+
+	var paths []Join
+	seen := make(map[int]bool)
+	for i, from := range mentioned {
+		for _, to := range mentioned[i+1:] {
+			for _, edge := range shortestPath(joins, edges, from, to) {
+				if !seen[edge] {
+					seen[edge] = true
+					paths = append(paths, joins[edge])
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// shortestPath returns the indexes of the joins on a shortest path between
+// two tables, or nil if they are not connected.
+func shortestPath(joins []Join, edges map[string][]int, from, to string) []int {
+	via := map[string]int{from: -1}
+	queue := []string{from}
+	for len(queue) > 0 && via[to] == 0 && to != from {
+		table := queue[0]
+		queue = queue[1:]
+		for _, edge := range edges[table] {
+			next := joins[edge].To
+			if next == table {
+				next = joins[edge].From
+			}
+			if _, ok := via[next]; !ok {
+				via[next] = edge + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	if _, ok := via[to]; !ok {
+		return nil
+	}
+
+	var path []int
+	for table := to; via[table] > 0; {
+		edge := via[table] - 1
+		path = append([]int{edge}, path...)
+		if joins[edge].To == table {
+			table = joins[edge].From
+		} else {
+			table = joins[edge].To
+		}
+	}
+	return path
+}
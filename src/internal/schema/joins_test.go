@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func joinStrings(joins []Join) string {
+	var parts []string
+	for _, j := range joins {
+		part := fmt.Sprintf("%s.%s=%s.%s", j.From, j.FromColumn, j.To, j.ToColumn)
+		if j.Inferred {
+			part += "?"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+const shopDDL = `CREATE TABLE users (id BIGINT, name TEXT);
+CREATE TABLE categories (category_id INT, name TEXT);
+CREATE TABLE products (id INT, categoryId INT, name TEXT);
+CREATE TABLE orders (id INT, user_id BIGINT, status TEXT);
+CREATE TABLE order_items (
+  order_id INT,
+  product_id INT,
+  sku_id TEXT,
+  quantity INT,
+  FOREIGN KEY (order_id) REFERENCES orders (id)
+);
+CREATE TABLE dim_sku (id INT);`
+
+func TestJoins(t *testing.T) {
+	got := joinStrings(Parse(shopDDL).Joins())
+	expected := "products.categoryId=categories.category_id? " +
+		"orders.user_id=users.id? " +
+		"order_items.order_id=orders.id " +
+		"order_items.product_id=products.id?"
+	if got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestJoinPaths(t *testing.T) {
+	s := Parse(shopDDL)
+
+	tests := []struct {
+		question string
+		expected string
+	}{
+		{
+			question: "Which users bought products of the garden category?",
+			expected: "orders.user_id=users.id? order_items.order_id=orders.id order_items.product_id=products.id? products.categoryId=categories.category_id?",
+		},
+		{
+			question: "Orders per user",
+			expected: "orders.user_id=users.id?",
+		},
+		{
+			question: "Number of users",
+			expected: "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.question, func(t *testing.T) {
+			if got := joinStrings(s.JoinPaths(tc.question)); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNameMatch(t *testing.T) {
+	tests := []struct {
+		table    string
+		entity   string
+		expected int
+	}{
+		{"user", "user", 1},
+		{"users", "user", 2},
+		{"categories", "category", 2},
+		{"dim_customer", "customer", 3},
+		{"stg_addresses", "address", 3},
+		{"superusers", "user", 0},
+	}
+
+	for _, tc := range tests {
+		if got := nameMatch(tc.table, tc.entity); got != tc.expected {
+			t.Errorf("nameMatch(%q, %q) = %d, expected %d", tc.table, tc.entity, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package schema
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Normalized is the outcome of normalizing a DDL.
+type Normalized struct {
+	DDL string
+	// Removed is the number of statements dropped or folded into their
+	// table, and Saved the estimated tokens the DDL got shorter by.
+	Removed int
+	Saved   int
+}
+
+var (
+	// noisePattern matches the statements of schema dumps that do not
+	// describe the schema: session settings, ownership and privileges,
+	// sequences, plain indexes, triggers, data and transaction control.
+	noisePattern = regexp.MustCompile(`(?is)^(?:` + strings.Join([]string{
+		`SET\s`, `RESET\s`, `SELECT\s+pg_catalog\.`, `GRANT\s`, `REVOKE\s`,
+		`ALTER\s.*\sOWNER\s+TO\s`, `ALTER\s+DEFAULT\s+PRIVILEGES\s`,
+		`(?:CREATE|ALTER)\s+SEQUENCE\s`, `CREATE\s+INDEX\s`,
+		`CREATE\s+(?:OR\s+REPLACE\s+)?(?:CONSTRAINT\s+|EVENT\s+)?TRIGGER\s`,
+		`CREATE\s+(?:OR\s+REPLACE\s+)?FUNCTION\s.*\sRETURNS\s+(?:event_)?trigger\b`,
+		`CREATE\s+(?:EXTENSION|SCHEMA)\s`, `COMMENT\s+ON\s+(?:EXTENSION|SCHEMA|DATABASE)\s`,
+		`DROP\s`, `LOCK\s+TABLES?\s`, `UNLOCK\s+TABLES\b`, `USE\s`, `INSERT\s`, `COPY\s`,
+		`BEGIN\b`, `COMMIT\b`, `START\s+TRANSACTION\b`, `PRAGMA\s`, `INSTALL\s`, `LOAD\s`,
+		`ALTER\s+TABLE\s.*\sALTER\s+COLUMN\s.*\s(?:SET\s+DEFAULT\s+nextval|ADD\s+GENERATED\s)`,
+		`ALTER\s+TABLE\s.*\s(?:(?:ENABLE|DISABLE)\s+KEYS|REPLICA\s+IDENTITY|CLUSTER\s+ON)\b`,
+	}, "|") + `)`)
+
+	// dumpCommentPattern matches the header comments pg_dump and mysqldump
+	// write around statements.
+	dumpCommentPattern = regexp.MustCompile(`(?i)^--\s*(?:$|-+$|Name:|Data for Name:|TOC entry|PostgreSQL database dump|Dumped (?:from|by)|MySQL dump|Host:|Server version|Current Database:|Table structure for|Temporary (?:view|table) structure|Final view structure|Dumping (?:data|routines|events)|Dump completed)`)
+
+	// metaCommandPattern matches psql meta-commands such as \connect.
+	metaCommandPattern = regexp.MustCompile(`(?m)^\\[a-z]+\b.*$`)
+	commentPattern     = regexp.MustCompile(`(?s)--[^\n]*|/\*.*?\*/`)
+	leadingPattern     = regexp.MustCompile(`(?s)^` + leadingComments)
+
+	commentOnPattern      = regexp.MustCompile(`(?is)^COMMENT\s+ON\s+(TABLE|COLUMN)\s+` + namePattern + `\s+IS\s+(NULL|'(?:[^']|'')*')$`)
+	addConstraintPattern  = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?` + namePattern + `\s+ADD\s+(CONSTRAINT\s.*)$`)
+	constraintNamePattern = regexp.MustCompile("(?is)^CONSTRAINT\\s+(?:\"[^\"]*\"|`[^`]*`|\\w+)\\s+")
+
+	indexPattern         = regexp.MustCompile(`(?i)^(?:KEY|INDEX|FULLTEXT|SPATIAL)\b`)
+	columnNoisePattern   = regexp.MustCompile(`(?is)\s+(?:(?:CHARACTER\s+SET|CHARSET)\s+\w+|COLLATE\s+[\w.]*(?:"[^"]*")?|DEFAULT\s+nextval\('(?:[^']|'')*'(?:::regclass)?\))`)
+	columnCommentPattern = regexp.MustCompile(`(?is)\s+COMMENT\s+'((?:[^']|'')*)'`)
+	tableCommentPattern  = regexp.MustCompile(`(?is)\bCOMMENT\s*=?\s*'((?:[^']|'')*)'`)
+)
+
+// normalizedTable is a CREATE TABLE statement rebuilt by Normalize.
+type normalizedTable struct {
+	// text is the statement as written, used unless the table changed.
+	text string
+	// header is the statement up to the column list, e.g. CREATE TABLE
+	// public.orders, and comments the comments kept before it.
+	header   string
+	comments []string
+	items    []string
+	// comment is the table's comment and columnComments the comments of
+	// its columns, keyed by the lower-cased column name.
+	comment        string
+	columnComments map[string]string
+	changed        bool
+}
+
+// String renders the table with one column or constraint per line and the
+// comments as SQL comments.
+func (t *normalizedTable) String() string {
+	if !t.changed {
+		return strings.Join(append(t.comments, t.text), "\n")
+	}
+	var b strings.Builder
+	for _, c := range t.comments {
+		b.WriteString(c + "\n")
+	}
+	if t.comment != "" {
+		b.WriteString("-- " + t.comment + "\n")
+	}
+	b.WriteString(t.header + " (\n")
+	for i, item := range t.items {
+		b.WriteString("  " + item)
+		if i < len(t.items)-1 {
+			b.WriteString(",")
+		}
+		name, _ := splitName(item)
+		if comment := t.columnComments[strings.ToLower(name)]; comment != "" && !constraintPattern.MatchString(item) {
+			b.WriteString(" -- " + comment)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// hasColumn reports whether the table has a column with the given name,
+// ignoring case.
+func (t *normalizedTable) hasColumn(column string) bool {
+	for _, item := range t.items {
+		if name, _ := splitName(item); !constraintPattern.MatchString(item) && strings.EqualFold(name, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize compacts DDL pasted from schema dumps such as pg_dump
+// --schema-only, mysqldump --no-data or DuckDB's EXPORT DATABASE. It drops
+// the statements that do not describe the schema (settings, ownership,
+// privileges, sequences, plain indexes, triggers and data), the dump's
+// header comments and MySQL table options, folds COMMENT ON statements and
+// inline COMMENT clauses into SQL comments and ALTER TABLE ... ADD
+// CONSTRAINT statements into their table. Constraints, views and other
+// statements are kept. DDL without any of this is returned unchanged.
+func Normalize(ddl string) Normalized {
+	result := Normalized{DDL: ddl}
+	text := metaCommandPattern.ReplaceAllString(ddl, "")
+	modified := text != ddl
+
+	// entries are the statements in DDL order, either verbatim or a table
+	// to be rendered once all statements folding into it are known
+	type entry struct {
+		text  string
+		table *normalizedTable
+	}
+	var entries []entry
+	tables := make(map[string]*normalizedTable)
+	lookup := func(name string) *normalizedTable {
+		if t := tables[canonicalName(name)]; t != nil {
+			return t
+		}
+		return tables[Unqualified(name)]
+	}
+
+	for _, stmt := range splitStatements(text) {
+		leading := leadingPattern.FindString(stmt)
+		body := strings.TrimSpace(stmt[len(leading):])
+		var comments []string
+		for _, comment := range commentPattern.FindAllString(leading, -1) {
+			if dumpCommentPattern.MatchString(comment) || strings.HasPrefix(comment, "/*!") {
+				modified = true
+				continue
+			}
+			comments = append(comments, comment)
+		}
+		if body == "" {
+			modified = true
+			continue
+		}
+
+		if noisePattern.MatchString(body) {
+			result.Removed++
+			continue
+		}
+
+		if t := normalizeTable(body); t != nil {
+			t.comments = comments
+			entries = append(entries, entry{table: t})
+			name := tableStmtPattern.FindStringSubmatch(body)[1]
+			tables[canonicalName(name)] = t
+			tables[Unqualified(name)] = t
+			continue
+		}
+
+		if m := commentOnPattern.FindStringSubmatch(body); m != nil && m[3] != "NULL" {
+			comment := unquoteComment(m[3])
+			name := m[2]
+			if strings.EqualFold(m[1], "TABLE") {
+				if t := lookup(name); t != nil {
+					t.comment, t.changed = comment, true
+					result.Removed++
+					continue
+				}
+			} else if i := strings.LastIndex(name, "."); i > 0 {
+				column := unquote(name[i+1:])
+				if t := lookup(name[:i]); t != nil && t.hasColumn(column) {
+					t.columnComments[strings.ToLower(column)], t.changed = comment, true
+					result.Removed++
+					continue
+				}
+			}
+		}
+
+		if m := addConstraintPattern.FindStringSubmatch(body); m != nil {
+			if t := lookup(m[1]); t != nil {
+				t.items = append(t.items, constraintNamePattern.ReplaceAllString(strings.TrimSpace(m[2]), ""))
+				t.changed = true
+				result.Removed++
+				continue
+			}
+		}
+
+		entries = append(entries, entry{text: strings.Join(append(comments, body), "\n")})
+	}
+
+	statements := make([]string, len(entries))
+	for i, e := range entries {
+		if e.table == nil {
+			statements[i] = e.text
+			continue
+		}
+		modified = modified || e.table.changed
+		statements[i] = e.table.String()
+	}
+	if !modified && result.Removed == 0 {
+		return result
+	}
+
+	result.DDL = strings.Join(statements, ";\n")
+	if result.DDL != "" {
+		result.DDL += ";"
+	}
+	result.Saved = max(Tokens(ddl)-Tokens(result.DDL), 0)
+	return result
+}
+
+// normalizeTable parses a CREATE TABLE statement with a column list,
+// dropping index definitions, collations, character sets, sequence
+// defaults and table options and collecting inline comments. It returns
+// nil for other statements and for tables with comments inside the column
+// list, which are kept as they are.
+func normalizeTable(body string) *normalizedTable {
+	loc := tableStmtPattern.FindStringIndex(body)
+	if loc == nil {
+		return nil
+	}
+	rest := strings.TrimSpace(body[loc[1]:])
+	clean := stripComments(rest)
+	if !strings.HasPrefix(clean, "(") {
+		return nil
+	}
+	items, end := splitTopLevel(clean[1:])
+	if end < 0 || !strings.HasPrefix(rest, clean[:end+2]) {
+		return nil
+	}
+
+	t := &normalizedTable{text: body, header: strings.TrimSpace(body[:loc[1]]), columnComments: make(map[string]string)}
+	if tail := strings.TrimSpace(clean[end+2:]); tail != "" {
+		t.changed = true
+		if m := tableCommentPattern.FindStringSubmatch(tail); m != nil {
+			t.comment = unquoteComment("'" + m[1] + "'")
+		}
+	}
+
+	for _, item := range items {
+		if indexPattern.MatchString(item) {
+			t.changed = true
+			continue
+		}
+		if constraintPattern.MatchString(item) {
+			t.items = append(t.items, item)
+			continue
+		}
+		cleaned := item
+		if m := columnCommentPattern.FindStringSubmatch(cleaned); m != nil {
+			name, _ := splitName(item)
+			t.columnComments[strings.ToLower(name)] = unquoteComment("'" + m[1] + "'")
+			cleaned = columnCommentPattern.ReplaceAllString(cleaned, "")
+		}
+		cleaned = columnNoisePattern.ReplaceAllString(cleaned, "")
+		t.changed = t.changed || cleaned != item
+		t.items = append(t.items, cleaned)
+	}
+	return t
+}
+
+// canonicalName returns the lower-cased name without identifier quotes,
+// e.g. sales.orders for sales."Orders".
+func canonicalName(name string) string {
+	return strings.ToLower(strings.NewReplacer(`"`, "", "`", "", "[", "", "]", "").Replace(name))
+}
+
+// unquoteComment returns the text of a quoted SQL string on a single line.
+func unquoteComment(literal string) string {
+	text := strings.ReplaceAll(literal[1:len(literal)-1], "''", "'")
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,177 @@
+package schema
+
+import "testing"
+
+const pgDump = `--
+-- PostgreSQL database dump
+--
+
+-- Dumped from database version 16.2
+-- Dumped by pg_dump version 16.2
+
+\restrict abc
+SET statement_timeout = 0;
+SET client_encoding = 'UTF8';
+SELECT pg_catalog.set_config('search_path', '', false);
+
+--
+-- Name: update_modified(); Type: FUNCTION; Schema: public; Owner: postgres
+--
+
+CREATE FUNCTION public.update_modified() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+  NEW.modified = now();
+  RETURN NEW;
+END;
+$$;
+
+ALTER FUNCTION public.update_modified() OWNER TO postgres;
+
+SET default_tablespace = '';
+
+--
+-- Name: users; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.users (
+    id integer NOT NULL,
+    email text COLLATE pg_catalog."default" NOT NULL
+);
+
+
+ALTER TABLE public.users OWNER TO postgres;
+
+--
+-- Name: COLUMN users.email; Type: COMMENT; Schema: public; Owner: postgres
+--
+
+COMMENT ON COLUMN public.users.email IS 'Login e-mail, lower-cased';
+COMMENT ON TABLE public.users IS 'Registered users';
+
+CREATE SEQUENCE public.users_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+ALTER SEQUENCE public.users_id_seq OWNED BY public.users.id;
+
+CREATE TABLE public.orders (
+    id integer NOT NULL,
+    user_id integer,
+    total numeric(10,2)
+);
+
+ALTER TABLE ONLY public.users ALTER COLUMN id SET DEFAULT nextval('public.users_id_seq'::regclass);
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.orders
+    ADD CONSTRAINT orders_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id);
+
+CREATE INDEX orders_user_id_idx ON public.orders USING btree (user_id);
+
+CREATE UNIQUE INDEX users_email_key ON public.users USING btree (email);
+
+CREATE TRIGGER users_modified BEFORE UPDATE ON public.users FOR EACH ROW EXECUTE FUNCTION public.update_modified();
+
+GRANT ALL ON TABLE public.users TO app;
+
+--
+-- PostgreSQL database dump complete
+--
+`
+
+const mysqlDump = "-- MySQL dump 10.13  Distrib 8.0.36, for Linux (x86_64)\n--\n-- Host: localhost    Database: shop\n-- ------------------------------------------------------\n-- Server version\t8.0.36\n\n/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;\n/*!50503 SET NAMES utf8mb4 */;\n\n--\n-- Table structure for table `orders`\n--\n\nDROP TABLE IF EXISTS `orders`;\n/*!40101 SET @saved_cs_client     = @@character_set_client */;\n/*!50503 SET character_set_client = utf8mb4 */;\nCREATE TABLE `orders` (\n  `id` int NOT NULL AUTO_INCREMENT,\n  `user_id` int NOT NULL,\n  `status` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'new' COMMENT 'new, paid or shipped',\n  PRIMARY KEY (`id`),\n  KEY `idx_user` (`user_id`),\n  CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)\n) ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='Customer orders';\n/*!40101 SET character_set_client = @saved_cs_client */;\n\nLOCK TABLES `orders` WRITE;\nUNLOCK TABLES;\n-- Dump completed on 2026-03-02  9:30:00\n"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		ddl      string
+		expected string
+		removed  int
+	}{
+		{
+			name: "pg_dump",
+			ddl:  pgDump,
+			expected: `-- Registered users
+CREATE TABLE public.users (
+  id integer NOT NULL,
+  email text NOT NULL, -- Login e-mail, lower-cased
+  PRIMARY KEY (id)
+);
+CREATE TABLE public.orders (
+  id integer NOT NULL,
+  user_id integer,
+  total numeric(10,2),
+  FOREIGN KEY (user_id) REFERENCES public.users(id)
+);
+CREATE UNIQUE INDEX users_email_key ON public.users USING btree (email);`,
+			removed: 17,
+		},
+		{
+			name: "mysqldump",
+			ddl:  mysqlDump,
+			expected: "-- Customer orders\nCREATE TABLE `orders` (\n" +
+				"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+				"  `user_id` int NOT NULL,\n" +
+				"  `status` varchar(20) NOT NULL DEFAULT 'new', -- new, paid or shipped\n" +
+				"  PRIMARY KEY (`id`),\n" +
+				"  CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)\n);",
+			removed: 3,
+		},
+		{
+			name: "DuckDB export",
+			ddl: `CREATE SCHEMA sales;
+CREATE SEQUENCE sales.order_ids START 1;
+CREATE TABLE sales.orders(id BIGINT DEFAULT(nextval('sales.order_ids')), total DECIMAL(10,2));
+CREATE VIEW sales.big_orders AS SELECT * FROM sales.orders WHERE total > 1000;
+CREATE INDEX orders_total ON sales.orders(total);`,
+			expected: `CREATE TABLE sales.orders(id BIGINT DEFAULT(nextval('sales.order_ids')), total DECIMAL(10,2));
+CREATE VIEW sales.big_orders AS SELECT * FROM sales.orders WHERE total > 1000;`,
+			removed: 3,
+		},
+		{
+			name: "functions are kept whole",
+			ddl: `SET search_path = public;
+CREATE FUNCTION net(total numeric) RETURNS numeric AS $body$ SELECT total / 1.19; $body$ LANGUAGE sql;
+CREATE TABLE t (a INT);`,
+			expected: `CREATE FUNCTION net(total numeric) RETURNS numeric AS $body$ SELECT total / 1.19; $body$ LANGUAGE sql;
+CREATE TABLE t (a INT);`,
+			removed: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Normalize(tc.ddl)
+			if got.DDL != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, got.DDL)
+			}
+			if got.Removed != tc.removed {
+				t.Errorf("expected %d statements removed, got %d", tc.removed, got.Removed)
+			}
+			if got.Saved != Tokens(tc.ddl)-Tokens(got.DDL) {
+				t.Errorf("expected %d tokens saved, got %d", Tokens(tc.ddl)-Tokens(got.DDL), got.Saved)
+			}
+		})
+	}
+}
+
+func TestNormalize_Unchanged(t *testing.T) {
+	ddls := []string{
+		"CREATE TABLE users (id INT, name TEXT); CREATE TABLE invoices (id INT, total DECIMAL);",
+		"-- customers and their orders\nCREATE TABLE orders (\n  id INT, -- order number\n  total DECIMAL\n);\nCOMMENT ON COLUMN orders.total IS 'Gross total';",
+	}
+
+	for _, ddl := range ddls {
+		if got := Normalize(ddl); got.DDL != ddl || got.Removed != 0 || got.Saved != 0 {
+			t.Errorf("expected %q unchanged, got %+v", ddl, got)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package schema
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// BM25 parameters, and the share of its best foreign key neighbour's score
+// a table gets on top of its own.
+const (
+	bm25K1         = 1.2
+	bm25B          = 0.75
+	neighbourBonus = 0.5
+)
+
+// Result is the outcome of pruning a schema.
+type Result struct {
+	DDL string
+	// Tables are the names of the tables kept, in DDL order, and Total the
+	// number of tables in the schema. Tables is nil if the schema was not
+	// pruned.
+	Tables []string
+	Total  int
+}
+
+// Tokens estimates the prompt tokens of text, at roughly four characters
+// per token.
+func Tokens(text string) int {
+	return len(text)/4 + 1
+}
+
+// Prune keeps the tables most relevant to the question when the DDL takes
+// more than budget tokens or creates more than maxTables tables. Tables are
+// ranked by BM25 of the question against their names, columns and
+// comments, plus a bonus for tables joined to relevant tables,
+// and kept while they fit into budget and maxTables. Statements that
+// belong to no table are always kept, and so is the most relevant table.
+// A budget or maxTables of 0 means no limit.
+func Prune(ddl, question string, budget, maxTables int) Result {
+	s := Parse(ddl)
+	result := Result{DDL: ddl, Total: len(s.Tables)}
+	if (budget <= 0 || Tokens(ddl) <= budget) && (maxTables <= 0 || len(s.Tables) <= maxTables) {
+		return result
+	}
+
+	// Statements of no table are kept, so they count against the budget
+	used := 0
+	for i, stmt := range s.statements {
+		if s.owners[i] == nil {
+			used += Tokens(stmt)
+		}
+	}
+
+	keep := make(map[*Table]bool)
+	for _, t := range s.rank(question) {
+		if maxTables > 0 && len(keep) == maxTables {
+			break
+		}
+		cost := Tokens(strings.Join(t.Statements, ";\n"))
+		// The most relevant table is kept even if it alone exceeds the budget
+		if budget > 0 && used+cost > budget && len(keep) > 0 {
+			continue
+		}
+		used += cost
+		keep[t] = true
+	}
+
+	result.DDL = s.DDL(keep)
+	result.Tables = []string{}
+	for _, t := range s.Tables {
+		if keep[t] {
+			result.Tables = append(result.Tables, t.Name)
+		}
+	}
+	return result
+}
+
+// rank orders the tables by relevance to the question, most relevant
+// first. Tables with equal scores keep their DDL order.
+func (s *Schema) rank(question string) []*Table {
+	docs := make([][]string, len(s.Tables))
+	df := make(map[string]int)
+	total := 0
+	for i, t := range s.Tables {
+		// The table name counts twice, since it says most about the table
+		docs[i] = append(terms(t.Name), terms(t.Name+" "+strings.Join(t.Statements, " "))...)
+		total += len(docs[i])
+		seen := make(map[string]bool)
+		for _, term := range docs[i] {
+			if !seen[term] {
+				seen[term] = true
+				df[term]++
+			}
+		}
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+	avgLen := float64(total) / float64(len(docs))
+
+	query := terms(question)
+	scores := make(map[*Table]float64)
+	for i, t := range s.Tables {
+		tf := make(map[string]int)
+		for _, term := range docs[i] {
+			tf[term]++
+		}
+		for _, term := range query {
+			if tf[term] == 0 {
+				continue
+			}
+			idf := math.Log(1 + (float64(len(docs))-float64(df[term])+0.5)/(float64(df[term])+0.5))
+			f := float64(tf[term])
+			scores[t] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(len(docs[i]))/avgLen))
+		}
+	}
+
+	// Tables joined to relevant tables are likely needed for the join
+	byName := make(map[string]*Table)
+	for _, t := range s.Tables {
+		byName[t.Name] = t
+	}
+	bonus := make(map[*Table]float64)
+	for _, j := range s.Joins() {
+		from, to := byName[j.From], byName[j.To]
+		bonus[from] = math.Max(bonus[from], neighbourBonus*scores[to])
+		bonus[to] = math.Max(bonus[to], neighbourBonus*scores[from])
+	}
+
+	ranked := append([]*Table(nil), s.Tables...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i]]+bonus[ranked[i]] > scores[ranked[j]]+bonus[ranked[j]]
+	})
+	return ranked
+}
+
+var (
+	termPattern      = regexp.MustCompile(`[\pL\pN]+`)
+	camelCasePattern = regexp.MustCompile(`(\p{Ll})(\p{Lu})`)
+)
+
+// terms splits text into lower-cased terms, breaking identifiers at
+// underscores and camel case and reducing plurals such as "orders" and
+// "categories" to their singular.
+func terms(text string) []string {
+	text = camelCasePattern.ReplaceAllString(text, "$1 $2")
+	var result []string
+	for _, term := range termPattern.FindAllString(strings.ToLower(text), -1) {
+		switch {
+		case len(term) > 4 && strings.HasSuffix(term, "ies"):
+			term = term[:len(term)-3] + "y"
+		case len(term) > 3 && strings.HasSuffix(term, "s") && !strings.HasSuffix(term, "ss"):
+			term = term[:len(term)-1]
+		}
+		result = append(result, term)
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// warehouse returns DDL with filler tables around a small orders schema.
+func warehouse(filler int) string {
+	var b strings.Builder
+	for i := 0; i < filler; i++ {
+		fmt.Fprintf(&b, "CREATE TABLE stg_log_%d (id INT, payload TEXT, loaded_at TIMESTAMP);\n", i)
+	}
+	b.WriteString("CREATE TABLE dim_customer (id INT, full_name TEXT, state_code TEXT); -- customers\n")
+	b.WriteString("CREATE TABLE fct_orders (id INT, customer_id INT REFERENCES dim_customer(id), amountNet DECIMAL);\n")
+	b.WriteString("CREATE TABLE dim_product (id INT, name TEXT);\n")
+	return b.String()
+}
+
+func TestPrune(t *testing.T) {
+	ddl := warehouse(30)
+
+	result := Prune(ddl, "Net amount of orders by customer state", 0, 2)
+
+	if result.Total != 33 {
+		t.Errorf("expected 33 tables, got %d", result.Total)
+	}
+	if got := strings.Join(result.Tables, ","); got != "dim_customer,fct_orders" {
+		t.Errorf("expected dim_customer,fct_orders, got %s", got)
+	}
+	if strings.Contains(result.DDL, "stg_log") || !strings.Contains(result.DDL, "CREATE TABLE fct_orders") {
+		t.Errorf("unexpected DDL %q", result.DDL)
+	}
+}
+
+func TestPrune_ForeignKeyNeighbour(t *testing.T) {
+	result := Prune(warehouse(30), "Total amountNet of orders", 0, 2)
+
+	// dim_customer does not match the question but is joined to fct_orders
+	if got := strings.Join(result.Tables, ","); got != "dim_customer,fct_orders" {
+		t.Errorf("expected dim_customer,fct_orders, got %s", got)
+	}
+}
+
+func TestPrune_Budget(t *testing.T) {
+	ddl := warehouse(30)
+	budget := Tokens(ddl) / 10
+
+	result := Prune(ddl, "Orders per product", budget, 0)
+
+	if Tokens(result.DDL) > budget {
+		t.Errorf("expected at most %d tokens, got %d", budget, Tokens(result.DDL))
+	}
+	if len(result.Tables) == 0 || len(result.Tables) == result.Total {
+		t.Errorf("expected some tables to be pruned, got %v", result.Tables)
+	}
+	if !strings.Contains(result.DDL, "dim_product") || !strings.Contains(result.DDL, "fct_orders") {
+		t.Errorf("expected the relevant tables, got %v", result.Tables)
+	}
+}
+
+func TestPrune_Unchanged(t *testing.T) {
+	ddl := warehouse(2)
+
+	for _, limits := range [][2]int{{0, 0}, {Tokens(ddl), 0}, {0, 5}} {
+		result := Prune(ddl, "Orders", limits[0], limits[1])
+		if result.DDL != ddl || result.Tables != nil {
+			t.Errorf("expected the DDL unchanged for limits %v, got %v", limits, result.Tables)
+		}
+	}
+}
+
+func TestTerms(t *testing.T) {
+	got := strings.Join(terms("fct_orders.amountNet sales_class"), " ")
+	if got != "fct order amount net sale class" {
+		t.Errorf("unexpected terms %q", got)
+	}
+}
+
+func TestPrune_KeepsMostRelevantTable(t *testing.T) {
+	result := Prune(warehouse(3), "Orders by customer", 1, 0)
+
+	if got := strings.Join(result.Tables, ","); got != "fct_orders" {
+		t.Errorf("expected only fct_orders, got %s", got)
+	}
+}
@@ -0,0 +1,333 @@
+// Package schema extracts the structure of a schema from its DDL, compacts
+// schema dumps, infers the joins between its tables, prunes large schemas
+// to the tables relevant to a question and checks queries against them.
+package schema
+
+import (
+	"regexp"
+	"strings"
+)
+
+// namePattern matches a possibly qualified and quoted table name.
+const namePattern = "([\\w.\"`\\[\\]]+)"
+
+// createTable matches the start of a CREATE TABLE statement.
+const createTable = `CREATE\s+(?:OR\s+REPLACE\s+)?(?:TEMP(?:ORARY)?\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?` + namePattern
+
+// leadingComments matches the whitespace and comments before a statement.
+const leadingComments = `(?:\s+|--[^\n]*|/\*.*?\*/)*`
+
+var (
+	createTablePattern = regexp.MustCompile(`(?i)` + createTable)
+	tableStmtPattern   = regexp.MustCompile(`(?is)^` + leadingComments + createTable)
+	// attachedPattern matches statements that belong to a table: comments,
+	// indexes and ALTER TABLE.
+	attachedPattern = regexp.MustCompile(`(?is)^` + leadingComments + `(?:` +
+		`COMMENT\s+ON\s+TABLE\s+` + namePattern + `|` +
+		`COMMENT\s+ON\s+COLUMN\s+` + namePattern + "\\.[\\w\"`\\[\\]]+|" +
+		`CREATE\s+(?:UNIQUE\s+)?INDEX\s+.*?\s+ON\s+(?:ONLY\s+)?` + namePattern + `|` +
+		`ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?` + namePattern + `)`)
+)
+
+// Tables returns the lower-cased, unqualified names of the tables the DDL
+// creates.
+func Tables(ddl string) map[string]bool {
+	tables := make(map[string]bool)
+	for _, match := range createTablePattern.FindAllStringSubmatch(ddl, -1) {
+		tables[Unqualified(match[1])] = true
+	}
+	return tables
+}
+
+// Unqualified returns the lower-cased last part of a possibly qualified
+// and quoted name, e.g. orders for sales."Orders".
+func Unqualified(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.ToLower(strings.Trim(name, "\"`[]"))
+}
+
+// Table is a table created by the DDL together with the statements that
+// belong to it.
+type Table struct {
+	// Name is the table name as written in the DDL.
+	Name string
+	// Statements are the CREATE TABLE statement and the comments, indexes
+	// and ALTER TABLE statements on the table, in DDL order.
+	Statements []string
+	Columns    []Column
+	// foreignKeys are the declared foreign keys, with To holding the
+	// unqualified name of the referenced table.
+	foreignKeys []Join
+}
+
+// Column is a column of a table.
+type Column struct {
+	Name string
+	// Type is the lower-cased base type without parameters, e.g. decimal
+	// for DECIMAL(10, 2).
+	Type string
+}
+
+// column returns the column with the given name, ignoring case, or nil.
+func (t *Table) column(name string) *Column {
+	for i := range t.Columns {
+		if strings.EqualFold(t.Columns[i].Name, name) {
+			return &t.Columns[i]
+		}
+	}
+	return nil
+}
+
+// Schema is a parsed DDL.
+type Schema struct {
+	Tables []*Table
+	// statements are all statements in DDL order; owners maps the index of
+	// a statement to its table, or nil for statements of no table.
+	statements []string
+	owners     []*Table
+}
+
+// Parse splits the DDL into statements and groups them by table.
+// Statements that belong to no table, such as views, are kept as they are.
+func Parse(ddl string) *Schema {
+	s := &Schema{}
+	byName := make(map[string]*Table)
+	for _, stmt := range splitStatements(ddl) {
+		var owner *Table
+		if loc := tableStmtPattern.FindStringSubmatchIndex(stmt); loc != nil {
+			owner = &Table{Name: stmt[loc[2]:loc[3]]}
+			owner.Columns, owner.foreignKeys = parseColumns(stmt[loc[1]:])
+			byName[Unqualified(owner.Name)] = owner
+			s.Tables = append(s.Tables, owner)
+		} else if m := attachedPattern.FindStringSubmatch(stmt); m != nil {
+			for _, name := range m[1:] {
+				if name != "" {
+					owner = byName[Unqualified(name)]
+				}
+			}
+		}
+
+		if owner != nil {
+			owner.Statements = append(owner.Statements, stmt)
+			if len(owner.Statements) > 1 {
+				owner.foreignKeys = append(owner.foreignKeys, foreignKeys(stripComments(stmt))...)
+			}
+		}
+		s.statements = append(s.statements, stmt)
+		s.owners = append(s.owners, owner)
+	}
+	return s
+}
+
+// DDL returns the statements of the given tables and all statements of no
+// table in DDL order, each terminated by a semicolon.
+func (s *Schema) DDL(tables map[*Table]bool) string {
+	var b strings.Builder
+	for i, stmt := range s.statements {
+		if owner := s.owners[i]; owner != nil && !tables[owner] {
+			continue
+		}
+		b.WriteString(stmt)
+		b.WriteString(";\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// dollarQuotePattern matches the opening tag of a dollar-quoted string
+// such as a PostgreSQL function body, e.g. $$ or $body$.
+var dollarQuotePattern = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+// splitStatements splits DDL at semicolons outside of strings, quoted
+// identifiers, dollar-quoted bodies and comments, dropping empty
+// statements.
+func splitStatements(ddl string) []string {
+	var statements []string
+	start := 0
+	var quote byte
+	for i := 0; i < len(ddl); i++ {
+		c := ddl[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '-' && strings.HasPrefix(ddl[i:], "--"):
+			if end := strings.IndexByte(ddl[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(ddl)
+			}
+		case c == '/' && strings.HasPrefix(ddl[i:], "/*"):
+			if end := strings.Index(ddl[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(ddl)
+			}
+		case c == '$' && dollarQuotePattern.MatchString(ddl[i:]):
+			tag := dollarQuotePattern.FindString(ddl[i:])
+			if end := strings.Index(ddl[i+len(tag):], tag); end >= 0 {
+				i += len(tag) + end + len(tag) - 1
+			} else {
+				i = len(ddl)
+			}
+		case c == ';':
+			statements = appendStatement(statements, ddl[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(ddl) {
+		statements = appendStatement(statements, ddl[start:])
+	}
+	return statements
+}
+
+func appendStatement(statements []string, stmt string) []string {
+	if stmt = strings.TrimSpace(stmt); stmt != "" {
+		statements = append(statements, stmt)
+	}
+	return statements
+}
+
+var (
+	constraintPattern = regexp.MustCompile(`(?i)^(?:CONSTRAINT|PRIMARY|FOREIGN|UNIQUE|CHECK|KEY|INDEX|EXCLUDE|FULLTEXT|SPATIAL)\b`)
+	foreignKeyPattern = regexp.MustCompile(`(?is)FOREIGN\s+KEY\s*\(([^)]*)\)\s*REFERENCES\s+` + namePattern + `\s*(?:\(([^)]*)\))?`)
+	referencesPattern = regexp.MustCompile(`(?is)\bREFERENCES\s+` + namePattern + `\s*(?:\(([^)]*)\))?`)
+	typePattern       = regexp.MustCompile(`^\s*([A-Za-z_][\w]*)`)
+)
+
+// parseColumns parses the column list that follows the table name of a
+// CREATE TABLE statement into its columns and declared foreign keys.
+func parseColumns(rest string) ([]Column, []Join) {
+	rest = strings.TrimSpace(stripComments(rest))
+	if !strings.HasPrefix(rest, "(") {
+		return nil, nil
+	}
+
+	var columns []Column
+	var keys []Join
+	items, _ := splitTopLevel(rest[1:])
+	for _, item := range items {
+		if constraintPattern.MatchString(item) {
+			keys = append(keys, foreignKeys(item)...)
+			continue
+		}
+
+		name, remainder := splitName(item)
+		column := Column{Name: name}
+		if m := typePattern.FindStringSubmatch(remainder); m != nil {
+			column.Type = strings.ToLower(m[1])
+		}
+		columns = append(columns, column)
+
+		if m := referencesPattern.FindStringSubmatch(remainder); m != nil {
+			keys = append(keys, Join{FromColumn: name, To: Unqualified(m[1]), ToColumn: unquote(m[2])})
+		}
+	}
+	return columns, keys
+}
+
+// foreignKeys returns the FOREIGN KEY constraints declared in text.
+func foreignKeys(text string) []Join {
+	var keys []Join
+	for _, m := range foreignKeyPattern.FindAllStringSubmatch(text, -1) {
+		from := strings.Split(m[1], ",")
+		to := strings.Split(m[3], ",")
+		for i := range from {
+			key := Join{FromColumn: unquote(from[i]), To: Unqualified(m[2])}
+			if i < len(to) {
+				key.ToColumn = unquote(to[i])
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// splitTopLevel splits the inside of a parenthesized list at commas outside
+// of nested parentheses and quotes, stopping at the closing parenthesis. It
+// also returns the index of the closing parenthesis, or -1 if there is none.
+func splitTopLevel(text string) ([]string, int) {
+	var items []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == ')' || (c == ',' && depth == 0):
+			if item := strings.TrimSpace(text[start:i]); item != "" {
+				items = append(items, item)
+			}
+			if c == ')' {
+				return items, i
+			}
+			start = i + 1
+		}
+	}
+	return items, -1
+}
+
+// splitName splits a column definition into its unquoted name and the
+// rest of the definition.
+func splitName(item string) (string, string) {
+	if close := map[byte]byte{'"': '"', '`': '`', '[': ']'}[item[0]]; close != 0 {
+		if end := strings.IndexByte(item[1:], close); end >= 0 {
+			return item[1 : end+1], item[end+2:]
+		}
+	}
+	if end := strings.IndexFunc(item, func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' || r == '\r' }); end >= 0 {
+		return item[:end], item[end:]
+	}
+	return item, ""
+}
+
+// unquote trims whitespace and identifier quotes from a column name.
+func unquote(name string) string {
+	return strings.Trim(strings.TrimSpace(name), "\"`[]")
+}
+
+// stripComments removes comments outside of strings and quoted identifiers.
+func stripComments(text string) string {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '-' && strings.HasPrefix(text[i:], "--"):
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				return b.String()
+			}
+			i += end
+			c = '\n'
+		case c == '/' && strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return b.String()
+			}
+			i += end + 3
+			c = ' '
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTables(t *testing.T) {
+	ddl := `CREATE TABLE users (id INT);
+create or replace temp table IF NOT EXISTS sales."Orders" (id INT);
+CREATE TABLE ` + "`analytics`.`events`" + ` (id INT);
+CREATE VIEW active_users AS SELECT * FROM users;`
+
+	tables := Tables(ddl)
+
+	for _, name := range []string{"users", "orders", "events"} {
+		if !tables[name] {
+			t.Errorf("expected table %s, got %v", name, tables)
+		}
+	}
+	if len(tables) != 3 {
+		t.Errorf("expected 3 tables, got %v", tables)
+	}
+}
+
+func TestParse(t *testing.T) {
+	ddl := `-- Customers of the shop
+CREATE TABLE customers (id INT PRIMARY KEY, name TEXT);
+CREATE TABLE orders (
+  id INT,
+  customer_id INT REFERENCES customers(id), -- buyer; never null
+  note TEXT DEFAULT 'a;b'
+);
+COMMENT ON COLUMN public.orders.note IS 'Free text';
+CREATE INDEX orders_customer ON orders (customer_id);
+CREATE VIEW big_orders AS SELECT * FROM orders;`
+
+	s := Parse(ddl)
+
+	if len(s.Tables) != 2 {
+		t.Fatalf("expected 2 tables, got %d", len(s.Tables))
+	}
+	orders := s.Tables[1]
+	if orders.Name != "orders" || len(orders.Statements) != 3 {
+		t.Errorf("expected orders with its comment and index, got %s with %q", orders.Name, orders.Statements)
+	}
+	if len(orders.Columns) != 3 || orders.Columns[1].Name != "customer_id" || orders.Columns[1].Type != "int" {
+		t.Errorf("expected columns id, customer_id and note, got %v", orders.Columns)
+	}
+	if len(orders.foreignKeys) != 1 || orders.foreignKeys[0].To != "customers" {
+		t.Errorf("expected a foreign key to customers, got %v", orders.foreignKeys)
+	}
+
+	got := s.DDL(map[*Table]bool{orders: true})
+	if strings.Contains(got, "CREATE TABLE customers") || !strings.Contains(got, "note TEXT DEFAULT 'a;b'\n);") || !strings.HasSuffix(got, "CREATE VIEW big_orders AS SELECT * FROM orders;") {
+		t.Errorf("unexpected DDL %q", got)
+	}
+}
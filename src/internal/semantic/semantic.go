@@ -0,0 +1,158 @@
+// Package semantic holds the semantic layer: business terms, canonical
+// metric definitions and column descriptions that explain a warehouse
+// schema to the model.
+package semantic
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/schema"
+)
+
+// Term maps a business term and its synonyms to what it means in the
+// schema, e.g. "customers" to the dim_account table.
+type Term struct {
+	Term     string   `json:"term"`
+	Synonyms []string `json:"synonyms,omitempty"`
+	// RefersTo names the table, column or expression the term stands for.
+	RefersTo    string `json:"refers_to,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Metric is the canonical definition of a metric.
+type Metric struct {
+	Name     string   `json:"name"`
+	Synonyms []string `json:"synonyms,omitempty"`
+	// Definition is the SQL expression computing the metric, e.g.
+	// SUM(total) - SUM(refunds).
+	Definition  string `json:"definition"`
+	Description string `json:"description,omitempty"`
+}
+
+// Column describes a column, named as table.column.
+type Column struct {
+	Column      string `json:"column"`
+	Description string `json:"description"`
+}
+
+// Layer is a semantic layer, or the part of it relevant to a question.
+type Layer struct {
+	Terms   []Term   `json:"terms,omitempty"`
+	Metrics []Metric `json:"metrics,omitempty"`
+	Columns []Column `json:"columns,omitempty"`
+}
+
+// Load reads a semantic layer from a JSON file.
+func Load(path string) (*Layer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var layer Layer
+	if err := json.Unmarshal(data, &layer); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := layer.validate(); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &layer, nil
+}
+
+// validate checks that every entry has the fields it needs.
+func (l *Layer) validate() error {
+	for _, t := range l.Terms {
+		if t.Term == "" || (t.RefersTo == "" && t.Description == "") {
+			return fmt.Errorf("term %q needs 'refers_to' or 'description'", t.Term)
+		}
+	}
+	for _, m := range l.Metrics {
+		if m.Name == "" || m.Definition == "" {
+			return fmt.Errorf("metric %q needs 'name' and 'definition'", m.Name)
+		}
+	}
+	for _, c := range l.Columns {
+		if !strings.Contains(c.Column, ".") || c.Description == "" {
+			return fmt.Errorf("column %q needs a table.column name and 'description'", c.Column)
+		}
+	}
+	return nil
+}
+
+// Empty reports whether the layer has no entries.
+func (l Layer) Empty() bool {
+	return len(l.Terms) == 0 && len(l.Metrics) == 0 && len(l.Columns) == 0
+}
+
+// Relevant returns the entries that apply to a question about the schema
+// in ddl: terms and metrics the question mentions by name or synonym, and
+// the descriptions of columns of tables the DDL creates. A nil Layer has no
+// entries.
+func (l *Layer) Relevant(question, ddl string) Layer {
+	var relevant Layer
+	if l == nil {
+		return relevant
+	}
+
+	for _, t := range l.Terms {
+		if mentions(question, append([]string{t.Term}, t.Synonyms...)) {
+			relevant.Terms = append(relevant.Terms, t)
+		}
+	}
+	for _, m := range l.Metrics {
+		if mentions(question, append([]string{m.Name}, m.Synonyms...)) {
+			relevant.Metrics = append(relevant.Metrics, m)
+		}
+	}
+
+	tables := schema.Tables(ddl)
+	for _, c := range l.Columns {
+		if tables[schema.Unqualified(c.Column[:strings.LastIndex(c.Column, ".")])] {
+			relevant.Columns = append(relevant.Columns, c)
+		}
+	}
+	return relevant
+}
+
+// mentions reports whether text contains one of the phrases as whole words,
+// ignoring case and a plural "s" or "es".
+func mentions(text string, phrases []string) bool {
+	text = strings.ToLower(text)
+	for _, phrase := range phrases {
+		if phrase == "" {
+			continue
+		}
+		phrase = strings.ToLower(phrase)
+		for from := 0; ; {
+			at := strings.Index(text[from:], phrase)
+			if at < 0 {
+				break
+			}
+			start, end := from+at, from+at+len(phrase)
+			if (start == 0 || !isWordByte(text[start-1])) && endsWord(text[end:]) {
+				return true
+			}
+			from = start + 1
+		}
+	}
+	return false
+}
+
+// endsWord reports whether rest, the text following a phrase, ends the
+// phrase's last word right away or after a plural "s" or "es".
+func endsWord(rest string) bool {
+	for _, suffix := range []string{"", "s", "es"} {
+		if after, ok := strings.CutPrefix(rest, suffix); ok && (after == "" || !isWordByte(after[0])) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWordByte reports whether b is an ASCII letter, digit or underscore.
+func isWordByte(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
@@ -0,0 +1,107 @@
+package semantic
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeLayer(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "semantic.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	layer, err := Load(writeLayer(t, `{
+		"terms": [{"term": "customer", "synonyms": ["client"], "refers_to": "dim_account"}],
+		"metrics": [{"name": "net revenue", "definition": "SUM(total) - SUM(refunds)"}],
+		"columns": [{"column": "dim_account.acct_st_cd", "description": "Account status: A=active, C=closed"}]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(layer.Terms) != 1 || len(layer.Metrics) != 1 || len(layer.Columns) != 1 {
+		t.Errorf("expected one entry of each kind, got %+v", layer)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"invalid json":        `{"terms": [`,
+		"term without target": `{"terms": [{"term": "customer"}]}`,
+		"metric without sql":  `{"metrics": [{"name": "net revenue"}]}`,
+		"unqualified column":  `{"columns": [{"column": "acct_st_cd", "description": "status"}]}`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writeLayer(t, content)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestRelevant(t *testing.T) {
+	layer := &Layer{
+		Terms: []Term{
+			{Term: "customer", Synonyms: []string{"client"}, RefersTo: "dim_account"},
+			{Term: "churn", Description: "no order in the last 90 days"},
+		},
+		Metrics: []Metric{
+			{Name: "net revenue", Definition: "SUM(total) - SUM(refunds)"},
+			{Name: "AOV", Synonyms: []string{"average order value"}, Definition: "SUM(total) / COUNT(*)"},
+		},
+		Columns: []Column{
+			{Column: "dim_account.acct_st_cd", Description: "Account status"},
+			{Column: "dwh.fct_orders.amt_net", Description: "Order total after discounts"},
+			{Column: "fct_returns.amt", Description: "Refunded amount"},
+		},
+	}
+	ddl := "CREATE TABLE dim_account (acct_st_cd TEXT); CREATE TABLE dwh.FCT_ORDERS (amt_net DECIMAL);"
+
+	relevant := layer.Relevant("Net revenue per Client, excluding churned customers", ddl)
+
+	if len(relevant.Terms) != 1 || relevant.Terms[0].Term != "customer" {
+		t.Errorf("expected the customer term, got %+v", relevant.Terms)
+	}
+	if len(relevant.Metrics) != 1 || relevant.Metrics[0].Name != "net revenue" {
+		t.Errorf("expected the net revenue metric, got %+v", relevant.Metrics)
+	}
+	if len(relevant.Columns) != 2 {
+		t.Errorf("expected the columns of tables in the DDL, got %+v", relevant.Columns)
+	}
+
+	if relevant := layer.Relevant("What is the AOV?", ""); len(relevant.Metrics) != 1 || len(relevant.Columns) != 0 {
+		t.Errorf("expected only the AOV metric, got %+v", relevant)
+	}
+
+	var none *Layer
+	if !none.Relevant("Net revenue", ddl).Empty() {
+		t.Error("expected no entries without a layer")
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := map[string]bool{
+		"Revenue per client":        true,
+		"Revenue per Clients":       true,
+		"client_id of orders":       false,
+		"Revenue per clientele":     false,
+		"Which (client) ordered?":   true,
+		"Top 5 clients, by revenue": true,
+		"subclient revenue":         false,
+	}
+	for text, expected := range tests {
+		if got := mentions(text, []string{"", "client"}); got != expected {
+			t.Errorf("mentions(%q) = %v, expected %v", text, got, expected)
+		}
+	}
+	if !mentions("Net revenue of big boxes", []string{"box"}) {
+		t.Error("expected the plural 'es' to match")
+	}
+}
@@ -0,0 +1,196 @@
+// Package session keeps the turns of conversations, so follow-up questions
+// such as "now break that down by month" can refine the previous query.
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"maps"
+	"sync"
+	"time"
+)
+
+// MaxTurns is the number of most recent turns kept per conversation.
+const MaxTurns = 10
+
+// Turn is a question and the SQL generated for it, or the clarifying
+// question the model asked back instead.
+type Turn struct {
+	Question      string `json:"question"`
+	SQL           string `json:"sql,omitempty"`
+	Clarification string `json:"clarification,omitempty"`
+}
+
+// Session is a conversation.
+type Session struct {
+	ID string
+	// DDL and Database are those of the latest turn; follow-ups that send
+	// no DDL or database reuse them.
+	DDL      string
+	Database string
+	Turns    []Turn
+
+	// count is the number of turns ever taken, including those dropped
+	// beyond MaxTurns, and native maps a provider to its own session of
+	// the conversation.
+	count   int
+	native  map[string]native
+	updated time.Time
+}
+
+// native is a provider's own session, the number of turns it holds and
+// the schema it was given.
+type native struct {
+	id     string
+	turns  int
+	schema string
+}
+
+// Resume returns the provider's own session of the conversation if it
+// holds every turn and was given the same schema, or "" if the turns have
+// to be replayed. schema identifies the schema part of the prompt, e.g. the
+// pruned DDL and the data hints, which resumed sessions are not sent again.
+func (s *Session) Resume(provider, schema string) string {
+	if s == nil {
+		return ""
+	}
+	if n, ok := s.native[provider]; ok && n.turns == s.count && n.schema == schema {
+		return n.id
+	}
+	return ""
+}
+
+// size estimates the bytes the session takes in memory.
+func (s *Session) size() int {
+	size := len(s.ID) + len(s.DDL) + len(s.Database)
+	for _, turn := range s.Turns {
+		size += len(turn.Question) + len(turn.SQL) + len(turn.Clarification)
+	}
+	for provider, n := range s.native {
+		size += len(provider) + len(n.id) + len(n.schema)
+	}
+	return size
+}
+
+// Store keeps sessions in memory until they have not been used for the TTL.
+// When it holds more than maxSessions sessions or maxBytes bytes, the least
+// recently used sessions are evicted. A nil Store keeps no sessions.
+type Store struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	maxSessions int
+	maxBytes    int
+	bytes       int
+	sessions    map[string]*Session
+	now         func() time.Time
+}
+
+// NewStore creates a Store whose sessions expire after ttl without use and
+// that keeps at most maxSessions sessions of at most maxBytes bytes in
+// total; 0 disables a limit.
+func NewStore(ttl time.Duration, maxSessions, maxBytes int) *Store {
+	return &Store{ttl: ttl, maxSessions: maxSessions, maxBytes: maxBytes, sessions: make(map[string]*Session), now: time.Now}
+}
+
+// Get returns a copy of the session with the given ID, or false if it is
+// unknown or expired. The copy shares no state with the stored session, so
+// it can be read while Save updates the session.
+func (s *Store) Get(id string) (*Session, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.sessions[id]
+	if !ok || s.now().Sub(stored.updated) > s.ttl {
+		return nil, false
+	}
+	copied := *stored
+	copied.Turns = append([]Turn(nil), stored.Turns...)
+	copied.native = maps.Clone(stored.native)
+	return &copied, true
+}
+
+// Save adds a turn to the session with the given ID, or to a new session
+// if id is empty or the session expired meanwhile, and returns the
+// session's ID. provider is the provider that answered and nativeID its own
+// session, if it keeps one, which was given schema. A changed DDL or
+// database invalidates the providers' own sessions. Expired sessions are
+// removed, and the least recently used ones evicted until the store is
+// within its limits again; the saved session itself is never evicted. A
+// nil Store returns "".
+func (s *Store) Save(id, ddl, database string, turn Turn, provider, nativeID, schema string) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, stored := range s.sessions {
+		if now.Sub(stored.updated) > s.ttl {
+			s.remove(key)
+		}
+	}
+
+	stored, ok := s.sessions[id]
+	if !ok {
+		if id == "" {
+			id = newID()
+		}
+		stored = &Session{ID: id, native: make(map[string]native)}
+		s.sessions[id] = stored
+	} else {
+		s.bytes -= stored.size()
+	}
+	if stored.DDL != ddl || stored.Database != database {
+		stored.DDL, stored.Database = ddl, database
+		clear(stored.native)
+	}
+
+	stored.Turns = append(stored.Turns, turn)
+	if len(stored.Turns) > MaxTurns {
+		stored.Turns = stored.Turns[len(stored.Turns)-MaxTurns:]
+	}
+	stored.count++
+	if nativeID != "" {
+		stored.native[provider] = native{id: nativeID, turns: stored.count, schema: schema}
+	}
+	stored.updated = now
+	s.bytes += stored.size()
+	s.evict(id)
+	return id
+}
+
+// remove deletes the session with the given ID.
+func (s *Store) remove(id string) {
+	s.bytes -= s.sessions[id].size()
+	delete(s.sessions, id)
+}
+
+// evict removes the least recently used sessions other than keep while the
+// store holds too many sessions or bytes.
+func (s *Store) evict(keep string) {
+	for (s.maxSessions > 0 && len(s.sessions) > s.maxSessions) || (s.maxBytes > 0 && s.bytes > s.maxBytes) {
+		oldest := ""
+		for key, stored := range s.sessions {
+			if key != keep && (oldest == "" || stored.updated.Before(s.sessions[oldest].updated)) {
+				oldest = key
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		s.remove(oldest)
+	}
+}
+
+// newID returns a random session ID.
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
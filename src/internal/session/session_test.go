@@ -0,0 +1,179 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	store := NewStore(time.Minute, 0, 0)
+
+	id := store.Save("", "CREATE TABLE orders (id INT)", "DuckDB", Turn{Question: "Orders", SQL: "SELECT * FROM orders"}, "claude", "", "")
+	if id == "" {
+		t.Fatal("expected a session ID")
+	}
+	if again := store.Save(id, "CREATE TABLE orders (id INT)", "DuckDB", Turn{Question: "Only paid ones", SQL: "SELECT 2"}, "claude", "", ""); again != id {
+		t.Errorf("expected the same session ID, got %s", again)
+	}
+
+	s, ok := store.Get(id)
+	if !ok {
+		t.Fatal("expected the session")
+	}
+	if len(s.Turns) != 2 || s.Turns[1].Question != "Only paid ones" || s.DDL != "CREATE TABLE orders (id INT)" || s.Database != "DuckDB" {
+		t.Errorf("unexpected session %+v", s)
+	}
+
+	s.Turns[0].SQL = "changed"
+	if stored, _ := store.Get(id); stored.Turns[0].SQL != "SELECT * FROM orders" {
+		t.Error("expected Get to return a copy")
+	}
+
+	if _, ok := store.Get("unknown"); ok {
+		t.Error("expected no session for an unknown ID")
+	}
+}
+
+func TestStore_Expiry(t *testing.T) {
+	store := NewStore(time.Minute, 0, 0)
+	now := time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	id := store.Save("", "CREATE TABLE t (a INT)", "DuckDB", Turn{Question: "q", SQL: "SELECT 1"}, "claude", "", "")
+	now = now.Add(2 * time.Minute)
+
+	if _, ok := store.Get(id); ok {
+		t.Error("expected the session to expire")
+	}
+	store.Save("", "CREATE TABLE t (a INT)", "DuckDB", Turn{Question: "q", SQL: "SELECT 1"}, "claude", "", "")
+	if len(store.sessions) != 1 {
+		t.Errorf("expected expired sessions to be removed, got %d sessions", len(store.sessions))
+	}
+}
+
+func TestStore_Limits(t *testing.T) {
+	now := time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC)
+	tick := func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	// At most two sessions: saving to the first keeps it over the second
+	store := NewStore(time.Hour, 2, 0)
+	store.now = tick
+	first := store.Save("", "CREATE TABLE t (a INT)", "DuckDB", Turn{Question: "q", SQL: "SELECT 1"}, "claude", "", "")
+	second := store.Save("", "CREATE TABLE t (a INT)", "DuckDB", Turn{Question: "q", SQL: "SELECT 1"}, "claude", "", "")
+	store.Save(first, "CREATE TABLE t (a INT)", "DuckDB", Turn{Question: "q", SQL: "SELECT 1"}, "claude", "", "")
+	third := store.Save("", "CREATE TABLE t (a INT)", "DuckDB", Turn{Question: "q", SQL: "SELECT 1"}, "claude", "", "")
+	if _, ok := store.Get(second); ok {
+		t.Error("expected the least recently used session to be evicted")
+	}
+	for _, id := range []string{first, third} {
+		if _, ok := store.Get(id); !ok {
+			t.Errorf("expected session %s to be kept", id)
+		}
+	}
+
+	// At most 200 bytes: a session with a large DDL evicts the others
+	store = NewStore(time.Hour, 0, 200)
+	store.now = tick
+	small := store.Save("", "CREATE TABLE t (a INT)", "DuckDB", Turn{Question: "q", SQL: "SELECT 1"}, "claude", "", "")
+	large := store.Save("", "CREATE TABLE t ("+strings.Repeat("a INT, ", 20)+"b INT)", "DuckDB", Turn{Question: "q", SQL: "SELECT 1"}, "claude", "", "")
+	if _, ok := store.Get(small); ok {
+		t.Error("expected the small session to be evicted for the large one")
+	}
+	if _, ok := store.Get(large); !ok {
+		t.Error("expected the saved session to be kept")
+	}
+	if store.bytes != store.sessions[large].size() {
+		t.Errorf("expected %d bytes, got %d", store.sessions[large].size(), store.bytes)
+	}
+}
+
+func TestStore_MaxTurns(t *testing.T) {
+	store := NewStore(time.Minute, 0, 0)
+
+	id := ""
+	for i := range MaxTurns + 2 {
+		id = store.Save(id, "CREATE TABLE t (a INT)", "DuckDB", Turn{Question: fmt.Sprint(i), SQL: "SELECT 1"}, "claude", "", "")
+	}
+
+	s, _ := store.Get(id)
+	if len(s.Turns) != MaxTurns || s.Turns[0].Question != "2" {
+		t.Errorf("expected the last %d turns, got %+v", MaxTurns, s.Turns)
+	}
+}
+
+func TestSession_Resume(t *testing.T) {
+	store := NewStore(time.Minute, 0, 0)
+	ddl := "CREATE TABLE t (a INT)"
+
+	id := store.Save("", ddl, "DuckDB", Turn{Question: "q1", SQL: "SELECT 1"}, "claude", "claude-1", "s1")
+	if s, _ := store.Get(id); s.Resume("claude", "s1") != "claude-1" || s.Resume("codex", "s1") != "" {
+		t.Errorf("expected claude to resume its own session only")
+	}
+
+	// A turn answered by another provider is missing from claude's session
+	store.Save(id, ddl, "DuckDB", Turn{Question: "q2", SQL: "SELECT 2"}, "codex", "", "")
+	if s, _ := store.Get(id); s.Resume("claude", "s1") != "" {
+		t.Error("expected claude's session to be outdated")
+	}
+
+	// So is a session that saw another DDL
+	store.Save(id, ddl, "DuckDB", Turn{Question: "q3", SQL: "SELECT 3"}, "claude", "claude-2", "s1")
+	store.Save(id, "CREATE TABLE t (a INT, b INT)", "DuckDB", Turn{Question: "q4", SQL: "SELECT 4"}, "opencode", "", "")
+	if s, _ := store.Get(id); s.Resume("claude", "s1") != "" {
+		t.Error("expected a changed DDL to invalidate claude's session")
+	}
+
+	// And one given another schema, e.g. when the DDL is pruned to other
+	// tables for a follow-up
+	store.Save(id, ddl, "DuckDB", Turn{Question: "q5", SQL: "SELECT 5"}, "claude", "claude-3", "s1")
+	if s, _ := store.Get(id); s.Resume("claude", "s2") != "" || s.Resume("claude", "s1") != "claude-3" {
+		t.Error("expected a changed schema to keep claude from resuming its session")
+	}
+
+	var none *Session
+	if none.Resume("claude", "s1") != "" {
+		t.Error("expected no session to resume without a session")
+	}
+}
+
+func TestStore_ConcurrentResume(t *testing.T) {
+	store := NewStore(time.Minute, 0, 0)
+	ddl := "CREATE TABLE t (a INT)"
+	id := store.Save("", ddl, "DuckDB", Turn{Question: "q", SQL: "SELECT 1"}, "claude", "claude-1", "s1")
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				store.Save(id, ddl, "DuckDB", Turn{Question: "q", SQL: "SELECT 1"}, "claude", fmt.Sprint("claude-", i), "s1")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				if s, ok := store.Get(id); ok {
+					s.Resume("claude", "s1")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestStore_Nil(t *testing.T) {
+	var store *Store
+	if id := store.Save("", "", "", Turn{}, "claude", "", ""); id != "" {
+		t.Errorf("expected no session ID, got %s", id)
+	}
+	if _, ok := store.Get("abc"); ok {
+		t.Error("expected no session")
+	}
+}
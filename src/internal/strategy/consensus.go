@@ -0,0 +1,100 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/provider"
+)
+
+// Variant is one distinct answer in a consensus run, together with the
+// providers that gave it.
+type Variant struct {
+	SQL       string   `json:"sql"`
+	Providers []string `json:"providers"`
+}
+
+// Consensus asks every entry of names concurrently (a provider may appear
+// several times to sample it repeatedly) and votes on the answers. Answers
+// are compared after Normalize; the largest group wins, ties going to the
+// group that appears first in names. The outcome carries the result that
+// first gave the winning SQL, the share of valid answers that agree with
+// it, and the dissenting variants. Answers rejected by validate and
+// providers asking for clarification do not vote; a clarification is only
+// reported if no provider answered.
+func Consensus(ctx context.Context, names []string, validate Validator, run RunFunc) (Outcome, error) {
+	type answer struct {
+		result   provider.Result
+		err      error
+		duration time.Duration
+	}
+
+	answers := make([]answer, len(names))
+	done := make(chan struct{}, len(names))
+	start := time.Now()
+	for i, name := range names {
+		go func(i int, name string) {
+			result, err := run(ctx, name)
+			if err == nil && validate != nil {
+				err = validate(result.SQL)
+			}
+			answers[i] = answer{result: result, err: err, duration: time.Since(start)}
+			done <- struct{}{}
+		}(i, name)
+	}
+	for range names {
+		<-done
+	}
+
+	var outcome Outcome
+	var lastErr error
+	var variants []Variant
+	// results holds the answer that first gave each variant
+	var results []provider.Result
+	index := make(map[string]int)
+	valid := 0
+
+	for i, a := range answers {
+		attempt := Attempt{Provider: names[i], DurationMS: a.duration.Milliseconds()}
+		if a.err != nil {
+			attempt.fail(a.err)
+			lastErr = lastError(lastErr, a.err)
+		}
+		outcome.Attempts = append(outcome.Attempts, attempt)
+		if a.err != nil {
+			continue
+		}
+		valid++
+
+		key := Normalize(a.result.SQL)
+		if j, ok := index[key]; ok {
+			variants[j].Providers = append(variants[j].Providers, names[i])
+			continue
+		}
+		index[key] = len(variants)
+		variants = append(variants, Variant{SQL: a.result.SQL, Providers: []string{names[i]}})
+		results = append(results, a.result)
+	}
+
+	if valid == 0 {
+		if ctx.Err() != nil {
+			return outcome, ctx.Err()
+		}
+		return outcome, errors.Join(ErrAllFailed, lastErr)
+	}
+
+	winner := 0
+	for i, v := range variants {
+		if len(v.Providers) > len(variants[winner].Providers) {
+			winner = i
+		}
+	}
+
+	outcome.Result = results[winner]
+	outcome.Provider = variants[winner].Providers[0]
+	outcome.Agreement = float64(len(variants[winner].Providers)) / float64(valid)
+	outcome.Dissent = append(variants[:winner:winner], variants[winner+1:]...)
+
+	return outcome, nil
+}
@@ -0,0 +1,110 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/provider"
+)
+
+func TestConsensus_MajorityWins(t *testing.T) {
+	answers := map[string]string{
+		"claude": "SELECT name FROM users;",
+		"gemini": "select NAME\nfrom users",
+		"codex":  "SELECT * FROM users",
+	}
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		return provider.Result{SQL: answers[name]}, nil
+	}
+
+	outcome, err := Consensus(context.Background(), []string{"codex", "claude", "gemini"}, NotEmpty, run)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if outcome.Result.SQL != "SELECT name FROM users;" || outcome.Provider != "claude" {
+		t.Errorf("expected claude's answer to win, got %+v", outcome)
+	}
+	if outcome.Agreement < 0.66 || outcome.Agreement > 0.67 {
+		t.Errorf("expected agreement 2/3, got %f", outcome.Agreement)
+	}
+	if len(outcome.Dissent) != 1 || outcome.Dissent[0].SQL != "SELECT * FROM users" || outcome.Dissent[0].Providers[0] != "codex" {
+		t.Errorf("expected codex to dissent, got %+v", outcome.Dissent)
+	}
+	if len(outcome.Attempts) != 3 {
+		t.Errorf("expected 3 attempts, got %+v", outcome.Attempts)
+	}
+}
+
+func TestConsensus_SameProviderSampled(t *testing.T) {
+	calls := make(chan struct{}, 3)
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		calls <- struct{}{}
+		return provider.Result{SQL: "SELECT 1"}, nil
+	}
+
+	outcome, err := Consensus(context.Background(), []string{"claude", "claude", "claude"}, NotEmpty, run)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(calls) != 3 {
+		t.Errorf("expected 3 calls, got %d", len(calls))
+	}
+	if outcome.Agreement != 1 || len(outcome.Dissent) != 0 {
+		t.Errorf("expected unanimous agreement, got %+v", outcome)
+	}
+}
+
+func TestConsensus_FailuresDoNotVote(t *testing.T) {
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		switch name {
+		case "claude":
+			return provider.Result{}, provider.ErrCLIExecution
+		case "gemini":
+			return provider.Result{}, nil
+		}
+		return provider.Result{SQL: "SELECT 1"}, nil
+	}
+
+	outcome, err := Consensus(context.Background(), []string{"claude", "gemini", "codex"}, NotEmpty, run)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if outcome.Provider != "codex" || outcome.Agreement != 1 {
+		t.Errorf("expected codex alone to decide, got %+v", outcome)
+	}
+	if outcome.Attempts[0].Error == "" || outcome.Attempts[1].Error != ErrEmptySQL.Error() {
+		t.Errorf("expected failed attempts to be recorded, got %+v", outcome.Attempts)
+	}
+}
+
+func TestConsensus_AllFail(t *testing.T) {
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		return provider.Result{}, provider.ErrParsing
+	}
+
+	_, err := Consensus(context.Background(), []string{"claude", "codex"}, NotEmpty, run)
+	if !errors.Is(err, ErrAllFailed) || !errors.Is(err, provider.ErrParsing) {
+		t.Errorf("expected ErrAllFailed wrapping the provider error, got %v", err)
+	}
+}
+
+func TestConsensus_Clarification(t *testing.T) {
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		if name == "claude" {
+			return provider.Result{}, &provider.Clarification{Question: "Gross or net revenue?"}
+		}
+		return provider.Result{SQL: "SELECT 1"}, nil
+	}
+
+	outcome, err := Consensus(context.Background(), []string{"claude", "codex"}, NotEmpty, run)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if outcome.Provider != "codex" || outcome.Agreement != 1 {
+		t.Errorf("expected the answering provider to decide, got %+v", outcome)
+	}
+}
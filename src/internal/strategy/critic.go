@@ -0,0 +1,154 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/provider"
+)
+
+// Verdicts of a Review.
+const (
+	ReviewApproved   = "approved"
+	ReviewCorrected  = "corrected"
+	ReviewUnreviewed = "unreviewed"
+)
+
+// Review is what the reviewer of a critic run made of the generator's
+// draft.
+type Review struct {
+	Generator string `json:"generator"`
+	Reviewer  string `json:"reviewer"`
+	// Verdict is ReviewApproved, ReviewCorrected, or ReviewUnreviewed if
+	// the reviewer failed. Corrected reviews carry the draft and the line
+	// diff from it to the corrected SQL.
+	Verdict string `json:"verdict"`
+	Draft   string `json:"draft,omitempty"`
+	Diff    string `json:"diff,omitempty"`
+	// Rationale is the reviewer's explanation, taken from the SQL comments
+	// it starts its answer with, or else from the explanation it reported.
+	Rationale string `json:"rationale,omitempty"`
+}
+
+// ReviewFunc asks the named provider to review a draft. It answers with
+// the draft unchanged to approve it, or with a corrected query.
+type ReviewFunc func(ctx context.Context, name string, draft provider.Result) (provider.Result, error)
+
+// Critic has generator draft the SQL and reviewer review the draft. The
+// outcome carries the draft if the reviewer approved it, comparing after
+// Normalize, and the reviewer's corrected SQL otherwise, together with the
+// Review. Answers rejected by validate count as failures. If the generator
+// fails the run fails; if the reviewer fails the draft is returned with a
+// ReviewUnreviewed verdict and the failed attempt is recorded.
+func Critic(ctx context.Context, generator, reviewer string, validate Validator, run RunFunc, review ReviewFunc) (Outcome, error) {
+	var outcome Outcome
+
+	start := time.Now()
+	draft, err := run(ctx, generator)
+	if err == nil && validate != nil {
+		err = validate(draft.SQL)
+	}
+	attempt := Attempt{Provider: generator, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		attempt.fail(err)
+		outcome.Attempts = append(outcome.Attempts, attempt)
+		if ctx.Err() != nil {
+			return outcome, ctx.Err()
+		}
+		return outcome, errors.Join(ErrAllFailed, err)
+	}
+	outcome.Attempts = append(outcome.Attempts, attempt)
+	outcome.Result = draft
+	outcome.Provider = generator
+
+	outcome.Review = &Review{Generator: generator, Reviewer: reviewer, Verdict: ReviewUnreviewed}
+
+	start = time.Now()
+	reviewed, err := review(ctx, reviewer, draft)
+	rationale, sql := splitRationale(reviewed.SQL)
+	if err == nil && validate != nil {
+		err = validate(sql)
+	}
+	attempt = Attempt{Provider: reviewer, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		attempt.fail(err)
+		outcome.Attempts = append(outcome.Attempts, attempt)
+		if ctx.Err() != nil {
+			return outcome, ctx.Err()
+		}
+		return outcome, nil
+	}
+	outcome.Attempts = append(outcome.Attempts, attempt)
+
+	if rationale == "" {
+		rationale = reviewed.Explanation
+	}
+	outcome.Review.Verdict = ReviewApproved
+	outcome.Review.Rationale = rationale
+	if Normalize(sql) != Normalize(draft.SQL) {
+		reviewed.SQL = sql
+		outcome.Result = reviewed
+		outcome.Provider = reviewer
+		outcome.Review.Verdict = ReviewCorrected
+		outcome.Review.Draft = draft.SQL
+		outcome.Review.Diff = Diff(draft.SQL, sql)
+	}
+	return outcome, nil
+}
+
+// splitRationale splits the -- comment lines an answer starts with from the
+// SQL that follows them, returning the comments' text on one line.
+func splitRationale(sql string) (string, string) {
+	var comments []string
+	rest := strings.TrimSpace(sql)
+	for strings.HasPrefix(rest, "--") {
+		line, after, _ := strings.Cut(rest, "\n")
+		if text := strings.TrimSpace(strings.TrimPrefix(line, "--")); text != "" {
+			comments = append(comments, text)
+		}
+		rest = strings.TrimSpace(after)
+	}
+	return strings.Join(comments, " "), rest
+}
+
+// Diff returns the line diff from a to b: unchanged lines prefixed by a
+// space, removed lines by - and added lines by +.
+func Diff(a, b string) string {
+	from, to := strings.Split(a, "\n"), strings.Split(b, "\n")
+
+	// common[i][j] is the length of the longest common subsequence of
+	// from[i:] and to[j:]
+	common := make([][]int, len(from)+1)
+	for i := range common {
+		common[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(from) || j < len(to) {
+		switch {
+		case i < len(from) && j < len(to) && from[i] == to[j]:
+			lines = append(lines, " "+from[i])
+			i++
+			j++
+		case j == len(to) || i < len(from) && common[i+1][j] >= common[i][j+1]:
+			lines = append(lines, "-"+from[i])
+			i++
+		default:
+			lines = append(lines, "+"+to[j])
+			j++
+		}
+	}
+	return strings.Join(lines, "\n")
+}
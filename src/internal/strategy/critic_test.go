@@ -0,0 +1,152 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/provider"
+)
+
+func TestCritic_Approved(t *testing.T) {
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		return provider.Result{SQL: "SELECT id\nFROM orders", Explanation: "All orders"}, nil
+	}
+	review := func(ctx context.Context, name string, draft provider.Result) (provider.Result, error) {
+		return provider.Result{SQL: "-- Correct as drafted\nselect id from orders;"}, nil
+	}
+
+	outcome, err := Critic(context.Background(), "gemini", "claude", NotEmpty, run, review)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if outcome.Provider != "gemini" || outcome.Result.SQL != "SELECT id\nFROM orders" || outcome.Result.Explanation != "All orders" {
+		t.Errorf("expected gemini's draft, got %+v", outcome)
+	}
+	expected := Review{Generator: "gemini", Reviewer: "claude", Verdict: ReviewApproved, Rationale: "Correct as drafted"}
+	if outcome.Review == nil || *outcome.Review != expected {
+		t.Errorf("expected %+v, got %+v", expected, outcome.Review)
+	}
+	if len(outcome.Attempts) != 2 || outcome.Attempts[0].Provider != "gemini" || outcome.Attempts[1].Provider != "claude" {
+		t.Errorf("expected the draft and the review attempts, got %+v", outcome.Attempts)
+	}
+}
+
+func TestCritic_Corrected(t *testing.T) {
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		return provider.Result{SQL: "SELECT SUM(total)\nFROM orders\nWHERE created_at > '2026-01-01'"}, nil
+	}
+	var got provider.Result
+	review := func(ctx context.Context, name string, draft provider.Result) (provider.Result, error) {
+		got = draft
+		return provider.Result{SQL: "-- The year starts on January 1st,\n-- which the draft excluded.\nSELECT SUM(total)\nFROM orders\nWHERE created_at >= '2026-01-01'"}, nil
+	}
+
+	outcome, err := Critic(context.Background(), "gemini", "claude", NotEmpty, run, review)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.SQL != "SELECT SUM(total)\nFROM orders\nWHERE created_at > '2026-01-01'" {
+		t.Errorf("expected the reviewer to get the draft, got %+v", got)
+	}
+	if outcome.Provider != "claude" || outcome.Result.SQL != "SELECT SUM(total)\nFROM orders\nWHERE created_at >= '2026-01-01'" {
+		t.Errorf("expected claude's correction without the comments, got %+v", outcome)
+	}
+	expected := Review{
+		Generator: "gemini",
+		Reviewer:  "claude",
+		Verdict:   ReviewCorrected,
+		Draft:     got.SQL,
+		Diff:      " SELECT SUM(total)\n FROM orders\n-WHERE created_at > '2026-01-01'\n+WHERE created_at >= '2026-01-01'",
+		Rationale: "The year starts on January 1st, which the draft excluded.",
+	}
+	if outcome.Review == nil || *outcome.Review != expected {
+		t.Errorf("expected %+v, got %+v", expected, outcome.Review)
+	}
+}
+
+func TestCritic_RationaleFromExplanation(t *testing.T) {
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		return provider.Result{SQL: "SELECT SUM(total) FROM orders"}, nil
+	}
+	review := func(ctx context.Context, name string, draft provider.Result) (provider.Result, error) {
+		return provider.Result{SQL: "SELECT SUM(total) FROM orders WHERE status = 'paid'", Explanation: "Only paid orders count as revenue."}, nil
+	}
+
+	outcome, err := Critic(context.Background(), "gemini", "claude", NotEmpty, run, review)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if outcome.Review == nil || outcome.Review.Verdict != ReviewCorrected || outcome.Review.Rationale != "Only paid orders count as revenue." {
+		t.Errorf("expected the reported explanation as rationale, got %+v", outcome.Review)
+	}
+}
+
+func TestCritic_GeneratorFails(t *testing.T) {
+	reviewed := false
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		return provider.Result{}, provider.ErrCLIExecution
+	}
+	review := func(ctx context.Context, name string, draft provider.Result) (provider.Result, error) {
+		reviewed = true
+		return draft, nil
+	}
+
+	outcome, err := Critic(context.Background(), "gemini", "claude", NotEmpty, run, review)
+	if !errors.Is(err, ErrAllFailed) || !errors.Is(err, provider.ErrCLIExecution) {
+		t.Errorf("expected ErrAllFailed wrapping the provider error, got %v", err)
+	}
+	if reviewed || len(outcome.Attempts) != 1 {
+		t.Errorf("expected no review, got %+v", outcome.Attempts)
+	}
+}
+
+func TestCritic_ReviewerFails(t *testing.T) {
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		return provider.Result{SQL: "SELECT 1"}, nil
+	}
+
+	for name, review := range map[string]ReviewFunc{
+		"error": func(ctx context.Context, name string, draft provider.Result) (provider.Result, error) {
+			return provider.Result{}, provider.ErrAPIRequest
+		},
+		"only comments": func(ctx context.Context, name string, draft provider.Result) (provider.Result, error) {
+			return provider.Result{SQL: "-- Looks fine"}, nil
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			outcome, err := Critic(context.Background(), "gemini", "claude", NotEmpty, run, review)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if outcome.Result.SQL != "SELECT 1" || outcome.Provider != "gemini" {
+				t.Errorf("expected the draft, got %+v", outcome)
+			}
+			if outcome.Review == nil || outcome.Review.Verdict != ReviewUnreviewed || outcome.Review.Reviewer != "claude" {
+				t.Errorf("expected an unreviewed verdict, got %+v", outcome.Review)
+			}
+			if len(outcome.Attempts) != 2 || outcome.Attempts[1].Error == "" {
+				t.Errorf("expected the failed review attempt, got %+v", outcome.Attempts)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b, expected string
+	}{
+		{"SELECT 1", "SELECT 1", " SELECT 1"},
+		{"SELECT 1", "SELECT 2", "-SELECT 1\n+SELECT 2"},
+		{"SELECT a\nFROM t", "SELECT a\nFROM t\nLIMIT 10", " SELECT a\n FROM t\n+LIMIT 10"},
+		{"SELECT a\nFROM t\nWHERE b", "SELECT a\nFROM t", " SELECT a\n FROM t\n-WHERE b"},
+	}
+
+	for _, tc := range tests {
+		if got := Diff(tc.a, tc.b); got != tc.expected {
+			t.Errorf("Diff(%q, %q): expected %q, got %q", tc.a, tc.b, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/provider"
+)
+
+// ErrAllFailed is returned when no provider produced SQL.
+var ErrAllFailed = errors.New("all providers failed")
+
+// Attempt records one provider call made while serving a request. Error
+// only classifies a failure, since the errors of CLIs carry their stderr;
+// Err is the full error, for the server log.
+type Attempt struct {
+	Provider   string `json:"provider"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	Err        error  `json:"-"`
+}
+
+// fail records err as the attempt's failure.
+func (a *Attempt) fail(err error) {
+	a.Err = err
+	a.Error = describe(err)
+}
+
+// describe classifies a failed provider call for the client.
+func describe(err error) string {
+	var clarification *provider.Clarification
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timed out"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.As(err, &clarification):
+		return "asked for clarification"
+	case errors.Is(err, ErrEmptySQL), errors.Is(err, ErrInvalidSQL):
+		return err.Error()
+	case errors.Is(err, provider.ErrCLIExecution):
+		return "cli failed"
+	case errors.Is(err, provider.ErrAPIRequest):
+		return "api error"
+	case errors.Is(err, provider.ErrParsing):
+		return "parse error"
+	}
+	return "failed"
+}
+
+// Outcome is the result of running a strategy: Result is what the winning
+// Provider returned. Agreement and Dissent are only set by Consensus and
+// Review only by Critic.
+type Outcome struct {
+	Result    provider.Result
+	Provider  string
+	Attempts  []Attempt
+	Agreement float64
+	Dissent   []Variant
+	Review    *Review
+}
+
+// RunFunc generates SQL with the named provider.
+type RunFunc func(ctx context.Context, name string) (provider.Result, error)
+
+// Fallback tries the providers in chain order and returns the first success.
+// A provider failing with a CLI, API or parsing error, or running longer than
+// attemptTimeout (if non-zero), hands over to the next one. The last provider
+// is not bounded by attemptTimeout and gets whatever time ctx has left.
+// Cancellation of ctx itself stops the chain immediately.
+func Fallback(ctx context.Context, chain []string, attemptTimeout time.Duration, run RunFunc) (Outcome, error) {
+	var outcome Outcome
+	var lastErr error
+
+	for i, name := range chain {
+		timeout := attemptTimeout
+		if i == len(chain)-1 {
+			timeout = 0
+		}
+		attemptCtx, cancel := withAttemptTimeout(ctx, timeout)
+
+		start := time.Now()
+		result, err := run(attemptCtx, name)
+		cancel()
+
+		attempt := Attempt{Provider: name, DurationMS: time.Since(start).Milliseconds()}
+		if err == nil {
+			outcome.Attempts = append(outcome.Attempts, attempt)
+			outcome.Result = result
+			outcome.Provider = name
+			return outcome, nil
+		}
+
+		attempt.fail(err)
+		outcome.Attempts = append(outcome.Attempts, attempt)
+		lastErr = err
+
+		if ctx.Err() != nil {
+			return outcome, ctx.Err()
+		}
+		if !Retryable(err) {
+			return outcome, err
+		}
+	}
+
+	return outcome, errors.Join(ErrAllFailed, lastErr)
+}
+
+// lastError returns the error to report when no provider produced SQL,
+// given the one reported so far and that of the latest failed attempt: a
+// provider asking for clarification outranks other failures.
+func lastError(reported, err error) error {
+	var clarification *provider.Clarification
+	if errors.As(reported, &clarification) && !errors.As(err, &clarification) {
+		return reported
+	}
+	return err
+}
+
+// withAttemptTimeout derives the context for a single provider call.
+func withAttemptTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// Retryable reports whether err is a provider failure that another provider
+// might not have: CLI or API failures, unparseable output, and timeouts. A
+// provider asking for clarification is not retried: the question is
+// ambiguous for the next provider as well.
+func Retryable(err error) bool {
+	return errors.Is(err, provider.ErrCLIExecution) ||
+		errors.Is(err, provider.ErrAPIRequest) ||
+		errors.Is(err, provider.ErrParsing) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
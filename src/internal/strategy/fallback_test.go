@@ -0,0 +1,162 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/provider"
+)
+
+// scriptedRun returns a RunFunc that answers from a fixed table and records
+// which providers were called.
+func scriptedRun(results map[string]error, calls *[]string) RunFunc {
+	return func(ctx context.Context, name string) (provider.Result, error) {
+		*calls = append(*calls, name)
+		if err := results[name]; err != nil {
+			return provider.Result{}, err
+		}
+		return provider.Result{SQL: "SELECT '" + name + "'"}, nil
+	}
+}
+
+func TestFallback_FirstSucceeds(t *testing.T) {
+	var calls []string
+	outcome, err := Fallback(context.Background(), []string{"claude", "codex"}, 0, scriptedRun(nil, &calls))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if outcome.Provider != "claude" || outcome.Result.SQL != "SELECT 'claude'" {
+		t.Errorf("unexpected outcome: %+v", outcome)
+	}
+	if len(calls) != 1 {
+		t.Errorf("expected only claude to be called, got %v", calls)
+	}
+	if len(outcome.Attempts) != 1 || outcome.Attempts[0].Error != "" {
+		t.Errorf("unexpected attempts: %+v", outcome.Attempts)
+	}
+}
+
+func TestFallback_RetryableErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		message string
+	}{
+		{"cli execution", errors.Join(provider.ErrCLIExecution, errors.New("usage limit reached for /home/alice")), "cli failed"},
+		{"api request", errors.Join(provider.ErrAPIRequest, errors.New("status 529")), "api error"},
+		{"parsing", provider.ErrParsing, "parse error"},
+		{"timeout", errors.Join(provider.ErrCLIExecution, context.DeadlineExceeded), "timed out"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls []string
+			run := scriptedRun(map[string]error{"claude": tc.err}, &calls)
+
+			outcome, err := Fallback(context.Background(), []string{"claude", "codex", "gemini"}, 0, run)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if outcome.Provider != "codex" {
+				t.Errorf("expected codex to answer, got %q", outcome.Provider)
+			}
+			if len(outcome.Attempts) != 2 || outcome.Attempts[0].Provider != "claude" || outcome.Attempts[0].Error != tc.message {
+				t.Errorf("expected failed claude attempt to be recorded as %q, got %+v", tc.message, outcome.Attempts)
+			}
+			if outcome.Attempts[0].Err != tc.err {
+				t.Errorf("expected the full error to be kept, got %v", outcome.Attempts[0].Err)
+			}
+		})
+	}
+}
+
+func TestFallback_NonRetryableError(t *testing.T) {
+	var calls []string
+	run := scriptedRun(map[string]error{"claude": errors.New("template error")}, &calls)
+
+	_, err := Fallback(context.Background(), []string{"claude", "codex"}, 0, run)
+	if err == nil || err.Error() != "template error" {
+		t.Errorf("expected the non-retryable error, got %v", err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("expected chain to stop, got calls %v", calls)
+	}
+}
+
+func TestFallback_AllFail(t *testing.T) {
+	var calls []string
+	run := scriptedRun(map[string]error{
+		"claude": provider.ErrCLIExecution,
+		"codex":  provider.ErrParsing,
+	}, &calls)
+
+	outcome, err := Fallback(context.Background(), []string{"claude", "codex"}, 0, run)
+	if !errors.Is(err, ErrAllFailed) {
+		t.Errorf("expected ErrAllFailed, got %v", err)
+	}
+	if !errors.Is(err, provider.ErrParsing) {
+		t.Errorf("expected last error to be wrapped, got %v", err)
+	}
+	if len(outcome.Attempts) != 2 {
+		t.Errorf("expected 2 attempts, got %+v", outcome.Attempts)
+	}
+}
+
+func TestFallback_AttemptTimeout(t *testing.T) {
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		if name == "claude" {
+			<-ctx.Done()
+			return provider.Result{}, errors.Join(provider.ErrCLIExecution, ctx.Err())
+		}
+		return provider.Result{SQL: "SELECT 1"}, nil
+	}
+
+	outcome, err := Fallback(context.Background(), []string{"claude", "codex"}, 20*time.Millisecond, run)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if outcome.Provider != "codex" {
+		t.Errorf("expected codex after claude timed out, got %q", outcome.Provider)
+	}
+}
+
+func TestFallback_ParentCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var calls []string
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		calls = append(calls, name)
+		cancel()
+		return provider.Result{}, errors.Join(provider.ErrCLIExecution, ctx.Err())
+	}
+
+	_, err := Fallback(ctx, []string{"claude", "codex"}, 0, run)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("expected chain to stop after cancellation, got calls %v", calls)
+	}
+}
+
+func TestFallback_Clarification(t *testing.T) {
+	var calls []string
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		calls = append(calls, name)
+		return provider.Result{}, &provider.Clarification{Question: "Gross or net revenue?"}
+	}
+
+	_, err := Fallback(context.Background(), []string{"claude", "codex"}, 0, run)
+	var clarification *provider.Clarification
+	if !errors.As(err, &clarification) {
+		t.Errorf("expected the clarification, got %v", err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("expected the chain to stop at the clarification, got calls %v", calls)
+	}
+}
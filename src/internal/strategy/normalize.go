@@ -0,0 +1,148 @@
+package strategy
+
+import (
+	"strings"
+	"unicode"
+)
+
+// sqlKeywords are the words Normalize upper-cases. Every other unquoted word
+// is treated as an identifier and lower-cased, as SQL folds their case anyway.
+var sqlKeywords = map[string]bool{
+	"ALL": true, "AND": true, "ANTI": true, "ANY": true, "AS": true, "ASC": true,
+	"BETWEEN": true, "BY": true, "CASE": true, "CAST": true, "CROSS": true,
+	"CURRENT_DATE": true, "CURRENT_TIMESTAMP": true, "DESC": true, "DISTINCT": true,
+	"ELSE": true, "END": true, "EXCEPT": true, "EXISTS": true, "FALSE": true,
+	"FILTER": true, "FIRST": true, "FOLLOWING": true, "FROM": true, "FULL": true,
+	"GROUP": true, "HAVING": true, "ILIKE": true, "IN": true, "INNER": true,
+	"INTERSECT": true, "INTERVAL": true, "IS": true, "JOIN": true, "LAST": true,
+	"LATERAL": true, "LEFT": true, "LIKE": true, "LIMIT": true, "NATURAL": true,
+	"NOT": true, "NULL": true, "NULLS": true, "OFFSET": true, "ON": true, "OR": true,
+	"ORDER": true, "OUTER": true, "OVER": true, "PARTITION": true, "PRECEDING": true,
+	"QUALIFY": true, "RANGE": true, "RECURSIVE": true, "RIGHT": true, "ROWS": true,
+	"SELECT": true, "SEMI": true, "SIMILAR": true, "THEN": true, "TRUE": true,
+	"UNBOUNDED": true, "UNION": true, "USING": true, "VALUES": true, "WHEN": true,
+	"WHERE": true, "WINDOW": true, "WITH": true,
+}
+
+// Normalize rewrites SQL into a canonical form so that answers differing
+// only in layout compare equal: comments and the trailing semicolon are
+// dropped, whitespace is collapsed, keywords are upper-cased and unquoted
+// identifiers lower-cased. String literals and quoted identifiers are kept
+// verbatim.
+func Normalize(sql string) string {
+	var b strings.Builder
+	prev := ""
+
+	for _, token := range tokenize(sql) {
+		if needsSpace(prev, token) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(token)
+		prev = token
+	}
+
+	return strings.TrimSuffix(strings.TrimSpace(b.String()), ";")
+}
+
+// needsSpace reports whether a space separates two adjacent tokens in the
+// canonical form.
+func needsSpace(prev, token string) bool {
+	switch {
+	case prev == "":
+		return false
+	case token == "," || token == ")" || token == "." || token == ";":
+		return false
+	case prev == "(" || prev == ".":
+		return false
+	case token == "(":
+		// Function calls keep the parenthesis attached, keywords do not
+		return sqlKeywords[prev] || !isWordToken(prev)
+	}
+	return true
+}
+
+// isWordToken reports whether token is a bare or quoted identifier.
+func isWordToken(token string) bool {
+	r := rune(token[0])
+	return r == '_' || r == '"' || r == '`' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// tokenize splits SQL into canonicalized tokens, dropping whitespace and
+// comments.
+func tokenize(sql string) []string {
+	var tokens []string
+	runes := []rune(sql)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				i++
+			}
+			i = min(i+2, len(runes))
+
+		case r == '\'' || r == '"' || r == '`':
+			j := i + 1
+			for j < len(runes) {
+				if runes[j] == r {
+					// A doubled quote is an escaped quote
+					if j+1 < len(runes) && runes[j+1] == r {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			j = min(j+1, len(runes))
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+
+		case r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(runes) && (runes[j] == '_' || unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			word := string(runes[i:j])
+			if upper := strings.ToUpper(word); sqlKeywords[upper] {
+				word = upper
+			} else {
+				word = strings.ToLower(word)
+			}
+			tokens = append(tokens, word)
+			i = j
+
+		default:
+			// Multi-character operators
+			if i+1 < len(runes) {
+				if op := string(runes[i : i+2]); op == "<=" || op == ">=" || op == "<>" || op == "!=" || op == "||" || op == "::" {
+					tokens = append(tokens, op)
+					i += 2
+					continue
+				}
+			}
+			tokens = append(tokens, string(r))
+			i++
+		}
+	}
+
+	return tokens
+}
@@ -0,0 +1,54 @@
+package strategy
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "whitespace and keyword case",
+			input:    "select id,\n   name\nFROM Users  where id=1;",
+			expected: "SELECT id, name FROM users WHERE id = 1",
+		},
+		{
+			name:     "function calls and qualified names",
+			input:    "SELECT COUNT( * ) FROM main . orders o JOIN users u ON o.user_id = u.id",
+			expected: "SELECT count(*) FROM main.orders o JOIN users u ON o.user_id = u.id",
+		},
+		{
+			name:     "literals and quoted identifiers are kept",
+			input:    `select "First Name" from users where name = 'O''Brien Select'`,
+			expected: `SELECT "First Name" FROM users WHERE name = 'O''Brien Select'`,
+		},
+		{
+			name:     "comments are dropped",
+			input:    "-- all users\nSELECT * /* every column */ FROM users",
+			expected: "SELECT * FROM users",
+		},
+		{
+			name:     "operators and numbers",
+			input:    "SELECT price::DECIMAL FROM t WHERE price>=1.5 AND a<>b IN(1,2)",
+			expected: "SELECT price :: decimal FROM t WHERE price >= 1.5 AND a <> b IN (1, 2)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Normalize(tc.input); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNormalize_EquivalentLayouts(t *testing.T) {
+	a := "SELECT name, COUNT(*) AS n\nFROM users\nGROUP BY name\nORDER BY n DESC;"
+	b := "select name,count(*) as N from USERS group by NAME order by n desc"
+
+	if Normalize(a) != Normalize(b) {
+		t.Errorf("expected equal normal forms:\n%s\n%s", Normalize(a), Normalize(b))
+	}
+}
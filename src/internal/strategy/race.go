@@ -0,0 +1,94 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/provider"
+)
+
+// ErrEmptySQL is returned by NotEmpty for a blank answer, and ErrInvalidSQL
+// wraps the problems a Validator found in an answer.
+var (
+	ErrEmptySQL   = errors.New("provider returned no SQL")
+	ErrInvalidSQL = errors.New("SQL failed validation")
+)
+
+// Validator rejects generated SQL that must not be returned to the client.
+type Validator func(sql string) error
+
+// NotEmpty rejects blank SQL.
+func NotEmpty(sql string) error {
+	if strings.TrimSpace(sql) == "" {
+		return ErrEmptySQL
+	}
+	return nil
+}
+
+// raceResult is what a single racing provider reports back.
+type raceResult struct {
+	index    int
+	result   provider.Result
+	err      error
+	duration time.Duration
+}
+
+// Race starts every provider at once and returns the first SQL that passes
+// validate. If none does, a provider asking for clarification is reported
+// over other failures. As soon as there is a winner the other providers'
+// contexts are cancelled, which kills their subprocesses; Race waits for
+// them to exit so that every attempt is recorded with its timing. Attempts
+// are reported in the order of names.
+func Race(ctx context.Context, names []string, validate Validator, run RunFunc) (Outcome, error) {
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan raceResult, len(names))
+	start := time.Now()
+	for i, name := range names {
+		go func(i int, name string) {
+			result, err := run(raceCtx, name)
+			if err == nil && validate != nil {
+				err = validate(result.SQL)
+			}
+			results <- raceResult{index: i, result: result, err: err, duration: time.Since(start)}
+		}(i, name)
+	}
+
+	var outcome Outcome
+	var lastErr error
+	won := false
+	attempts := make([]Attempt, len(names))
+
+	for range names {
+		result := <-results
+		attempt := Attempt{Provider: names[result.index], DurationMS: result.duration.Milliseconds()}
+
+		switch {
+		case result.err == nil && !won:
+			won = true
+			outcome.Result = result.result
+			outcome.Provider = attempt.Provider
+			cancel()
+		case result.err == nil:
+			attempt.Error = "lost the race"
+		case won:
+			attempt.Error = "cancelled"
+		default:
+			attempt.fail(result.err)
+			lastErr = lastError(lastErr, result.err)
+		}
+		attempts[result.index] = attempt
+	}
+	outcome.Attempts = attempts
+
+	if won {
+		return outcome, nil
+	}
+	if ctx.Err() != nil {
+		return outcome, ctx.Err()
+	}
+	return outcome, errors.Join(ErrAllFailed, lastErr)
+}
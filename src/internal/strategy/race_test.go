@@ -0,0 +1,110 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tobilg/text-to-sql-proxy/src/internal/provider"
+)
+
+func TestRace_FastestWinsAndLosersAreCancelled(t *testing.T) {
+	cancelled := make(chan string, 1)
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		if name == "codex" {
+			return provider.Result{SQL: "SELECT 'codex'"}, nil
+		}
+		<-ctx.Done()
+		cancelled <- name
+		return provider.Result{}, errors.Join(provider.ErrCLIExecution, ctx.Err())
+	}
+
+	outcome, err := Race(context.Background(), []string{"claude", "codex"}, NotEmpty, run)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if outcome.Provider != "codex" || outcome.Result.SQL != "SELECT 'codex'" {
+		t.Errorf("unexpected outcome: %+v", outcome)
+	}
+	if got := <-cancelled; got != "claude" {
+		t.Errorf("expected claude to be cancelled, got %s", got)
+	}
+	if len(outcome.Attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %+v", outcome.Attempts)
+	}
+	if outcome.Attempts[0].Provider != "claude" || outcome.Attempts[0].Error != "cancelled" {
+		t.Errorf("expected cancelled claude attempt first, got %+v", outcome.Attempts[0])
+	}
+	if outcome.Attempts[1].Provider != "codex" || outcome.Attempts[1].Error != "" {
+		t.Errorf("expected successful codex attempt, got %+v", outcome.Attempts[1])
+	}
+}
+
+func TestRace_InvalidAnswerDoesNotWin(t *testing.T) {
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		if name == "claude" {
+			return provider.Result{SQL: "   "}, nil
+		}
+		time.Sleep(20 * time.Millisecond)
+		return provider.Result{SQL: "SELECT 1"}, nil
+	}
+
+	outcome, err := Race(context.Background(), []string{"claude", "codex"}, NotEmpty, run)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if outcome.Provider != "codex" {
+		t.Errorf("expected codex to win, got %q", outcome.Provider)
+	}
+	if outcome.Attempts[0].Error != ErrEmptySQL.Error() {
+		t.Errorf("expected claude's empty answer to be rejected, got %+v", outcome.Attempts[0])
+	}
+}
+
+func TestRace_AllFail(t *testing.T) {
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		return provider.Result{}, provider.ErrCLIExecution
+	}
+
+	outcome, err := Race(context.Background(), []string{"claude", "codex"}, NotEmpty, run)
+	if !errors.Is(err, ErrAllFailed) || !errors.Is(err, provider.ErrCLIExecution) {
+		t.Errorf("expected ErrAllFailed wrapping the provider error, got %v", err)
+	}
+	if len(outcome.Attempts) != 2 {
+		t.Errorf("expected 2 attempts, got %+v", outcome.Attempts)
+	}
+}
+
+func TestRace_ParentCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		<-ctx.Done()
+		return provider.Result{}, errors.Join(provider.ErrCLIExecution, ctx.Err())
+	}
+
+	_, err := Race(ctx, []string{"claude", "codex"}, NotEmpty, run)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRace_Clarification(t *testing.T) {
+	run := func(ctx context.Context, name string) (provider.Result, error) {
+		if name == "claude" {
+			return provider.Result{}, &provider.Clarification{Question: "Gross or net revenue?"}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return provider.Result{}, provider.ErrCLIExecution
+	}
+
+	_, err := Race(context.Background(), []string{"claude", "codex"}, NotEmpty, run)
+	var clarification *provider.Clarification
+	if !errors.Is(err, ErrAllFailed) || !errors.As(err, &clarification) {
+		t.Errorf("expected the clarification to outrank the later failure, got %v", err)
+	}
+}